@@ -47,10 +47,8 @@ module.exports = {
     }
 
     const clientOptions = {
-      documentSelector: [{ scheme: 'file', language:'c3'}],
-      synchronize: {
-        fileEvents: workspace.createFileSystemWatcher('**/*.c3')
-      }
+      // The server registers the file watchers it needs.
+      documentSelector: [{ scheme: 'file', language:'c3'}, { scheme: 'c3l', language:'c3'}]
     }
 
     client = new LanguageClient(
@@ -60,6 +58,13 @@ module.exports = {
     );
     client.setTrace(Trace.Verbose);
     client.start();
+
+    // Files inside .c3l library archives are only readable through the server.
+    context.subscriptions.push(workspace.registerTextDocumentContentProvider('c3l', {
+      provideTextDocumentContent: function (uri) {
+        return client.sendRequest('c3lsp/libraryFileContent', { textDocument: { uri: uri.toString() } });
+      }
+    }));
   },
 
   deactivate: function () {
@@ -4,9 +4,12 @@ import (
 	"flag"
 	"fmt"
 	"runtime/debug"
+	"slices"
 	"time"
 
 	"github.com/pherrymason/c3-lsp/internal/c3c"
+	"github.com/pherrymason/c3-lsp/internal/lsp/diagnostics"
+	"github.com/pherrymason/c3-lsp/internal/lsp/formatter"
 	"github.com/pherrymason/c3-lsp/internal/lsp/server"
 	"github.com/pherrymason/c3-lsp/pkg/option"
 )
@@ -18,6 +21,8 @@ func cmdLineArguments() (server.ServerOpts, bool, bool) {
 
 	var logFilePath = flag.String("log-path", "", "Enables logs and sets its filepath")
 	var debug = flag.Bool("debug", false, "Enables debug mode")
+	var listen = flag.String("listen", "", "Listens for clients on tcp:HOST:PORT or ws:HOST:PORT instead of using stdio.")
+	var shared = flag.Bool("shared", false, "With -listen, connections share the same index instead of having their own.")
 
 	// C3 Options
 	flag.String("lang-version", "0.6.2", "Specify C3 language version. Deprecated.")
@@ -28,40 +33,65 @@ func cmdLineArguments() (server.ServerOpts, bool, bool) {
 
 	flag.Parse()
 
-	c3cPathOpt := option.None[string]()
-	if *c3cPath != "" {
-		c3cPathOpt = option.Some(*c3cPath)
-	}
-	stdlibPathOpt := option.None[string]()
-	if *stdlibPath != "" {
-		stdlibPathOpt = option.Some(*stdlibPath)
-	}
-
 	logFilePathOpt := option.None[string]()
 	if *logFilePath != "" {
 		logFilePathOpt = option.Some(*logFilePath)
 	}
 
+	listenOpt := option.None[string]()
+	if *listen != "" {
+		listenOpt = option.Some(*listen)
+	}
+
 	//log.Printf("Version: %s\n", *c3Version)
 	//log.Printf("Logpath: %s\n", *logFilePath)
 	//log.Printf("Delay: %d\n", *diagnosticsDelay)
 	//log.Printf("---------------")
 
+	options := newServerOpts(*c3cPath, *stdlibPath)
+	options.Diagnostics.Delay = time.Duration(*diagnosticsDelay)
+	options.LogFilepath = logFilePathOpt
+	options.Debug = *debug
+	options.SendCrashReports = *sendCrashReports
+	options.Listen = listenOpt
+	options.SharedState = *shared
+
+	return options, *showHelp, *showVersion
+}
+
+// newServerOpts returns the default options of the server, using c3c and the stdlib at the given
+// paths when they are not empty.
+func newServerOpts(c3cPath string, stdlibPath string) server.ServerOpts {
+	c3cPathOpt := option.None[string]()
+	if c3cPath != "" {
+		c3cPathOpt = option.Some(c3cPath)
+	}
+	stdlibPathOpt := option.None[string]()
+	if stdlibPath != "" {
+		stdlibPathOpt = option.Some(stdlibPath)
+	}
+
 	return server.ServerOpts{
 		C3: c3c.C3Opts{
 			Version:     option.None[string](),
 			Path:        c3cPathOpt,
 			StdlibPath:  stdlibPathOpt,
 			CompileArgs: []string{},
+			Targets:     []string{},
 		},
 		Diagnostics: server.DiagnosticsOpts{
-			Delay:   time.Duration(*diagnosticsDelay),
+			Delay:   2000,
+			Timeout: 60000,
 			Enabled: true,
+			Checks:  diagnostics.DefaultChecks(),
 		},
-		LogFilepath:      logFilePathOpt,
-		Debug:            *debug,
-		SendCrashReports: *sendCrashReports,
-	}, *showHelp, *showVersion
+		Formatting: server.FormattingOpts{
+			UseTabs:      option.None[bool](),
+			IndentSize:   option.None[int](),
+			BraceStyle:   formatter.BraceStyleNextLine,
+			MaxLineWidth: 120,
+		},
+	}
 }
 
 func printAppGreet(appName string, version string, commit string) {
@@ -73,6 +103,16 @@ func printHelp(appName string, version string, commit string) {
 
 	fmt.Println("\nOptions")
 	flag.PrintDefaults()
+
+	fmt.Println("\nCommands, run without an editor")
+	names := []string{}
+	for name := range commandHelps {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		fmt.Printf("  %s\n    \t%s\n", commandHelps[name].usage, commandHelps[name].description)
+	}
 }
 
 func buildInfo() string {
@@ -0,0 +1,317 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pherrymason/c3-lsp/internal/lsp/diagnostics"
+	"github.com/pherrymason/c3-lsp/internal/lsp/server"
+	"github.com/pherrymason/c3-lsp/pkg/symbols"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// Exit codes of the commands.
+const (
+	exitOk       = 0
+	exitProblems = 1
+	exitFailure  = 2
+)
+
+// commands run the analysis of the server from the command line, to use it in scripts and CI.
+// They return the exit code of the program.
+var commands = map[string]func(args []string) int{
+	"check":      runCheck,
+	"symbols":    runSymbols,
+	"definition": runDefinition,
+}
+
+type commandHelp struct {
+	usage       string
+	description string
+}
+
+var commandHelps = map[string]commandHelp{
+	"check": {
+		usage:       "check [options] [FILE...]",
+		description: "Reports syntax errors, problems found by the semantic checks and, with -c3c, errors of c3c, in every file of the project when none is given.",
+	},
+	"symbols": {
+		usage:       "symbols [options] [FILE...]",
+		description: "Prints the symbols declared in the files, or in every file of the project when none is given.",
+	},
+	"definition": {
+		usage:       "definition [options] FILE:LINE:COLUMN",
+		description: "Prints where the symbol at the position is defined. Lines and columns start at 1.",
+	},
+}
+
+// commandOptions are the options common to every command.
+type commandOptions struct {
+	root       *string
+	c3cPath    *string
+	stdlibPath *string
+	verbose    *bool
+}
+
+func newCommandFlags(name string) (*flag.FlagSet, commandOptions) {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.Usage = func() {
+		help := commandHelps[name]
+		fmt.Fprintf(flags.Output(), "Usage: %s %s\n\n%s\n\nOptions\n", os.Args[0], help.usage, help.description)
+		flags.PrintDefaults()
+	}
+
+	return flags, commandOptions{
+		root:       flags.String("root", ".", "Root of the workspace, where project.json and c3lsp.json are."),
+		c3cPath:    flags.String("c3c-path", "", "Path where c3c is located."),
+		stdlibPath: flags.String("stdlib-path", "", "Path to stdlib sources."),
+		verbose:    flags.Bool("verbose", false, "Logs what the server does to stderr."),
+	}
+}
+
+// openWorkspace indexes the workspace the command runs on.
+func openWorkspace(options commandOptions) (*server.Headless, error) {
+	if !*options.verbose {
+		log.SetOutput(io.Discard)
+	}
+
+	return server.NewHeadless(newServerOpts(*options.c3cPath, *options.stdlibPath), appName, version, *options.root)
+}
+
+// openFiles returns the documents of the files given to a command, or of every file of the
+// project when none is given.
+func openFiles(workspace *server.Headless, paths []string) ([]string, error) {
+	if len(paths) == 0 {
+		return workspace.Files(), nil
+	}
+
+	docIds := []string{}
+	for _, path := range paths {
+		docId, err := workspace.OpenFile(path)
+		if err != nil {
+			return nil, err
+		}
+		docIds = append(docIds, docId)
+	}
+
+	return docIds, nil
+}
+
+func commandFailed(name string, err error) int {
+	fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+	return exitFailure
+}
+
+func runCheck(args []string) int {
+	flags, options := newCommandFlags("check")
+	format := flags.String("format", diagnostics.FormatText, "Output format: text, json or sarif.")
+	failOn := flags.String("fail-on", "error", "Lowest severity making the command fail: error, warning, information or hint.")
+	compiler := flags.Bool("c3c", false, "Also checks the project with c3c, reporting its errors and warnings.")
+	if err := flags.Parse(args); err != nil {
+		return exitFailure
+	}
+	if !diagnostics.IsValidFormat(*format) {
+		return commandFailed("check", fmt.Errorf("unknown format %q", *format))
+	}
+	failSeverity, ok := severities[*failOn]
+	if !ok {
+		return commandFailed("check", fmt.Errorf("unknown severity %q", *failOn))
+	}
+
+	workspace, err := openWorkspace(options)
+	if err != nil {
+		return commandFailed("check", err)
+	}
+	docIds, err := openFiles(workspace, flags.Args())
+	if err != nil {
+		return commandFailed("check", err)
+	}
+
+	if *compiler {
+		if err := workspace.CheckWithCompiler(); err != nil {
+			return commandFailed("check", err)
+		}
+	}
+
+	report := workspace.Check(docIds)
+	if err := report.Write(os.Stdout, *format); err != nil {
+		return commandFailed("check", err)
+	}
+	if report.HasSeverity(failSeverity) {
+		return exitProblems
+	}
+
+	return exitOk
+}
+
+var severities = map[string]protocol.DiagnosticSeverity{
+	string(diagnostics.SeverityError):       protocol.DiagnosticSeverityError,
+	string(diagnostics.SeverityWarning):     protocol.DiagnosticSeverityWarning,
+	string(diagnostics.SeverityInformation): protocol.DiagnosticSeverityInformation,
+	string(diagnostics.SeverityHint):        protocol.DiagnosticSeverityHint,
+}
+
+func runSymbols(args []string) int {
+	flags, options := newCommandFlags("symbols")
+	format := flags.String("format", diagnostics.FormatText, "Output format: text or json.")
+	if err := flags.Parse(args); err != nil {
+		return exitFailure
+	}
+	if *format != diagnostics.FormatText && *format != diagnostics.FormatJSON {
+		return commandFailed("symbols", fmt.Errorf("unknown format %q", *format))
+	}
+
+	workspace, err := openWorkspace(options)
+	if err != nil {
+		return commandFailed("symbols", err)
+	}
+	docIds, err := openFiles(workspace, flags.Args())
+	if err != nil {
+		return commandFailed("symbols", err)
+	}
+
+	if *format == diagnostics.FormatJSON {
+		type fileSymbols struct {
+			Path    string                    `json:"path"`
+			Symbols []protocol.DocumentSymbol `json:"symbols"`
+		}
+		files := []fileSymbols{}
+		for _, docId := range docIds {
+			files = append(files, fileSymbols{Path: docId, Symbols: workspace.Symbols(docId)})
+		}
+
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(files); err != nil {
+			return commandFailed("symbols", err)
+		}
+		return exitOk
+	}
+
+	for _, docId := range docIds {
+		fmt.Println(docId)
+		printSymbols(workspace.Symbols(docId), "  ")
+	}
+
+	return exitOk
+}
+
+// printSymbols prints a line per symbol, "name kind line:column", indenting their children.
+func printSymbols(documentSymbols []protocol.DocumentSymbol, indent string) {
+	for _, symbol := range documentSymbols {
+		fmt.Printf("%s%s %s %d:%d\n", indent, symbol.Name, symbolKindName(symbol.Kind),
+			symbol.SelectionRange.Start.Line+1, symbol.SelectionRange.Start.Character+1)
+		printSymbols(symbol.Children, indent+"  ")
+	}
+}
+
+func symbolKindName(kind protocol.SymbolKind) string {
+	switch kind {
+	case protocol.SymbolKindModule:
+		return "module"
+	case protocol.SymbolKindFunction:
+		return "function"
+	case protocol.SymbolKindMethod:
+		return "method"
+	case protocol.SymbolKindVariable:
+		return "variable"
+	case protocol.SymbolKindConstant:
+		return "constant"
+	case protocol.SymbolKindStruct:
+		return "struct"
+	case protocol.SymbolKindField:
+		return "field"
+	case protocol.SymbolKindEnum:
+		return "enum"
+	case protocol.SymbolKindEnumMember:
+		return "enum-member"
+	case protocol.SymbolKindInterface:
+		return "interface"
+	case protocol.SymbolKindTypeParameter:
+		return "type"
+	}
+
+	return strconv.Itoa(int(kind))
+}
+
+func runDefinition(args []string) int {
+	flags, options := newCommandFlags("definition")
+	if err := flags.Parse(args); err != nil {
+		return exitFailure
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return exitFailure
+	}
+	path, position, err := parseFilePosition(flags.Arg(0))
+	if err != nil {
+		return commandFailed("definition", err)
+	}
+
+	workspace, err := openWorkspace(options)
+	if err != nil {
+		return commandFailed("definition", err)
+	}
+	docId, err := workspace.OpenFile(path)
+	if err != nil {
+		return commandFailed("definition", err)
+	}
+
+	definition := workspace.Definition(docId, position)
+	if definition.IsNone() {
+		fmt.Fprintln(os.Stderr, "definition: no symbol found")
+		return exitProblems
+	}
+
+	symbol := definition.Get()
+	if !symbol.HasSourceCode() {
+		// Bundled stdlib symbols are known without their sources.
+		fmt.Printf("%s (no source)\n", symbol.GetFQN())
+		return exitOk
+	}
+
+	idRange := symbol.GetIdRange()
+	fmt.Printf("%s:%d:%d: %s\n", symbol.GetDocumentURI(),
+		idRange.Start.Line+1, idRange.Start.Character+1, symbol.GetFQN())
+
+	return exitOk
+}
+
+// parseFilePosition parses "FILE:LINE:COLUMN", where lines and columns start at 1.
+// The file may contain colons, like Windows paths do.
+func parseFilePosition(argument string) (string, symbols.Position, error) {
+	invalid := errors.New("expected FILE:LINE:COLUMN, got " + argument)
+
+	rest, columnText, found := cutLast(argument, ":")
+	if !found {
+		return "", symbols.Position{}, invalid
+	}
+	path, lineText, found := cutLast(rest, ":")
+	if !found {
+		return "", symbols.Position{}, invalid
+	}
+
+	line, lineErr := strconv.ParseUint(lineText, 10, 32)
+	column, columnErr := strconv.ParseUint(columnText, 10, 32)
+	if lineErr != nil || columnErr != nil || line == 0 || column == 0 || path == "" {
+		return "", symbols.Position{}, invalid
+	}
+
+	return path, symbols.NewPosition(uint(line-1), uint(column-1)), nil
+}
+
+func cutLast(s string, separator string) (string, string, bool) {
+	index := strings.LastIndex(s, separator)
+	if index < 0 {
+		return s, "", false
+	}
+
+	return s[:index], s[index+len(separator):], true
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"log"
+	"os"
 	"time"
 
 	"github.com/getsentry/sentry-go"
@@ -14,6 +15,12 @@ const prerelease = false
 const appName = "C3-LSP"
 
 func main() {
+	if len(os.Args) > 1 {
+		if run, ok := commands[os.Args[1]]; ok {
+			os.Exit(run(os.Args[2:]))
+		}
+	}
+
 	options, showHelp, showVersion := cmdLineArguments()
 	commitHash := buildInfo()
 	if showHelp {
@@ -44,7 +51,9 @@ func main() {
 	}
 
 	server := server.NewServer(options, appName, version)
-	server.Run()
+	if err := server.Run(); err != nil {
+		log.Fatal(err)
+	}
 }
 
 func getLSPVersion() string {
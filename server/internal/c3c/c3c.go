@@ -2,9 +2,15 @@ package c3c
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"log"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"regexp"
+	"sync"
+	"time"
 
 	"github.com/pherrymason/c3-lsp/pkg/option"
 )
@@ -17,6 +23,32 @@ func binaryPath(c3Path option.Option[string]) string {
 	return binary
 }
 
+// FindStdlibPath looks for the stdlib sources installed with the c3c binary: next to it, as
+// in c3c releases, or in the lib directory of its prefix, as installed by package managers.
+func FindStdlibPath(c3Path option.Option[string]) option.Option[string] {
+	binary, err := exec.LookPath(binaryPath(c3Path))
+	if err != nil {
+		return option.None[string]()
+	}
+	if resolved, err := filepath.EvalSymlinks(binary); err == nil {
+		binary = resolved
+	}
+
+	dir := filepath.Dir(binary)
+	candidates := []string{
+		filepath.Join(dir, "lib", "std"),
+		filepath.Join(dir, "..", "lib", "std"),
+		filepath.Join(dir, "..", "lib", "c3", "std"),
+	}
+	for _, candidate := range candidates {
+		if info, err := os.Stat(candidate); err == nil && info.IsDir() {
+			return option.Some(filepath.Clean(candidate))
+		}
+	}
+
+	return option.None[string]()
+}
+
 func GetC3Version(c3Path option.Option[string]) option.Option[string] {
 	binary := binaryPath(c3Path)
 	command := exec.Command(binary, "--version")
@@ -45,16 +77,25 @@ func GetC3Version(c3Path option.Option[string]) option.Option[string] {
 	return option.None[string]()
 }
 
-func CheckC3ErrorsCommand(c3Options C3Opts, projectPath string) (bytes.Buffer, bytes.Buffer, error) {
+// CheckC3ErrorsCommand builds target, or the default target of the project when empty,
+// in test mode so c3c prints its errors in a format that can be parsed.
+// The build is stopped when ctx is done.
+func CheckC3ErrorsCommand(ctx context.Context, c3Options C3Opts, projectPath string, target string) (bytes.Buffer, bytes.Buffer, error) {
 	binary := binaryPath(c3Options.Path)
 
-	args := []string{"build", "--test"}
+	args := []string{"build"}
+	if target != "" {
+		args = append(args, target)
+	}
+	args = append(args, "--test")
 	if len(c3Options.CompileArgs) > 0 {
 		args = append(args, c3Options.CompileArgs...)
 	}
 
-	command := exec.Command(binary, args...)
+	command := exec.CommandContext(ctx, binary, args...)
 	command.Dir = projectPath
+	// Do not wait for processes started by c3c that are still writing to the output once it is stopped.
+	command.WaitDelay = time.Second
 
 	// set var to get the output
 	var out bytes.Buffer
@@ -65,6 +106,55 @@ func CheckC3ErrorsCommand(c3Options C3Opts, projectPath string) (bytes.Buffer, b
 	command.Stderr = &stdErr
 
 	err := command.Run()
+	if ctx.Err() != nil {
+		err = ctx.Err()
+	}
 
 	return out, stdErr, err
 }
+
+// TargetCheck is the result of checking a target with c3c.
+type TargetCheck struct {
+	Target      string
+	Diagnostics []Diagnostic
+	// Unsupported is true when c3c is too old to report its errors in a format that can be parsed.
+	Unsupported bool
+	TimedOut    bool
+}
+
+// CheckTargets checks the targets in parallel. An empty list checks the default target of the project.
+// Targets taking longer than timeout are stopped and reported as TimedOut. A zero timeout waits forever.
+func CheckTargets(c3Options C3Opts, projectPath string, targets []string, timeout time.Duration) []TargetCheck {
+	if len(targets) == 0 {
+		targets = []string{""}
+	}
+
+	checks := make([]TargetCheck, len(targets))
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			ctx := context.Background()
+			if timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, timeout)
+				defer cancel()
+			}
+
+			_, stdErr, err := CheckC3ErrorsCommand(ctx, c3Options, projectPath, target)
+			checks[i] = TargetCheck{Target: target}
+			if errors.Is(err, context.DeadlineExceeded) {
+				log.Printf("c3c took longer than %s to check target %q", timeout, target)
+				checks[i].TimedOut = true
+				return
+			}
+
+			checks[i].Diagnostics, checks[i].Unsupported = ParseDiagnostics(stdErr.String())
+		}()
+	}
+	wg.Wait()
+
+	return checks
+}
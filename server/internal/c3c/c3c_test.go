@@ -0,0 +1,31 @@
+package c3c
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/pherrymason/c3-lsp/pkg/option"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindStdlibPath_finds_stdlib_installed_with_c3c(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script as c3c")
+	}
+
+	release := t.TempDir()
+	assert.Nil(t, os.MkdirAll(filepath.Join(release, "lib", "std"), 0755))
+	assert.Nil(t, os.WriteFile(filepath.Join(release, "c3c"), []byte("#!/bin/sh\n"), 0755))
+
+	prefix := t.TempDir()
+	assert.Nil(t, os.MkdirAll(filepath.Join(prefix, "bin"), 0755))
+	assert.Nil(t, os.MkdirAll(filepath.Join(prefix, "lib", "c3", "std"), 0755))
+	assert.Nil(t, os.WriteFile(filepath.Join(prefix, "bin", "c3c"), []byte("#!/bin/sh\n"), 0755))
+
+	assert.Equal(t, option.Some(filepath.Join(release, "lib", "std")), FindStdlibPath(option.Some(filepath.Join(release, "c3c"))))
+	assert.Equal(t, option.Some(filepath.Join(prefix, "lib", "c3", "std")), FindStdlibPath(option.Some(filepath.Join(prefix, "bin", "c3c"))))
+	missing := FindStdlibPath(option.Some(filepath.Join(t.TempDir(), "missing")))
+	assert.True(t, missing.IsNone())
+}
@@ -0,0 +1,110 @@
+package c3c
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type Severity string
+
+const (
+	SeverityError   Severity = "Error"
+	SeverityWarning Severity = "Warning"
+	SeverityNote    Severity = "Note"
+)
+
+// Diagnostic is a message reported by c3c when run with --test.
+type Diagnostic struct {
+	Severity Severity
+	File     string
+	// Line and Column are 0 based.
+	Line    uint
+	Column  uint
+	Message string
+	// Notes give more details about the error or warning they follow.
+	Notes []Diagnostic
+	// Targets where the diagnostic was reported, when several targets are checked.
+	Targets []string
+}
+
+// ParseDiagnostics extracts the errors, warnings and notes from the output of c3c.
+// Each one is printed in its own line as `Severity|file|line|column|message`.
+// unsupported is true when the output comes from a c3c version not using this format.
+func ParseDiagnostics(output string) (diagnostics []Diagnostic, unsupported bool) {
+	diagnostics = []Diagnostic{}
+
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimRight(line, "\r")
+		parts := strings.SplitN(line, "|", 5)
+		severity := Severity(parts[0])
+		if severity != SeverityError && severity != SeverityWarning && severity != SeverityNote {
+			continue
+		}
+		if len(parts) != 5 {
+			if severity == SeverityError {
+				unsupported = true
+			}
+			continue
+		}
+
+		row, err := strconv.Atoi(parts[2])
+		if err != nil || row < 1 {
+			continue
+		}
+		column, err := strconv.Atoi(parts[3])
+		if err != nil || column < 1 {
+			continue
+		}
+
+		diagnostic := Diagnostic{
+			Severity: severity,
+			File:     parts[1],
+			Line:     uint(row - 1),
+			Column:   uint(column - 1),
+			Message:  parts[4],
+			Notes:    []Diagnostic{},
+		}
+
+		if severity == SeverityNote {
+			if len(diagnostics) > 0 {
+				last := &diagnostics[len(diagnostics)-1]
+				last.Notes = append(last.Notes, diagnostic)
+			}
+			continue
+		}
+
+		diagnostics = append(diagnostics, diagnostic)
+	}
+
+	return diagnostics, unsupported
+}
+
+// MergeTargetDiagnostics joins the diagnostics of the checked targets. Diagnostics reported by
+// several targets are kept once, with the list of targets reporting them.
+// Targets that timed out are left out, as their diagnostics are unknown.
+func MergeTargetDiagnostics(checks []TargetCheck) []Diagnostic {
+	merged := []Diagnostic{}
+	index := map[string]int{}
+	for _, check := range checks {
+		if check.TimedOut {
+			continue
+		}
+
+		for _, diagnostic := range check.Diagnostics {
+			key := fmt.Sprintf("%s|%s|%d|%d|%s", diagnostic.Severity, diagnostic.File, diagnostic.Line, diagnostic.Column, diagnostic.Message)
+			i, ok := index[key]
+			if !ok {
+				i = len(merged)
+				index[key] = i
+				diagnostic.Targets = []string{}
+				merged = append(merged, diagnostic)
+			}
+			if check.Target != "" {
+				merged[i].Targets = append(merged[i].Targets, check.Target)
+			}
+		}
+	}
+
+	return merged
+}
@@ -0,0 +1,46 @@
+package c3c
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDiagnostics_reads_every_error_and_warning(t *testing.T) {
+	output := `Warning|/project/src/main.c3|3|5|Unused variable 'x'.
+Error|/project/src/main.c3|10|12|'foo' could not be found, did you spell it right?
+Error|/project/src/other.c3|1|1|Expected ';'
+`
+
+	diagnostics, unsupported := ParseDiagnostics(output)
+
+	assert.False(t, unsupported)
+	assert.Equal(t, []Diagnostic{
+		{Severity: SeverityWarning, File: "/project/src/main.c3", Line: 2, Column: 4, Message: "Unused variable 'x'.", Notes: []Diagnostic{}},
+		{Severity: SeverityError, File: "/project/src/main.c3", Line: 9, Column: 11, Message: "'foo' could not be found, did you spell it right?", Notes: []Diagnostic{}},
+		{Severity: SeverityError, File: "/project/src/other.c3", Line: 0, Column: 0, Message: "Expected ';'", Notes: []Diagnostic{}},
+	}, diagnostics)
+}
+
+func TestParseDiagnostics_attaches_notes_to_previous_diagnostic(t *testing.T) {
+	output := "Error|/project/a.c3|4|2|Duplicate symbol 'x'.\r\nNote|/project/b.c3|7|3|The previous definition was here.\r\n"
+
+	diagnostics, _ := ParseDiagnostics(output)
+
+	assert.Equal(t, 1, len(diagnostics))
+	assert.Equal(t, []Diagnostic{
+		{Severity: SeverityNote, File: "/project/b.c3", Line: 6, Column: 2, Message: "The previous definition was here.", Notes: []Diagnostic{}},
+	}, diagnostics[0].Notes)
+}
+
+func TestParseDiagnostics_keeps_pipes_in_messages(t *testing.T) {
+	diagnostics, _ := ParseDiagnostics("Error|/a.c3|1|3|Cannot use '|' here.")
+
+	assert.Equal(t, "Cannot use '|' here.", diagnostics[0].Message)
+}
+
+func TestParseDiagnostics_detects_old_output_format(t *testing.T) {
+	_, unsupported := ParseDiagnostics("Error|/a.c3|Something failed")
+
+	assert.True(t, unsupported)
+}
@@ -0,0 +1,170 @@
+package c3c
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/pherrymason/c3-lsp/pkg/fs"
+)
+
+// LibraryFile is a C3 source of a library.
+type LibraryFile struct {
+	// Path identifies the file. Files inside an archive are identified by fs.ArchiveEntryPath.
+	Path    string
+	Content string
+	// Archived tells if the file is inside an archive rather than on disk.
+	Archived bool
+}
+
+// libraryManifest is the content of the manifest.json of a library that the language server uses.
+type libraryManifest struct {
+	Sources []string `json:"sources"`
+}
+
+// ReadLibrary reads the sources of a `.c3l` library, either a zip archive or an unpacked directory.
+// Only the sources listed in its manifest.json are read, or every C3 file when it lists none.
+func ReadLibrary(libraryPath string) ([]LibraryFile, error) {
+	info, err := os.Stat(libraryPath)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return readLibraryDirectory(libraryPath)
+	}
+
+	return readLibraryArchive(libraryPath)
+}
+
+// ReadLibraryFile returns the content of a file inside a library archive, identified by a path
+// built with fs.ArchiveEntryPath.
+func ReadLibraryFile(filePath string) (string, error) {
+	archive, entry, ok := fs.SplitArchiveEntryPath(filePath)
+	if !ok {
+		return "", fmt.Errorf("%s is not inside a library archive", filePath)
+	}
+
+	reader, err := zip.OpenReader(archive)
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+
+	return readArchiveEntry(&reader.Reader, entry)
+}
+
+func readLibraryDirectory(libraryPath string) ([]LibraryFile, error) {
+	var manifest libraryManifest
+	if data, err := os.ReadFile(filepath.Join(libraryPath, "manifest.json")); err == nil {
+		if err := json.Unmarshal(stripJSONComments(data), &manifest); err != nil {
+			return nil, err
+		}
+	}
+
+	entries := []string{}
+	for _, file := range scanC3Files(libraryPath, true) {
+		entry, err := filepath.Rel(libraryPath, file)
+		if err == nil {
+			entries = append(entries, filepath.ToSlash(entry))
+		}
+	}
+
+	files := []LibraryFile{}
+	for _, entry := range selectLibrarySources(entries, manifest.Sources) {
+		filePath := filepath.Join(libraryPath, filepath.FromSlash(entry))
+		content, err := os.ReadFile(filePath)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, LibraryFile{Path: filePath, Content: string(content)})
+	}
+
+	return files, nil
+}
+
+func readLibraryArchive(libraryPath string) ([]LibraryFile, error) {
+	reader, err := zip.OpenReader(libraryPath)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	var manifest libraryManifest
+	if data, err := readArchiveEntry(&reader.Reader, "manifest.json"); err == nil {
+		if err := json.Unmarshal(stripJSONComments([]byte(data)), &manifest); err != nil {
+			return nil, err
+		}
+	}
+
+	entries := []string{}
+	for _, file := range reader.File {
+		if !file.FileInfo().IsDir() && isC3File(file.Name) {
+			entries = append(entries, file.Name)
+		}
+	}
+
+	files := []LibraryFile{}
+	for _, entry := range selectLibrarySources(entries, manifest.Sources) {
+		content, err := readArchiveEntry(&reader.Reader, entry)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, LibraryFile{Path: fs.ArchiveEntryPath(libraryPath, entry), Content: content, Archived: true})
+	}
+
+	return files, nil
+}
+
+func readArchiveEntry(reader *zip.Reader, entry string) (string, error) {
+	file, err := reader.Open(entry)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	content, err := io.ReadAll(file)
+
+	return string(content), err
+}
+
+// selectLibrarySources returns the entries, relative to the library, matching the sources of its
+// manifest, with the same wildcards as project.json. Every entry matches when there are no sources.
+func selectLibrarySources(entries []string, sources []string) []string {
+	selected := []string{}
+	for _, entry := range entries {
+		if len(sources) == 0 || slices.ContainsFunc(sources, func(source string) bool {
+			return matchesSource(entry, source)
+		}) {
+			selected = append(selected, entry)
+		}
+	}
+	slices.Sort(selected)
+
+	return selected
+}
+
+func matchesSource(entry string, source string) bool {
+	source = strings.TrimPrefix(path.Clean(filepath.ToSlash(source)), "./")
+
+	switch {
+	case source == "**" || source == "*" && !strings.Contains(entry, "/"):
+		return true
+	case strings.HasSuffix(source, "/**"):
+		return strings.HasPrefix(entry, strings.TrimSuffix(source, "**"))
+	case strings.HasSuffix(source, "/*"):
+		return path.Dir(entry) == strings.TrimSuffix(source, "/*")
+	}
+
+	if matched, _ := path.Match(source, entry); matched {
+		return true
+	}
+
+	// A directory includes its files and subdirectories.
+	return strings.HasPrefix(entry, source+"/")
+}
@@ -0,0 +1,72 @@
+package c3c
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pherrymason/c3-lsp/pkg/fs"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeArchive(t *testing.T, path string, files map[string]string) {
+	archive, err := os.Create(path)
+	assert.Nil(t, err)
+	defer archive.Close()
+
+	writer := zip.NewWriter(archive)
+	for name, content := range files {
+		entry, err := writer.Create(name)
+		assert.Nil(t, err)
+		_, err = entry.Write([]byte(content))
+		assert.Nil(t, err)
+	}
+	assert.Nil(t, writer.Close())
+}
+
+func TestReadLibrary_reads_sources_of_archives(t *testing.T) {
+	library := filepath.Join(t.TempDir(), "raylib.c3l")
+	writeArchive(t, library, map[string]string{
+		"manifest.json":         `{ "provides": "raylib", "sources": ["raylib.c3i"] }`,
+		"raylib.c3i":            "module raylib;",
+		"examples/example.c3":   "module example;",
+		"linux-x64/libraylib.a": "binary",
+	})
+
+	files, err := ReadLibrary(library)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []LibraryFile{
+		{Path: fs.ArchiveEntryPath(library, "raylib.c3i"), Content: "module raylib;", Archived: true},
+	}, files)
+
+	content, err := ReadLibraryFile(fs.ArchiveEntryPath(library, "raylib.c3i"))
+	assert.Nil(t, err)
+	assert.Equal(t, "module raylib;", content)
+}
+
+func TestReadLibrary_reads_every_source_of_directories_without_manifest_sources(t *testing.T) {
+	library := filepath.Join(t.TempDir(), "json.c3l")
+	assert.Nil(t, os.MkdirAll(filepath.Join(library, "src"), 0755))
+	assert.Nil(t, os.WriteFile(filepath.Join(library, "manifest.json"), []byte(`{ "provides": "json" }`), 0644))
+	assert.Nil(t, os.WriteFile(filepath.Join(library, "json.c3i"), []byte("module json;"), 0644))
+	assert.Nil(t, os.WriteFile(filepath.Join(library, "src", "parser.c3"), []byte("module json::parser;"), 0644))
+
+	files, err := ReadLibrary(library)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []LibraryFile{
+		{Path: filepath.Join(library, "json.c3i"), Content: "module json;"},
+		{Path: filepath.Join(library, "src", "parser.c3"), Content: "module json::parser;"},
+	}, files)
+}
+
+func TestMatchesSource(t *testing.T) {
+	assert.True(t, matchesSource("src/a/b.c3", "src/**"))
+	assert.True(t, matchesSource("src/b.c3", "src/*"))
+	assert.False(t, matchesSource("src/a/b.c3", "src/*"))
+	assert.True(t, matchesSource("src/a/b.c3", "./src"))
+	assert.True(t, matchesSource("raylib.c3i", "*.c3i"))
+	assert.False(t, matchesSource("raylib.c3", "other.c3"))
+}
@@ -7,4 +7,6 @@ type C3Opts struct {
 	Path        option.Option[string] `json:"path"`
 	StdlibPath  option.Option[string] `json:"stdlib-path"`
 	CompileArgs []string              `json:"compile-args"`
+	// Targets of project.json checked by diagnostics, by name or type. The default target when empty.
+	Targets []string `json:"targets"`
 }
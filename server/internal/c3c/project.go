@@ -0,0 +1,252 @@
+package c3c
+
+import (
+	"encoding/json"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Project is the content of the project.json of a C3 project that the language server uses.
+type Project struct {
+	// Sources and TestSources are paths, relative to the project, of files or directories.
+	// `*` and `**` wildcards match files of a directory, and files of a directory and its subdirectories.
+	Sources               []string `json:"sources"`
+	TestSources           []string `json:"test-sources"`
+	DependencySearchPaths []string `json:"dependency-search-paths"`
+	// Dependencies are names of libraries, searched in DependencySearchPaths.
+	Dependencies []string `json:"dependencies"`
+
+	Targets map[string]ProjectTarget `json:"targets"`
+}
+
+// ProjectTarget can declare, besides its type, more sources and dependencies than the project.
+type ProjectTarget struct {
+	Type                  string   `json:"type"`
+	Sources               []string `json:"sources"`
+	TestSources           []string `json:"test-sources"`
+	DependencySearchPaths []string `json:"dependency-search-paths"`
+	Dependencies          []string `json:"dependencies"`
+}
+
+// Target is a build target declared in the project.json of a C3 project.
+type Target struct {
+	Name string
+	// Type is the kind of output: executable, static-lib, dynamic-lib, test...
+	Type string
+}
+
+// ReadProject reads the project.json of projectPath.
+func ReadProject(projectPath string) (Project, error) {
+	var project Project
+	data, err := os.ReadFile(filepath.Join(projectPath, "project.json"))
+	if err != nil {
+		return project, err
+	}
+
+	err = json.Unmarshal(stripJSONComments(data), &project)
+
+	return project, err
+}
+
+// ReadProjectTargets returns the targets declared in the project.json of projectPath, sorted by name.
+func ReadProjectTargets(projectPath string) ([]Target, error) {
+	project, err := ReadProject(projectPath)
+	if err != nil {
+		return nil, err
+	}
+
+	targets := []Target{}
+	for name, target := range project.Targets {
+		targets = append(targets, Target{Name: name, Type: target.Type})
+	}
+	slices.SortFunc(targets, func(a, b Target) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return targets, nil
+}
+
+// SourceFiles returns the C3 files of the project: its sources and test sources, for the
+// project and all its targets. Paths are absolute and sorted.
+func (p Project) SourceFiles(projectPath string) []string {
+	sources := slices.Clone(p.Sources)
+	sources = append(sources, p.TestSources...)
+	for _, target := range p.Targets {
+		sources = append(sources, target.Sources...)
+		sources = append(sources, target.TestSources...)
+	}
+
+	files := map[string]bool{}
+	for _, source := range sources {
+		for _, file := range matchSources(projectPath, source) {
+			files[file] = true
+		}
+	}
+
+	sorted := []string{}
+	for file := range files {
+		sorted = append(sorted, file)
+	}
+	slices.Sort(sorted)
+
+	return sorted
+}
+
+// Libraries returns the paths of the `.c3l` libraries the project and its targets depend on,
+// found in their dependency search paths. A library is either a zip archive or a directory.
+func (p Project) Libraries(projectPath string) []string {
+	searchPaths := slices.Clone(p.DependencySearchPaths)
+	dependencies := slices.Clone(p.Dependencies)
+	for _, target := range p.Targets {
+		searchPaths = append(searchPaths, target.DependencySearchPaths...)
+		dependencies = append(dependencies, target.Dependencies...)
+	}
+	if len(searchPaths) == 0 {
+		// Default search path of c3c.
+		searchPaths = []string{"lib"}
+	}
+
+	libraries := []string{}
+	for _, dependency := range dependencies {
+		for _, searchPath := range searchPaths {
+			library := filepath.Join(absolutePath(projectPath, searchPath), dependency+".c3l")
+			if _, err := os.Stat(library); err == nil {
+				if !slices.Contains(libraries, library) {
+					libraries = append(libraries, library)
+				}
+				break
+			}
+		}
+	}
+	slices.Sort(libraries)
+
+	return libraries
+}
+
+// matchSources returns the C3 files matching a source path of project.json.
+func matchSources(projectPath string, source string) []string {
+	path := absolutePath(projectPath, source)
+
+	switch {
+	case strings.HasSuffix(path, string(filepath.Separator)+"**"):
+		return scanC3Files(strings.TrimSuffix(path, string(filepath.Separator)+"**"), true)
+	case strings.HasSuffix(path, string(filepath.Separator)+"*"):
+		return scanC3Files(strings.TrimSuffix(path, string(filepath.Separator)+"*"), false)
+	case strings.ContainsAny(path, "*?["):
+		matches, _ := filepath.Glob(path)
+		files := []string{}
+		for _, match := range matches {
+			if isC3File(match) {
+				files = append(files, match)
+			}
+		}
+		return files
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return []string{}
+	}
+	if info.IsDir() {
+		return scanC3Files(path, true)
+	}
+
+	return []string{path}
+}
+
+func scanC3Files(dir string, recursive bool) []string {
+	files := []string{}
+	filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if entry.IsDir() {
+			if !recursive && path != dir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if isC3File(path) {
+			files = append(files, path)
+		}
+		return nil
+	})
+
+	return files
+}
+
+func isC3File(path string) bool {
+	ext := filepath.Ext(path)
+	return ext == ".c3" || ext == ".c3i"
+}
+
+func absolutePath(projectPath string, path string) string {
+	path = filepath.FromSlash(path)
+	if filepath.IsAbs(path) {
+		return filepath.Clean(path)
+	}
+
+	return filepath.Join(projectPath, path)
+}
+
+// SelectTargets returns the names of the targets matching the selection, which lists
+// target names or target types, like `wasm` or `static-lib`. "*" selects every target.
+func SelectTargets(targets []Target, selection []string) []string {
+	names := []string{}
+	for _, target := range targets {
+		for _, selected := range selection {
+			if selected == "*" || selected == target.Name || selected == target.Type {
+				names = append(names, target.Name)
+				break
+			}
+		}
+	}
+
+	return names
+}
+
+// stripJSONComments removes the `//` and `/* */` comments c3c accepts in project.json.
+func stripJSONComments(data []byte) []byte {
+	stripped := make([]byte, 0, len(data))
+	inString := false
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		switch {
+		case inString:
+			stripped = append(stripped, c)
+			if c == '\\' && i+1 < len(data) {
+				i++
+				stripped = append(stripped, data[i])
+			} else if c == '"' {
+				inString = false
+			}
+
+		case c == '"':
+			inString = true
+			stripped = append(stripped, c)
+
+		case c == '/' && i+1 < len(data) && data[i+1] == '/':
+			for i < len(data) && data[i] != '\n' {
+				i++
+			}
+			if i < len(data) {
+				stripped = append(stripped, '\n')
+			}
+
+		case c == '/' && i+1 < len(data) && data[i+1] == '*':
+			end := strings.Index(string(data[i+2:]), "*/")
+			if end == -1 {
+				return stripped
+			}
+			i += end + 3
+
+		default:
+			stripped = append(stripped, c)
+		}
+	}
+
+	return stripped
+}
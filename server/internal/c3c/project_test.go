@@ -0,0 +1,138 @@
+package c3c
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/pherrymason/c3-lsp/pkg/option"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadProjectTargets_reads_targets_sorted_by_name(t *testing.T) {
+	dir := t.TempDir()
+	project := `{
+  // Targets of the project.
+  "langrev": "1",
+  "targets": {
+    "wasm": { "type": "executable", "target": "wasm32" },
+    /* Library used by the other targets */
+    "core": { "type": "static-lib", "sources": ["src/**"] },
+    "tests": { "type": "test", "description": "http://example.com // not a comment" }
+  }
+}`
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "project.json"), []byte(project), 0644))
+
+	targets, err := ReadProjectTargets(dir)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []Target{
+		{Name: "core", Type: "static-lib"},
+		{Name: "tests", Type: "test"},
+		{Name: "wasm", Type: "executable"},
+	}, targets)
+}
+
+func TestReadProjectTargets_fails_without_project_json(t *testing.T) {
+	_, err := ReadProjectTargets(t.TempDir())
+
+	assert.NotNil(t, err)
+}
+
+func TestSelectTargets_matches_names_and_types(t *testing.T) {
+	targets := []Target{
+		{Name: "app", Type: "executable"},
+		{Name: "core", Type: "static-lib"},
+		{Name: "tests", Type: "test"},
+		{Name: "wasm", Type: "executable"},
+	}
+
+	assert.Equal(t, []string{"core", "tests"}, SelectTargets(targets, []string{"tests", "static-lib"}))
+	assert.Equal(t, []string{"app", "wasm"}, SelectTargets(targets, []string{"executable"}))
+	assert.Equal(t, []string{"app", "core", "tests", "wasm"}, SelectTargets(targets, []string{"*"}))
+	assert.Equal(t, []string{}, SelectTargets(targets, []string{"unknown"}))
+}
+
+func TestMergeTargetDiagnostics_attributes_diagnostics_to_targets(t *testing.T) {
+	shared := Diagnostic{Severity: SeverityError, File: "/p/a.c3", Line: 1, Column: 2, Message: "Shared", Notes: []Diagnostic{}}
+	wasmOnly := Diagnostic{Severity: SeverityWarning, File: "/p/b.c3", Line: 3, Column: 0, Message: "Wasm only", Notes: []Diagnostic{}}
+
+	merged := MergeTargetDiagnostics([]TargetCheck{
+		{Target: "app", Diagnostics: []Diagnostic{shared}},
+		{Target: "wasm", Diagnostics: []Diagnostic{shared, wasmOnly}},
+		{Target: "slow", TimedOut: true},
+	})
+
+	assert.Equal(t, 2, len(merged))
+	assert.Equal(t, []string{"app", "wasm"}, merged[0].Targets)
+	assert.Equal(t, "Wasm only", merged[1].Message)
+	assert.Equal(t, []string{"wasm"}, merged[1].Targets)
+}
+
+func TestCheckTargets_runs_each_target_and_stops_slow_ones(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script as c3c")
+	}
+
+	dir := t.TempDir()
+	script := `#!/bin/sh
+if [ "$2" = "slow" ]; then sleep 5; fi
+echo "Error|/p/$2.c3|1|1|Failed $2" >&2
+exit 1
+`
+	binary := filepath.Join(dir, "c3c")
+	assert.Nil(t, os.WriteFile(binary, []byte(script), 0755))
+
+	checks := CheckTargets(C3Opts{Path: option.Some(binary)}, dir, []string{"app", "slow"}, 500*time.Millisecond)
+
+	assert.Equal(t, 2, len(checks))
+	assert.Equal(t, "app", checks[0].Target)
+	assert.False(t, checks[0].TimedOut)
+	assert.Equal(t, "Failed app", checks[0].Diagnostics[0].Message)
+	assert.Equal(t, "slow", checks[1].Target)
+	assert.True(t, checks[1].TimedOut)
+}
+
+func TestProject_lists_declared_sources_and_dependencies(t *testing.T) {
+	dir := t.TempDir()
+	files := []string{
+		"src/main.c3",
+		"src/nested/util.c3",
+		"src/notes.txt",
+		"test/main_test.c3",
+		"test/fixtures/broken.c3",
+		"vendor/junk.c3",
+		"lib/json.c3l/json.c3i",
+		"lib/json.c3l/src/parser.c3",
+		"lib/unused.c3l/unused.c3i",
+		"extra/tool.c3",
+	}
+	for _, file := range files {
+		path := filepath.Join(dir, file)
+		assert.Nil(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.Nil(t, os.WriteFile(path, []byte("module x;"), 0644))
+	}
+	project := `{
+  "sources": ["src/**"],
+  "test-sources": ["test/*"],
+  "dependency-search-paths": ["lib"],
+  "dependencies": ["json"],
+  "targets": {
+    "tool": { "type": "executable", "sources": ["extra/tool.c3"] }
+  }
+}`
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "project.json"), []byte(project), 0644))
+
+	read, err := ReadProject(dir)
+	assert.Nil(t, err)
+
+	assert.Equal(t, []string{
+		filepath.Join(dir, "extra/tool.c3"),
+		filepath.Join(dir, "src/main.c3"),
+		filepath.Join(dir, "src/nested/util.c3"),
+		filepath.Join(dir, "test/main_test.c3"),
+	}, read.SourceFiles(dir))
+	assert.Equal(t, []string{filepath.Join(dir, "lib/json.c3l")}, read.Libraries(dir))
+}
@@ -905,6 +905,34 @@ func convert_literal(node *sitter.Node, sourceCode []byte) Expression {
 	return literal
 }
 
+var literalTypeNames = map[string]string{
+	"integer_literal":    "int",
+	"real_literal":       "double",
+	"string_literal":     "String",
+	"raw_string_literal": "String",
+	"char_literal":       "char",
+	"true":               "bool",
+	"false":              "bool",
+}
+
+// LiteralType returns the type of a literal without suffix, None when node is not a literal.
+func LiteralType(node *sitter.Node) option.Option[TypeInfo] {
+	name, ok := literalTypeNames[node.Type()]
+	if !ok {
+		return option.None[TypeInfo]()
+	}
+
+	builder := NewTypeInfoBuilder().WithName(name)
+	// String is declared by the stdlib.
+	if name != "String" {
+		builder.IsBuiltin()
+	}
+	start, end := node.StartPoint(), node.EndPoint()
+	builder.WithStartEnd(uint(start.Row), uint(start.Column), uint(end.Row), uint(end.Column))
+
+	return option.Some(builder.Build())
+}
+
 func typeNodeToType(node *sitter.Node, sourceCode []byte) TypeInfo {
 	if node.Type() == "optional_type" {
 		return extTypeNodeToType(node.Child(0), true, sourceCode)
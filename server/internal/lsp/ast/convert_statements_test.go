@@ -3,6 +3,7 @@ package ast
 import (
 	"testing"
 
+	sitter "github.com/smacker/go-tree-sitter"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -94,3 +95,41 @@ func TestConvertToAST_function_statements_call_chain(t *testing.T) {
 
 	ConvertToAST(GetCST(source), source, "file.c3")
 }
+
+func TestLiteralType(t *testing.T) {
+	cases := []struct {
+		literal  string
+		expected string
+		builtIn  bool
+	}{
+		{"1", "int", true},
+		{"1.1", "double", true},
+		{"'a'", "char", true},
+		{"true", "bool", true},
+		{"\"hello\"", "String", false},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.literal, func(t *testing.T) {
+			source := "module foo;\nint var = " + tt.literal + ";"
+			root := GetCST(source)
+			literal := root.NamedDescendantForPointRange(sitter.Point{Row: 1, Column: 10}, sitter.Point{Row: 1, Column: 10})
+
+			literalType := LiteralType(literal)
+
+			assert.True(t, literalType.IsSome())
+			assert.Equal(t, tt.expected, literalType.Get().Identifier.Name)
+			assert.Equal(t, tt.builtIn, literalType.Get().BuiltIn)
+		})
+	}
+}
+
+func TestLiteralType_of_other_nodes(t *testing.T) {
+	source := "module foo;\nint var = other;"
+	root := GetCST(source)
+	identifier := root.NamedDescendantForPointRange(sitter.Point{Row: 1, Column: 10}, sitter.Point{Row: 1, Column: 10})
+
+	literalType := LiteralType(identifier)
+
+	assert.True(t, literalType.IsNone())
+}
@@ -0,0 +1,112 @@
+package ast
+
+import (
+	"github.com/pherrymason/c3-lsp/pkg/option"
+	"github.com/pherrymason/c3-lsp/pkg/symbols"
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// SymbolResolver finds the symbol an identifier node refers to.
+type SymbolResolver interface {
+	ResolveIdentifier(identifier *sitter.Node) option.Option[symbols.Indexable]
+}
+
+var identifierTypes = map[string]bool{
+	"ident":       true,
+	"type_ident":  true,
+	"const_ident": true,
+	"ct_ident":    true,
+	"at_ident":    true,
+	"hash_ident":  true,
+}
+
+// InferExpressionType infers the type of literals, variables, member accesses and calls,
+// using resolver to find the symbols they refer to.
+func InferExpressionType(expression *sitter.Node, sourceCode []byte, resolver SymbolResolver) option.Option[symbols.Type] {
+	if literalType := LiteralType(expression); literalType.IsSome() {
+		return option.Some(symbols.NewTypeFromString(literalType.Get().Identifier.Name, ""))
+	}
+
+	if expression.Type() == "call_expr" {
+		return callReturnType(expression, resolver)
+	}
+
+	if !IsAccessChain(expression, sourceCode) {
+		return option.None[symbols.Type]()
+	}
+
+	switch symbol := ResolveLastIdentifier(expression, resolver).(type) {
+	case *symbols.Variable:
+		return option.Some(*symbol.GetType())
+	case *symbols.StructMember:
+		return option.Some(*symbol.GetType())
+	}
+
+	return option.None[symbols.Type]()
+}
+
+// callReturnType returns the type returned by the function called in callNode, None when
+// the function returns nothing or a generic argument.
+func callReturnType(callNode *sitter.Node, resolver SymbolResolver) option.Option[symbols.Type] {
+	function, ok := ResolveLastIdentifier(callNode.Child(0), resolver).(*symbols.Function)
+	if !ok {
+		return option.None[symbols.Type]()
+	}
+
+	returnType := function.GetReturnType()
+	if returnType == nil || returnType.GetName() == "" || returnType.GetName() == "void" || returnType.IsGenericArgument() {
+		return option.None[symbols.Type]()
+	}
+
+	return option.Some(*returnType)
+}
+
+// ResolveLastIdentifier finds the symbol referred by the last identifier of node, like `c` in `a.b.c`.
+func ResolveLastIdentifier(node *sitter.Node, resolver SymbolResolver) symbols.Indexable {
+	last := LastLeaf(node)
+	if !identifierTypes[last.Type()] {
+		return nil
+	}
+
+	resolved := resolver.ResolveIdentifier(last)
+	if resolved.IsNone() {
+		return nil
+	}
+
+	return resolved.Get()
+}
+
+// IsAccessChain checks node is an identifier, or a chain of them like `a.b.c`.
+func IsAccessChain(node *sitter.Node, sourceCode []byte) bool {
+	if node.ChildCount() == 0 {
+		return identifierTypes[node.Type()]
+	}
+
+	for i := 0; i < int(node.ChildCount()); i++ {
+		child := node.Child(i)
+		if child.ChildCount() == 0 && !identifierTypes[child.Type()] && child.Content(sourceCode) != "." {
+			return false
+		}
+		if child.ChildCount() > 0 && !IsAccessChain(child, sourceCode) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func FirstLeaf(node *sitter.Node) *sitter.Node {
+	for node.ChildCount() > 0 {
+		node = node.Child(0)
+	}
+
+	return node
+}
+
+func LastLeaf(node *sitter.Node) *sitter.Node {
+	for node.ChildCount() > 0 {
+		node = node.Child(int(node.ChildCount()) - 1)
+	}
+
+	return node
+}
@@ -0,0 +1,77 @@
+package ast
+
+import (
+	"testing"
+
+	"github.com/pherrymason/c3-lsp/pkg/option"
+	"github.com/pherrymason/c3-lsp/pkg/symbols"
+	sitter "github.com/smacker/go-tree-sitter"
+	"github.com/stretchr/testify/assert"
+)
+
+type resolverByName struct {
+	sourceCode []byte
+	symbols    map[string]symbols.Indexable
+}
+
+func (r resolverByName) ResolveIdentifier(identifier *sitter.Node) option.Option[symbols.Indexable] {
+	symbol, ok := r.symbols[identifier.Content(r.sourceCode)]
+	if !ok {
+		return option.None[symbols.Indexable]()
+	}
+
+	return option.Some(symbol)
+}
+
+func findInitializer(node *sitter.Node) *sitter.Node {
+	if node.Type() == "var_decl" {
+		return node.NamedChild(int(node.NamedChildCount()) - 1)
+	}
+
+	for i := 0; i < int(node.ChildCount()); i++ {
+		if initializer := findInitializer(node.Child(i)); initializer != nil {
+			return initializer
+		}
+	}
+
+	return nil
+}
+
+func TestInferExpressionType(t *testing.T) {
+	call := symbols.NewFunction("call", symbols.NewTypeFromString("Point", "foo"), nil, "foo", "file.c3", symbols.NewRange(0, 0, 0, 0), symbols.NewRange(0, 0, 0, 0))
+	nothing := symbols.NewFunction("nothing", symbols.NewTypeFromString("void", ""), nil, "foo", "file.c3", symbols.NewRange(0, 0, 0, 0), symbols.NewRange(0, 0, 0, 0))
+	member := symbols.NewStructMember("x", symbols.NewTypeFromString("float", ""), option.None[[2]uint](), "foo", "file.c3", symbols.NewRange(0, 0, 0, 0))
+	point := symbols.NewVariable("point", symbols.NewTypeFromString("Point", "foo"), "foo", "file.c3", symbols.NewRange(0, 0, 0, 0), symbols.NewRange(0, 0, 0, 0))
+
+	cases := []struct {
+		initializer string
+		expected    option.Option[string]
+	}{
+		{"1", option.Some("int")},
+		{"\"text\"", option.Some("String")},
+		{"call()", option.Some("Point")},
+		{"nothing()", option.None[string]()},
+		{"point", option.Some("Point")},
+		{"point.x", option.Some("float")},
+		{"point.x + 1", option.None[string]()},
+		{"unknown", option.None[string]()},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.initializer, func(t *testing.T) {
+			source := "module foo;\nfn void main() {\n\tvar value = " + tt.initializer + ";\n}"
+			resolver := resolverByName{
+				sourceCode: []byte(source),
+				symbols:    map[string]symbols.Indexable{"call": &call, "nothing": &nothing, "x": &member, "point": &point},
+			}
+
+			inferred := InferExpressionType(findInitializer(GetCST(source)), []byte(source), resolver)
+
+			if tt.expected.IsNone() {
+				assert.True(t, inferred.IsNone())
+			} else {
+				assert.Equal(t, tt.expected.Get(), inferred.Get().GetName())
+			}
+		})
+	}
+}
@@ -27,7 +27,7 @@ func BuildFromDocumentPosition(
 	}
 
 	doc := state.GetDocument(docURI)
-	tree := doc.ContextSyntaxTree
+	tree := doc.SyntaxTree()
 	root := tree.RootNode()
 
 	// Search sitter.Node where cursor is currently
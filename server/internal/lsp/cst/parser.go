@@ -28,6 +28,17 @@ func GetParsedTreeFromString(source string) *sitter.Tree {
 	return n
 }
 
+// ReparseTree parses source reusing the nodes of oldTree, which must have been
+// edited with the changes made to its source. A nil oldTree parses from scratch.
+//
+// The go-tree-sitter binding forwards OldEndPoint as the new end point of edits.
+// This only affects the nodes touched by the edit, which are parsed again anyway.
+func ReparseTree(oldTree *sitter.Tree, source string) *sitter.Tree {
+	parser := NewSitterParser()
+
+	return parser.Parse(oldTree, []byte(source))
+}
+
 func RunQuery(query string, node *sitter.Node) *sitter.QueryCursor {
 	q, err := sitter.NewQuery([]byte(query), GetLanguage())
 	if err != nil {
@@ -0,0 +1,34 @@
+package diagnostics
+
+import (
+	"strings"
+	"unicode/utf16"
+
+	"github.com/pherrymason/c3-lsp/pkg/symbols"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// sourceLines converts the byte columns of tree-sitter to the UTF-16 columns of LSP, counting
+// them in the lines of the source code.
+type sourceLines []string
+
+func newSourceLines(sourceCode string) sourceLines {
+	return strings.Split(sourceCode, "\n")
+}
+
+func (l sourceLines) lspRange(r symbols.Range) protocol.Range {
+	return protocol.Range{
+		Start: l.lspPosition(r.Start),
+		End:   l.lspPosition(r.End),
+	}
+}
+
+func (l sourceLines) lspPosition(position symbols.Position) protocol.Position {
+	column := position.Character
+	if position.Line < uint(len(l)) {
+		line := l[position.Line]
+		column = uint(len(utf16.Encode([]rune(line[:min(int(column), len(line))]))))
+	}
+
+	return protocol.Position{Line: protocol.UInteger(position.Line), Character: protocol.UInteger(column)}
+}
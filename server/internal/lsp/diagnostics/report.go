@@ -0,0 +1,438 @@
+package diagnostics
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/pherrymason/c3-lsp/pkg/cast"
+	"github.com/pherrymason/c3-lsp/pkg/fs"
+	"github.com/pherrymason/c3-lsp/pkg/option"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// Formats in which a Report is written.
+const (
+	FormatText  = "text"
+	FormatJSON  = "json"
+	FormatSARIF = "sarif"
+)
+
+// reportVersion is increased when the JSON format of reports changes in an incompatible way.
+const reportVersion = 1
+
+// Codes of the diagnostics without one in reports: syntax errors found by tree-sitter,
+// and errors and warnings reported by c3c.
+const (
+	codeSyntaxError = "syntax-error"
+	codeCompiler    = "c3c"
+)
+
+const informationURI = "https://github.com/pherrymason/c3-lsp"
+
+// Report holds the diagnostics of a workspace, to export them to tools other than editors.
+type Report struct {
+	ToolName    string
+	ToolVersion string
+	// Paths of files are written relative to Root.
+	Root  string
+	Files []FileDiagnostics
+}
+
+// FileDiagnostics are the diagnostics found in a file. Their columns are counted in UTF-16
+// code units, like LSP does.
+type FileDiagnostics struct {
+	Path        string
+	Diagnostics []protocol.Diagnostic
+}
+
+func IsValidFormat(format string) bool {
+	return format == FormatText || format == FormatJSON || format == FormatSARIF
+}
+
+// Write writes the report in the given format.
+func (r Report) Write(w io.Writer, format string) error {
+	switch format {
+	case FormatJSON:
+		return r.WriteJSON(w)
+	case FormatSARIF:
+		return r.WriteSARIF(w)
+	case FormatText:
+		return r.WriteText(w)
+	}
+
+	return fmt.Errorf("unknown format %q", format)
+}
+
+// HasSeverity tells if a diagnostic is at least as severe as severity.
+func (r Report) HasSeverity(severity protocol.DiagnosticSeverity) bool {
+	for _, file := range r.Files {
+		for _, diagnostic := range file.Diagnostics {
+			if diagnosticSeverity(diagnostic) <= severity {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// WriteText writes a line per diagnostic, like compilers do: "file:line:column: severity: message".
+// Lines and columns start at 1.
+func (r Report) WriteText(w io.Writer) error {
+	for _, file := range r.sortedFiles() {
+		for _, diagnostic := range file.Diagnostics {
+			line := fmt.Sprintf("%s:%d:%d: %s: %s",
+				r.relativePath(file.Path),
+				diagnostic.Range.Start.Line+1,
+				diagnostic.Range.Start.Character+1,
+				severityName(diagnosticSeverity(diagnostic)),
+				diagnostic.Message,
+			)
+			if code := diagnosticCode(diagnostic); code != "" {
+				line += " [" + code + "]"
+			}
+			if _, err := fmt.Fprintln(w, line); err != nil {
+				return err
+			}
+
+			for _, related := range diagnostic.RelatedInformation {
+				_, err := fmt.Fprintf(w, "%s:%d:%d: note: %s\n",
+					r.relativePath(locationPath(related.Location.URI)),
+					related.Location.Range.Start.Line+1,
+					related.Location.Range.Start.Character+1,
+					related.Message,
+				)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+type jsonReport struct {
+	Version int        `json:"version"`
+	Tool    jsonTool   `json:"tool"`
+	Files   []jsonFile `json:"files"`
+}
+
+type jsonTool struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type jsonFile struct {
+	Path        string           `json:"path"`
+	Diagnostics []jsonDiagnostic `json:"diagnostics"`
+}
+
+type jsonDiagnostic struct {
+	Severity string       `json:"severity"`
+	Code     string       `json:"code"`
+	Source   string       `json:"source"`
+	Message  string       `json:"message"`
+	Start    jsonPosition `json:"start"`
+	End      jsonPosition `json:"end"`
+	// Related are the notes of the diagnostic, elsewhere in the code.
+	Related []jsonRelated `json:"related"`
+}
+
+type jsonRelated struct {
+	Path    string       `json:"path"`
+	Message string       `json:"message"`
+	Start   jsonPosition `json:"start"`
+	End     jsonPosition `json:"end"`
+}
+
+// jsonPosition starts at line 1 and column 1. Columns are counted in UTF-16 units, like in LSP.
+type jsonPosition struct {
+	Line   protocol.UInteger `json:"line"`
+	Column protocol.UInteger `json:"column"`
+}
+
+func newJSONPosition(position protocol.Position) jsonPosition {
+	return jsonPosition{Line: position.Line + 1, Column: position.Character + 1}
+}
+
+// WriteJSON writes the report as JSON. Its format only changes in compatible ways while its
+// "version" stays the same.
+func (r Report) WriteJSON(w io.Writer) error {
+	report := jsonReport{
+		Version: reportVersion,
+		Tool:    jsonTool{Name: r.ToolName, Version: r.ToolVersion},
+		Files:   []jsonFile{},
+	}
+	for _, file := range r.sortedFiles() {
+		exported := jsonFile{Path: r.relativePath(file.Path), Diagnostics: []jsonDiagnostic{}}
+		for _, diagnostic := range file.Diagnostics {
+			related := []jsonRelated{}
+			for _, information := range diagnostic.RelatedInformation {
+				related = append(related, jsonRelated{
+					Path:    r.relativePath(locationPath(information.Location.URI)),
+					Message: information.Message,
+					Start:   newJSONPosition(information.Location.Range.Start),
+					End:     newJSONPosition(information.Location.Range.End),
+				})
+			}
+
+			exported.Diagnostics = append(exported.Diagnostics, jsonDiagnostic{
+				Severity: severityName(diagnosticSeverity(diagnostic)),
+				Code:     diagnosticCode(diagnostic),
+				Source:   diagnosticSource(diagnostic),
+				Message:  diagnostic.Message,
+				Start:    newJSONPosition(diagnostic.Range.Start),
+				End:      newJSONPosition(diagnostic.Range.End),
+				Related:  related,
+			})
+		}
+		report.Files = append(report.Files, exported)
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool               sarifTool                   `json:"tool"`
+	OriginalURIBaseIDs map[string]sarifArtifactURI `json:"originalUriBaseIds,omitempty"`
+	Results            []sarifResult               `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version,omitempty"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID string `json:"id"`
+}
+
+type sarifResult struct {
+	RuleID           string          `json:"ruleId"`
+	Level            string          `json:"level"`
+	Message          sarifMessage    `json:"message"`
+	Locations        []sarifLocation `json:"locations"`
+	RelatedLocations []sarifLocation `json:"relatedLocations,omitempty"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	ID               *int                  `json:"id,omitempty"`
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+	Message          *sarifMessage         `json:"message,omitempty"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactURI `json:"artifactLocation"`
+	Region           sarifRegion      `json:"region"`
+}
+
+type sarifArtifactURI struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId,omitempty"`
+}
+
+// sarifRegion columns are counted in UTF-16 units, the default of SARIF.
+type sarifRegion struct {
+	StartLine   protocol.UInteger `json:"startLine"`
+	StartColumn protocol.UInteger `json:"startColumn"`
+	EndLine     protocol.UInteger `json:"endLine"`
+	EndColumn   protocol.UInteger `json:"endColumn"`
+}
+
+func newSARIFRegion(lspRange protocol.Range) sarifRegion {
+	return sarifRegion{
+		StartLine:   lspRange.Start.Line + 1,
+		StartColumn: lspRange.Start.Character + 1,
+		EndLine:     lspRange.End.Line + 1,
+		EndColumn:   lspRange.End.Character + 1,
+	}
+}
+
+// sarifRootID is the base of the paths relative to the root of the report.
+const sarifRootID = "SRCROOT"
+
+// WriteSARIF writes the report in SARIF 2.1.0, understood by code scanning services.
+func (r Report) WriteSARIF(w io.Writer) error {
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           r.ToolName,
+			Version:        r.ToolVersion,
+			InformationURI: informationURI,
+			Rules:          []sarifRule{},
+		}},
+		Results: []sarifResult{},
+	}
+	if r.Root != "" {
+		run.OriginalURIBaseIDs = map[string]sarifArtifactURI{
+			sarifRootID: {URI: strings.TrimSuffix(fs.ConvertPathToURI(r.Root, option.None[string]()), "/") + "/"},
+		}
+	}
+
+	rules := map[string]bool{}
+	for _, file := range r.sortedFiles() {
+		location := r.sarifArtifact(file.Path)
+		for _, diagnostic := range file.Diagnostics {
+			ruleID := diagnosticCode(diagnostic)
+			if !rules[ruleID] {
+				rules[ruleID] = true
+				run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{ID: ruleID})
+			}
+
+			result := sarifResult{
+				RuleID:  ruleID,
+				Level:   sarifLevel(diagnosticSeverity(diagnostic)),
+				Message: sarifMessage{Text: diagnostic.Message},
+				Locations: []sarifLocation{{PhysicalLocation: sarifPhysicalLocation{
+					ArtifactLocation: location,
+					Region:           newSARIFRegion(diagnostic.Range),
+				}}},
+			}
+			for i, information := range diagnostic.RelatedInformation {
+				result.RelatedLocations = append(result.RelatedLocations, sarifLocation{
+					ID: cast.ToPtr(i),
+					PhysicalLocation: sarifPhysicalLocation{
+						ArtifactLocation: r.sarifArtifact(locationPath(information.Location.URI)),
+						Region:           newSARIFRegion(information.Location.Range),
+					},
+					Message: &sarifMessage{Text: information.Message},
+				})
+			}
+			run.Results = append(run.Results, result)
+		}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{run},
+	})
+}
+
+// sarifArtifact locates a file relative to the root when it is inside it.
+func (r Report) sarifArtifact(path string) sarifArtifactURI {
+	relative := r.relativePath(path)
+	if relative == path {
+		return sarifArtifactURI{URI: fs.ConvertPathToURI(path, option.None[string]())}
+	}
+
+	segments := strings.Split(filepath.ToSlash(relative), "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+
+	return sarifArtifactURI{URI: strings.Join(segments, "/"), URIBaseID: sarifRootID}
+}
+
+// relativePath returns path relative to the root, or path itself when it is outside of it.
+func (r Report) relativePath(path string) string {
+	if r.Root == "" {
+		return path
+	}
+
+	relative, err := filepath.Rel(r.Root, path)
+	if err != nil || relative == ".." || strings.HasPrefix(relative, ".."+string(filepath.Separator)) {
+		return path
+	}
+
+	return relative
+}
+
+func (r Report) sortedFiles() []FileDiagnostics {
+	files := slices.Clone(r.Files)
+	slices.SortFunc(files, func(a, b FileDiagnostics) int { return strings.Compare(a.Path, b.Path) })
+
+	return files
+}
+
+// diagnosticSeverity returns the severity of the diagnostic. Clients take those without one as errors.
+func diagnosticSeverity(diagnostic protocol.Diagnostic) protocol.DiagnosticSeverity {
+	if diagnostic.Severity == nil {
+		return protocol.DiagnosticSeverityError
+	}
+
+	return *diagnostic.Severity
+}
+
+func diagnosticCode(diagnostic protocol.Diagnostic) string {
+	if diagnostic.Code != nil {
+		return fmt.Sprint(diagnostic.Code.Value)
+	}
+
+	source := diagnosticSource(diagnostic)
+	switch {
+	case source == Source:
+		return codeSyntaxError
+	case strings.HasPrefix(source, codeCompiler):
+		return codeCompiler
+	}
+
+	return source
+}
+
+// locationPath returns the path of the file at uri, or uri itself when it is not a file.
+func locationPath(uri protocol.DocumentUri) string {
+	path, err := fs.UriToPath(uri)
+	if err != nil {
+		return uri
+	}
+
+	return path
+}
+
+func diagnosticSource(diagnostic protocol.Diagnostic) string {
+	if diagnostic.Source == nil {
+		return ""
+	}
+
+	return *diagnostic.Source
+}
+
+func severityName(severity protocol.DiagnosticSeverity) string {
+	switch severity {
+	case protocol.DiagnosticSeverityWarning:
+		return string(SeverityWarning)
+	case protocol.DiagnosticSeverityInformation:
+		return string(SeverityInformation)
+	case protocol.DiagnosticSeverityHint:
+		return string(SeverityHint)
+	}
+
+	return string(SeverityError)
+}
+
+func sarifLevel(severity protocol.DiagnosticSeverity) string {
+	switch severity {
+	case protocol.DiagnosticSeverityError:
+		return "error"
+	case protocol.DiagnosticSeverityWarning:
+		return "warning"
+	}
+
+	return "note"
+}
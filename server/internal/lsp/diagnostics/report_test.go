@@ -0,0 +1,164 @@
+package diagnostics
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/pherrymason/c3-lsp/internal/lsp/search"
+	"github.com/pherrymason/c3-lsp/pkg/cast"
+	"github.com/pherrymason/c3-lsp/pkg/symbols"
+	"github.com/stretchr/testify/assert"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func testReport() Report {
+	return Report{
+		ToolName:    "c3-lsp",
+		ToolVersion: "0.3.2",
+		Root:        "/project",
+		Files: []FileDiagnostics{
+			{
+				Path: "/project/src/main.c3",
+				Diagnostics: []protocol.Diagnostic{{
+					Range:    protocol.Range{Start: protocol.Position{Line: 2, Character: 4}, End: protocol.Position{Line: 2, Character: 5}},
+					Severity: cast.ToPtr(protocol.DiagnosticSeverityWarning),
+					Code:     &protocol.IntegerOrString{Value: search.ProblemUnusedVariable},
+					Source:   cast.ToPtr(Source),
+					Message:  "Variable 'x' is never used",
+				}},
+			},
+			{
+				Path: "/project/src/app.c3",
+				Diagnostics: []protocol.Diagnostic{{
+					Range:    protocol.Range{Start: protocol.Position{Line: 0, Character: 10}, End: protocol.Position{Line: 0, Character: 11}},
+					Severity: cast.ToPtr(protocol.DiagnosticSeverityError),
+					Source:   cast.ToPtr(Source),
+					Message:  "Missing ';'",
+				}},
+			},
+		},
+	}
+}
+
+func TestReport_WriteText_writes_a_line_per_diagnostic(t *testing.T) {
+	var output bytes.Buffer
+
+	assert.Nil(t, testReport().WriteText(&output))
+
+	assert.Equal(t, "src/app.c3:1:11: error: Missing ';' [syntax-error]\n"+
+		"src/main.c3:3:5: warning: Variable 'x' is never used [unused-variable]\n", output.String())
+}
+
+func TestReport_WriteJSON_counts_lines_and_columns_from_one(t *testing.T) {
+	var output bytes.Buffer
+
+	assert.Nil(t, testReport().WriteJSON(&output))
+
+	var report jsonReport
+	assert.Nil(t, json.Unmarshal(output.Bytes(), &report))
+	assert.Equal(t, reportVersion, report.Version)
+	assert.Equal(t, "src/main.c3", report.Files[1].Path)
+	assert.Equal(t, jsonDiagnostic{
+		Severity: "warning",
+		Code:     search.ProblemUnusedVariable,
+		Source:   Source,
+		Message:  "Variable 'x' is never used",
+		Start:    jsonPosition{Line: 3, Column: 5},
+		End:      jsonPosition{Line: 3, Column: 6},
+		Related:  []jsonRelated{},
+	}, report.Files[1].Diagnostics[0])
+}
+
+func TestReport_WriteSARIF_locates_results_relative_to_the_root(t *testing.T) {
+	var output bytes.Buffer
+
+	assert.Nil(t, testReport().WriteSARIF(&output))
+
+	var log sarifLog
+	assert.Nil(t, json.Unmarshal(output.Bytes(), &log))
+	assert.Equal(t, "2.1.0", log.Version)
+	run := log.Runs[0]
+	assert.Equal(t, "file:///project/", run.OriginalURIBaseIDs[sarifRootID].URI)
+	assert.Equal(t, []sarifRule{{ID: codeSyntaxError}, {ID: search.ProblemUnusedVariable}}, run.Tool.Driver.Rules)
+	assert.Equal(t, "warning", run.Results[1].Level)
+	assert.Equal(t, sarifArtifactURI{URI: "src/main.c3", URIBaseID: sarifRootID}, run.Results[1].Locations[0].PhysicalLocation.ArtifactLocation)
+	assert.Equal(t, sarifRegion{StartLine: 3, StartColumn: 5, EndLine: 3, EndColumn: 6}, run.Results[1].Locations[0].PhysicalLocation.Region)
+}
+
+func TestReport_HasSeverity(t *testing.T) {
+	report := testReport()
+	report.Files = report.Files[:1]
+
+	assert.False(t, report.HasSeverity(protocol.DiagnosticSeverityError))
+	assert.True(t, report.HasSeverity(protocol.DiagnosticSeverityWarning))
+}
+
+func compilerReport() Report {
+	return Report{
+		ToolName: "c3-lsp",
+		Root:     "/project",
+		Files: []FileDiagnostics{{
+			Path: "/project/src/main.c3",
+			Diagnostics: []protocol.Diagnostic{{
+				Range:    protocol.Range{Start: protocol.Position{Line: 4, Character: 1}, End: protocol.Position{Line: 4, Character: 4}},
+				Severity: cast.ToPtr(protocol.DiagnosticSeverityError),
+				Source:   cast.ToPtr("c3c build --test"),
+				Message:  "'foo' could not be found.",
+				RelatedInformation: []protocol.DiagnosticRelatedInformation{{
+					Location: protocol.Location{
+						URI:   "file:///project/src/foo.c3",
+						Range: protocol.Range{Start: protocol.Position{Line: 0, Character: 0}, End: protocol.Position{Line: 0, Character: 3}},
+					},
+					Message: "Did you mean 'foo2'?",
+				}},
+			}},
+		}},
+	}
+}
+
+func TestReport_WriteText_writes_notes_of_compiler_diagnostics(t *testing.T) {
+	var output bytes.Buffer
+
+	assert.Nil(t, compilerReport().WriteText(&output))
+
+	assert.Equal(t, "src/main.c3:5:2: error: 'foo' could not be found. [c3c]\n"+
+		"src/foo.c3:1:1: note: Did you mean 'foo2'?\n", output.String())
+}
+
+func TestReport_WriteSARIF_reports_notes_as_related_locations(t *testing.T) {
+	var output bytes.Buffer
+
+	assert.Nil(t, compilerReport().WriteSARIF(&output))
+
+	var log sarifLog
+	assert.Nil(t, json.Unmarshal(output.Bytes(), &log))
+	result := log.Runs[0].Results[0]
+	assert.Equal(t, codeCompiler, result.RuleID)
+	assert.Equal(t, 1, len(result.RelatedLocations))
+	assert.Equal(t, "Did you mean 'foo2'?", result.RelatedLocations[0].Message.Text)
+	assert.Equal(t, sarifArtifactURI{URI: "src/foo.c3", URIBaseID: sarifRootID}, result.RelatedLocations[0].PhysicalLocation.ArtifactLocation)
+}
+
+func TestReport_WriteJSON_counts_columns_of_problems_in_utf16_code_units(t *testing.T) {
+	source := "module app;\nfn void main()\n{\n\tString s = \"é😀\"; int x;\n}\n"
+	// "é" is 2 bytes and 1 code unit, "😀" is 4 bytes and 2 code units.
+	problems := []search.Problem{
+		{Code: search.ProblemUnusedVariable, Range: symbols.NewRange(3, 26, 3, 27), Message: "Variable 'x' is never used"},
+	}
+	report := Report{
+		ToolName: "c3-lsp",
+		Root:     "/project",
+		Files: []FileDiagnostics{
+			{Path: "/project/src/main.c3", Diagnostics: Problems(problems, DefaultChecks(), source)},
+		},
+	}
+	var output bytes.Buffer
+
+	assert.Nil(t, report.WriteJSON(&output))
+
+	var written jsonReport
+	assert.Nil(t, json.Unmarshal(output.Bytes(), &written))
+	assert.Equal(t, jsonPosition{Line: 4, Column: 24}, written.Files[0].Diagnostics[0].Start)
+	assert.Equal(t, jsonPosition{Line: 4, Column: 25}, written.Files[0].Diagnostics[0].End)
+}
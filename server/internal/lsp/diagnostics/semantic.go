@@ -0,0 +1,92 @@
+package diagnostics
+
+import (
+	"github.com/pherrymason/c3-lsp/internal/lsp/search"
+	"github.com/pherrymason/c3-lsp/pkg/cast"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// Severity of the diagnostics reported by a check. SeverityOff disables the check.
+type Severity string
+
+const (
+	SeverityOff         Severity = "off"
+	SeverityError       Severity = "error"
+	SeverityWarning     Severity = "warning"
+	SeverityInformation Severity = "information"
+	SeverityHint        Severity = "hint"
+)
+
+// DefaultChecks returns the severity of each semantic check when it is not configured.
+func DefaultChecks() map[string]Severity {
+	return map[string]Severity{
+		search.ProblemUnresolvedIdentifier: SeverityHint,
+		search.ProblemUnknownModule:        SeverityError,
+		search.ProblemUnusedImport:         SeverityWarning,
+		search.ProblemUnusedVariable:       SeverityWarning,
+		search.ProblemUnusedFunction:       SeverityWarning,
+	}
+}
+
+func IsValidSeverity(severity string) bool {
+	switch Severity(severity) {
+	case SeverityOff, SeverityError, SeverityWarning, SeverityInformation, SeverityHint:
+		return true
+	}
+
+	return false
+}
+
+func IsKnownCheck(code string) bool {
+	_, ok := DefaultChecks()[code]
+	return ok
+}
+
+// Problems converts the problems found by the semantic analysis to diagnostics, with the
+// severity configured in checks. Problems of disabled checks are left out. Their columns are
+// counted in UTF-16 code units of sourceCode, the text of the document where they were found.
+func Problems(problems []search.Problem, checks map[string]Severity, sourceCode string) []protocol.Diagnostic {
+	diagnostics := []protocol.Diagnostic{}
+	lines := newSourceLines(sourceCode)
+	for _, problem := range problems {
+		severity, ok := checks[problem.Code]
+		if !ok {
+			severity = DefaultChecks()[problem.Code]
+		}
+		if severity == SeverityOff || severity == "" {
+			continue
+		}
+
+		diagnostic := protocol.Diagnostic{
+			Range:    lines.lspRange(problem.Range),
+			Severity: cast.ToPtr(severity.toLSP()),
+			Code:     &protocol.IntegerOrString{Value: problem.Code},
+			Source:   cast.ToPtr(Source),
+			Message:  problem.Message,
+		}
+		if isUnusedCode(problem.Code) {
+			diagnostic.Tags = []protocol.DiagnosticTag{protocol.DiagnosticTagUnnecessary}
+		}
+
+		diagnostics = append(diagnostics, diagnostic)
+	}
+
+	return diagnostics
+}
+
+func (s Severity) toLSP() protocol.DiagnosticSeverity {
+	switch s {
+	case SeverityError:
+		return protocol.DiagnosticSeverityError
+	case SeverityInformation:
+		return protocol.DiagnosticSeverityInformation
+	case SeverityHint:
+		return protocol.DiagnosticSeverityHint
+	}
+
+	return protocol.DiagnosticSeverityWarning
+}
+
+func isUnusedCode(code string) bool {
+	return code == search.ProblemUnusedImport || code == search.ProblemUnusedVariable || code == search.ProblemUnusedFunction
+}
@@ -0,0 +1,40 @@
+package diagnostics
+
+import (
+	"testing"
+
+	"github.com/pherrymason/c3-lsp/internal/lsp/search"
+	"github.com/pherrymason/c3-lsp/pkg/symbols"
+	"github.com/stretchr/testify/assert"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func TestProblems_uses_configured_severity(t *testing.T) {
+	problems := []search.Problem{
+		{Code: search.ProblemUnusedVariable, Range: symbols.NewRange(1, 2, 1, 5), Message: "Variable 'x' is never used"},
+		{Code: search.ProblemUnresolvedIdentifier, Range: symbols.NewRange(2, 2, 2, 5), Message: "'y' could not be found"},
+		{Code: search.ProblemUnusedImport, Range: symbols.NewRange(0, 7, 0, 9), Message: "Module 'io' is imported but never used"},
+	}
+	checks := DefaultChecks()
+	checks[search.ProblemUnresolvedIdentifier] = SeverityError
+	checks[search.ProblemUnusedImport] = SeverityOff
+
+	diagnostics := Problems(problems, checks, "")
+
+	assert.Equal(t, 2, len(diagnostics))
+	assert.Equal(t, protocol.DiagnosticSeverityWarning, *diagnostics[0].Severity)
+	assert.Equal(t, search.ProblemUnusedVariable, diagnostics[0].Code.Value)
+	assert.Equal(t, []protocol.DiagnosticTag{protocol.DiagnosticTagUnnecessary}, diagnostics[0].Tags)
+	assert.Equal(t, protocol.DiagnosticSeverityError, *diagnostics[1].Severity)
+	assert.Empty(t, diagnostics[1].Tags)
+}
+
+func TestProblems_reports_unresolved_identifiers_as_hints_by_default(t *testing.T) {
+	problems := []search.Problem{
+		{Code: search.ProblemUnresolvedIdentifier, Range: symbols.NewRange(2, 2, 2, 5), Message: "'y' could not be found"},
+	}
+
+	diagnostics := Problems(problems, map[string]Severity{}, "")
+
+	assert.Equal(t, protocol.DiagnosticSeverityHint, *diagnostics[0].Severity)
+}
@@ -0,0 +1,83 @@
+package diagnostics
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pherrymason/c3-lsp/pkg/cast"
+	"github.com/pherrymason/c3-lsp/pkg/symbols"
+	sitter "github.com/smacker/go-tree-sitter"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// Source identifies the diagnostics found by the language server itself, without c3c.
+const Source = "c3-lsp"
+
+// Longest text of an unexpected node quoted in a message.
+const maxQuotedLength = 30
+
+// SyntaxErrors reports the ERROR and MISSING nodes found by tree-sitter while parsing a document.
+func SyntaxErrors(root *sitter.Node, sourceCode []byte) []protocol.Diagnostic {
+	diagnostics := []protocol.Diagnostic{}
+	if root == nil || !root.HasError() {
+		return diagnostics
+	}
+
+	lines := newSourceLines(string(sourceCode))
+	var walk func(node *sitter.Node)
+	walk = func(node *sitter.Node) {
+		switch {
+		case node.IsMissing():
+			diagnostics = append(diagnostics, syntaxDiagnostic(node, lines, missingMessage(node)))
+			return
+
+		case node.IsError():
+			// Nodes inside an error only repeat it.
+			diagnostics = append(diagnostics, syntaxDiagnostic(node, lines, unexpectedMessage(node, sourceCode)))
+			return
+
+		case !node.HasError():
+			return
+		}
+
+		for i := 0; i < int(node.ChildCount()); i++ {
+			walk(node.Child(i))
+		}
+	}
+	walk(root)
+
+	return diagnostics
+}
+
+func syntaxDiagnostic(node *sitter.Node, lines sourceLines, message string) protocol.Diagnostic {
+	return protocol.Diagnostic{
+		Range:    lines.lspRange(symbols.NewRangeFromTreeSitterPositions(node.StartPoint(), node.EndPoint())),
+		Severity: cast.ToPtr(protocol.DiagnosticSeverityError),
+		Source:   cast.ToPtr(Source),
+		Message:  message,
+	}
+}
+
+func missingMessage(node *sitter.Node) string {
+	if node.IsNamed() {
+		return "Missing " + strings.ReplaceAll(node.Type(), "_", " ")
+	}
+
+	return fmt.Sprintf("Missing '%s'", node.Type())
+}
+
+func unexpectedMessage(node *sitter.Node, sourceCode []byte) string {
+	text := strings.TrimSpace(node.Content(sourceCode))
+	if text == "" {
+		return "Syntax error"
+	}
+
+	if index := strings.IndexAny(text, "\r\n"); index >= 0 {
+		text = strings.TrimSpace(text[:index]) + "..."
+	}
+	if runes := []rune(text); len(runes) > maxQuotedLength {
+		text = string(runes[:maxQuotedLength]) + "..."
+	}
+
+	return fmt.Sprintf("Unexpected '%s'", text)
+}
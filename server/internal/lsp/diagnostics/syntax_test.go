@@ -0,0 +1,58 @@
+package diagnostics
+
+import (
+	"testing"
+
+	"github.com/pherrymason/c3-lsp/internal/lsp/cst"
+	"github.com/stretchr/testify/assert"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func TestSyntaxErrors_returns_nothing_for_valid_source(t *testing.T) {
+	source := "module app;\nfn void main()\n{\n\tint x = 1;\n}\n"
+	tree := cst.GetParsedTreeFromString(source)
+
+	assert.Empty(t, SyntaxErrors(tree.RootNode(), []byte(source)))
+}
+
+func TestSyntaxErrors_reports_missing_nodes(t *testing.T) {
+	source := "module app;\nfn void main()\n{\n\tint x = 1\n}\n"
+	tree := cst.GetParsedTreeFromString(source)
+
+	diagnostics := SyntaxErrors(tree.RootNode(), []byte(source))
+
+	assert.Len(t, diagnostics, 1)
+	assert.Equal(t, "Missing ';'", diagnostics[0].Message)
+	assert.Equal(t, Source, *diagnostics[0].Source)
+	assert.Equal(t, protocol.DiagnosticSeverityError, *diagnostics[0].Severity)
+	assert.Equal(t, protocol.Range{
+		Start: protocol.Position{Line: 3, Character: 10},
+		End:   protocol.Position{Line: 3, Character: 10},
+	}, diagnostics[0].Range)
+}
+
+func TestSyntaxErrors_reports_unexpected_nodes(t *testing.T) {
+	source := "module app;\n)\n"
+	tree := cst.GetParsedTreeFromString(source)
+
+	diagnostics := SyntaxErrors(tree.RootNode(), []byte(source))
+
+	assert.Len(t, diagnostics, 1)
+	assert.Equal(t, "Unexpected ')'", diagnostics[0].Message)
+	assert.Equal(t, protocol.Range{
+		Start: protocol.Position{Line: 1, Character: 0},
+		End:   protocol.Position{Line: 1, Character: 1},
+	}, diagnostics[0].Range)
+}
+
+func TestSyntaxErrors_counts_columns_in_utf16_code_units(t *testing.T) {
+	source := "module app;\nfn void main()\n{\n\tString s = \"é😀\"\n}\n"
+	tree := cst.GetParsedTreeFromString(source)
+
+	diagnostics := SyntaxErrors(tree.RootNode(), []byte(source))
+
+	// "é" is 2 bytes and 1 code unit, "😀" is 4 bytes and 2 code units.
+	assert.Len(t, diagnostics, 1)
+	assert.Equal(t, "Missing ';'", diagnostics[0].Message)
+	assert.Equal(t, protocol.Position{Line: 3, Character: 17}, diagnostics[0].Range.Start)
+}
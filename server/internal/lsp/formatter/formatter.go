@@ -0,0 +1,204 @@
+package formatter
+
+import (
+	"errors"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/pherrymason/c3-lsp/internal/lsp/cst"
+	"github.com/pherrymason/c3-lsp/pkg/symbols"
+)
+
+var (
+	ErrSyntaxErrors      = errors.New("source has syntax errors")
+	ErrUnsupportedSource = errors.New("source can not be formatted safely")
+)
+
+// Edit replaces the text inside Range by NewText.
+type Edit struct {
+	Range   symbols.Range
+	NewText string
+}
+
+// Format reprints the source following options. Only whitespace between tokens is
+// changed: comments are kept, and formatting already formatted source returns it unchanged.
+// Sources with syntax errors are not formatted.
+func Format(source string, options Options) (string, error) {
+	p, lines, err := layoutSource(source, options)
+	if err != nil {
+		return "", err
+	}
+	if len(p.tokens) == 0 {
+		return source, nil
+	}
+
+	formatted := p.render(lines)
+	if err := verify(p.tokens, formatted); err != nil {
+		return "", err
+	}
+
+	return formatted, nil
+}
+
+// FormatRange formats the lines from startLine to endLine, extending them to whole
+// statements when a statement spans several lines.
+func FormatRange(source string, startLine uint, endLine uint, options Options) ([]Edit, error) {
+	p, lines, err := layoutSource(source, options)
+	if err != nil {
+		return nil, err
+	}
+	if err := verify(p.tokens, p.render(lines)); err != nil {
+		return nil, err
+	}
+
+	first, last := -1, -1
+	for i, tok := range p.tokens {
+		if uint(tok.startRow) >= startLine && uint(tok.startRow) <= endLine {
+			if first < 0 {
+				first = i
+			}
+			last = i
+		}
+	}
+	if first < 0 {
+		return []Edit{}, nil
+	}
+
+	lineOf := make([]int, len(p.tokens))
+	for l, ln := range lines {
+		for _, pc := range ln.pieces {
+			lineOf[pc.token] = l
+		}
+	}
+
+	// Grow the selection until it covers whole lines both in the source and in the formatted output.
+	for changed := true; changed; {
+		changed = false
+		if start := lines[lineOf[first]].pieces[0].token; start < first {
+			first, changed = start, true
+		}
+		for first > 0 && p.tokens[first-1].endRow >= p.tokens[first].startRow {
+			first, changed = first-1, true
+		}
+		pieces := lines[lineOf[last]].pieces
+		if end := pieces[len(pieces)-1].token; end > last {
+			last, changed = end, true
+		}
+		for last+1 < len(p.tokens) && p.tokens[last+1].startRow <= p.tokens[last].endRow {
+			last, changed = last+1, true
+		}
+	}
+
+	lineStarts := lineOffsets(source)
+	startRow, endRow := p.tokens[first].startRow, p.tokens[last].endRow
+	newText := p.render(lines[lineOf[first] : lineOf[last]+1])
+	editRange := symbols.NewRange(uint(startRow), 0, uint(endRow)+1, 0)
+	endOffset := len(source)
+	if int(endRow)+1 < len(lineStarts) {
+		endOffset = lineStarts[endRow+1]
+	} else {
+		newText = strings.TrimSuffix(newText, p.newline)
+		editRange.End = symbols.NewPosition(uint(endRow), utf16Length(source[lineStarts[endRow]:]))
+	}
+
+	if source[lineStarts[startRow]:endOffset] == newText {
+		return []Edit{}, nil
+	}
+
+	return []Edit{{Range: editRange, NewText: newText}}, nil
+}
+
+// DiffEdits returns a single edit turning original into formatted, leaving untouched the
+// lines they have in common at the beginning and at the end.
+func DiffEdits(original string, formatted string) []Edit {
+	if original == formatted {
+		return []Edit{}
+	}
+
+	a := strings.SplitAfter(original, "\n")
+	b := strings.SplitAfter(formatted, "\n")
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	end := symbols.NewPosition(uint(len(a)-1), utf16Length(a[len(a)-1]))
+	if suffix > 0 {
+		end = symbols.NewPosition(uint(len(a)-suffix), 0)
+	}
+
+	return []Edit{{
+		Range:   symbols.Range{Start: symbols.NewPosition(uint(prefix), 0), End: end},
+		NewText: strings.Join(b[prefix:len(b)-suffix], ""),
+	}}
+}
+
+func layoutSource(source string, options Options) (*printer, []line, error) {
+	sourceCode := []byte(source)
+	tree := cst.GetParsedTreeFromString(source)
+	root := tree.RootNode()
+	if root.HasError() {
+		return nil, nil, ErrSyntaxErrors
+	}
+
+	tokens, err := collectTokens(root, sourceCode)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	newline := "\n"
+	if strings.Contains(source, "\r\n") {
+		newline = "\r\n"
+	}
+	if options.IndentSize <= 0 {
+		options.IndentSize = DefaultOptions().IndentSize
+	}
+
+	p := newPrinter(tokens, root, options, newline)
+	lines := p.wrap(p.layout())
+	p.align(lines)
+
+	return p, lines, nil
+}
+
+// verify checks the formatted source is parsed into the same tokens as the original one.
+func verify(expected []token, formatted string) error {
+	tree := cst.GetParsedTreeFromString(formatted)
+	if tree.RootNode().HasError() {
+		return ErrUnsupportedSource
+	}
+
+	tokens, err := collectTokens(tree.RootNode(), []byte(formatted))
+	if err != nil {
+		return err
+	}
+	if len(tokens) != len(expected) {
+		return ErrUnsupportedSource
+	}
+	for i := range tokens {
+		if tokens[i].text != expected[i].text {
+			return ErrUnsupportedSource
+		}
+	}
+
+	return nil
+}
+
+func lineOffsets(source string) []int {
+	offsets := []int{0}
+	for i := 0; i < len(source); i++ {
+		if source[i] == '\n' {
+			offsets = append(offsets, i+1)
+		}
+	}
+
+	return offsets
+}
+
+func utf16Length(text string) uint {
+	return uint(len(utf16.Encode([]rune(text))))
+}
@@ -0,0 +1,242 @@
+package formatter
+
+import (
+	"testing"
+
+	"github.com/pherrymason/c3-lsp/pkg/symbols"
+	"github.com/stretchr/testify/assert"
+)
+
+func spacesOptions() Options {
+	options := DefaultOptions()
+	options.UseTabs = false
+
+	return options
+}
+
+func TestFormat_reindents_and_normalizes_spacing(t *testing.T) {
+	source := `module app;
+struct Point { int x; int y; }
+fn int add(int a,int b) { return a+b; }
+`
+
+	formatted, err := Format(source, spacesOptions())
+
+	assert.Nil(t, err)
+	assert.Equal(t, `module app;
+struct Point
+{
+    int x;
+    int y;
+}
+fn int add(int a, int b)
+{
+    return a + b;
+}
+`, formatted)
+}
+
+func TestFormat_indents_with_tabs(t *testing.T) {
+	formatted, err := Format("fn void main() {\nint x = 1;\n}", DefaultOptions())
+
+	assert.Nil(t, err)
+	assert.Equal(t, "fn void main()\n{\n\tint x = 1;\n}\n", formatted)
+}
+
+func TestFormat_preserves_comments(t *testing.T) {
+	source := `// header
+module app; // trailing
+/* block */
+fn void main()
+{
+    int x = 1;    // one
+
+
+    x++;
+}
+`
+
+	formatted, err := Format(source, spacesOptions())
+
+	assert.Nil(t, err)
+	assert.Equal(t, `// header
+module app; // trailing
+/* block */
+fn void main()
+{
+    int x = 1; // one
+
+    x++;
+}
+`, formatted)
+}
+
+func TestFormat_same_line_brace_style(t *testing.T) {
+	source := `fn void f(int a)
+{
+  if (a > 0)
+  {
+    a = 1;
+  }
+  else
+  {
+    a = 2;
+  }
+}
+`
+	options := spacesOptions()
+	options.BraceStyle = BraceStyleSameLine
+
+	formatted, err := Format(source, options)
+
+	assert.Nil(t, err)
+	assert.Equal(t, `fn void f(int a) {
+    if (a > 0) {
+        a = 1;
+    } else {
+        a = 2;
+    }
+}
+`, formatted)
+}
+
+func TestFormat_indents_switch_cases(t *testing.T) {
+	source := `fn void f(int a)
+{
+switch (a)
+{
+case 1:
+return;
+default:
+break;
+}
+}
+`
+
+	formatted, err := Format(source, spacesOptions())
+
+	assert.Nil(t, err)
+	assert.Equal(t, `fn void f(int a)
+{
+    switch (a)
+    {
+        case 1:
+            return;
+        default:
+            break;
+    }
+}
+`, formatted)
+}
+
+func TestFormat_aligns_struct_members(t *testing.T) {
+	options := spacesOptions()
+	options.AlignStructMembers = true
+
+	formatted, err := Format("struct Data { int a; double value; char* name; }", options)
+
+	assert.Nil(t, err)
+	assert.Equal(t, `struct Data
+{
+    int    a;
+    double value;
+    char*  name;
+}
+`, formatted)
+}
+
+func TestFormat_wraps_long_argument_lists(t *testing.T) {
+	options := spacesOptions()
+	options.MaxLineWidth = 30
+
+	formatted, err := Format("fn void main() { call(aaaaaaaa, bbbbbbbb, cccccccc, dddddddd); }", options)
+
+	assert.Nil(t, err)
+	assert.Equal(t, `fn void main()
+{
+    call(aaaaaaaa, bbbbbbbb,
+        cccccccc, dddddddd);
+}
+`, formatted)
+}
+
+func TestFormat_is_idempotent(t *testing.T) {
+	sources := []string{
+		`module app::data;
+import std::io;
+
+<*
+ @param [in] values
+*>
+fn int sum(int[] values) {
+    int total = 0;
+    foreach (value : values) { total += value; } // accumulate
+    return total;
+}
+
+enum Color : int { RED, GREEN, BLUE }
+
+fn void main()
+{
+    int[3] values = {
+        1,
+        2,
+        3
+    };
+    io::printfn("%d", sum(&values, -1, values.len > 2 ? 1 : 0));
+    $if $defined(Color):
+    Color c = Color.RED;
+    $endif
+}
+`,
+		`fn void long_call() { printf("a very long format string %d %d %d", first_argument, second_argument, third_argument); }`,
+	}
+
+	for _, options := range []Options{DefaultOptions(), spacesOptions()} {
+		options.MaxLineWidth = 60
+		options.AlignStructMembers = true
+		for _, source := range sources {
+			once, err := Format(source, options)
+			assert.Nil(t, err)
+
+			twice, err := Format(once, options)
+			assert.Nil(t, err)
+			assert.Equal(t, once, twice)
+		}
+	}
+}
+
+func TestFormat_refuses_sources_with_syntax_errors(t *testing.T) {
+	_, err := Format("fn void main( {", DefaultOptions())
+
+	assert.ErrorIs(t, err, ErrSyntaxErrors)
+}
+
+func TestFormatRange_only_touches_requested_lines(t *testing.T) {
+	source := `fn void main()
+{
+int x=1;
+    int y   =   2;
+}
+`
+
+	edits, err := FormatRange(source, 2, 2, spacesOptions())
+
+	assert.Nil(t, err)
+	assert.Equal(t, []Edit{{Range: symbols.NewRange(2, 0, 3, 0), NewText: "    int x = 1;\n"}}, edits)
+}
+
+func TestFormatRange_returns_no_edits_for_formatted_lines(t *testing.T) {
+	source := "fn void main()\n{\n    int x = 1;\n}\n"
+
+	edits, err := FormatRange(source, 0, 3, spacesOptions())
+
+	assert.Nil(t, err)
+	assert.Equal(t, []Edit{}, edits)
+}
+
+func TestDiffEdits_keeps_common_lines(t *testing.T) {
+	edits := DiffEdits("a\nb\nc\n", "a\nB\nc\n")
+
+	assert.Equal(t, []Edit{{Range: symbols.NewRange(1, 0, 2, 0), NewText: "B\n"}}, edits)
+}
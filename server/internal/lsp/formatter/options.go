@@ -0,0 +1,35 @@
+package formatter
+
+type BraceStyle string
+
+const (
+	// BraceStyleNextLine places the opening brace of a block on its own line, as the C3 standard library does.
+	BraceStyleNextLine BraceStyle = "next-line"
+	// BraceStyleSameLine keeps the opening brace of a block at the end of the line opening it.
+	BraceStyleSameLine BraceStyle = "same-line"
+)
+
+// Options controls how source code is laid out.
+type Options struct {
+	UseTabs    bool
+	IndentSize int
+	BraceStyle BraceStyle
+	// MaxLineWidth is the width lines are wrapped at. Zero disables wrapping.
+	MaxLineWidth int
+
+	AlignStructMembers bool
+	AlignEnumValues    bool
+}
+
+func DefaultOptions() Options {
+	return Options{
+		UseTabs:      true,
+		IndentSize:   4,
+		BraceStyle:   BraceStyleNextLine,
+		MaxLineWidth: 120,
+	}
+}
+
+func IsValidBraceStyle(style string) bool {
+	return style == string(BraceStyleNextLine) || style == string(BraceStyleSameLine)
+}
@@ -0,0 +1,624 @@
+package formatter
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+type braceKind int
+
+const (
+	braceNone braceKind = iota
+	braceInline
+	// braceBlock opens the body of a declaration or statement.
+	braceBlock
+	braceEmptyBlock
+	// braceMultiline opens an initializer list written over several lines.
+	braceMultiline
+)
+
+type groupKind int
+
+const (
+	groupParen groupKind = iota
+	groupBlock
+)
+
+type group struct {
+	kind   groupKind
+	inCase bool
+}
+
+type alignKind int
+
+const (
+	alignNone alignKind = iota
+	alignStructMember
+	alignEnumValue
+)
+
+type piece struct {
+	token int
+	space bool
+	pad   int
+}
+
+type line struct {
+	indent      int
+	blankBefore bool
+	pieces      []piece
+}
+
+var openingTokens = map[string]bool{"(": true, "[": true, "(<": true, "[<": true}
+var closingTokens = map[string]bool{")": true, "]": true, ">)": true, ">]": true}
+
+var controlKeywords = map[string]bool{
+	"if": true, "for": true, "foreach": true, "foreach_r": true, "while": true, "switch": true,
+	"catch": true, "return": true, "case": true,
+	"$if": true, "$for": true, "$foreach": true, "$switch": true, "$case": true,
+}
+
+var caseLabels = map[string]bool{"case": true, "default": true, "$case": true, "$default": true}
+var ctOpeners = map[string]bool{"$if": true, "$for": true, "$foreach": true, "$switch": true}
+var ctClosers = map[string]bool{"$endif": true, "$endfor": true, "$endforeach": true, "$endswitch": true}
+
+var binaryOperators = map[string]bool{
+	"=": true, "+=": true, "-=": true, "*=": true, "/=": true, "%=": true, "&=": true, "|=": true,
+	"^=": true, "<<=": true, ">>=": true, "==": true, "!=": true, "<": true, ">": true, "<=": true,
+	">=": true, "&&": true, "||": true, "+": true, "-": true, "*": true, "/": true, "%": true,
+	"&": true, "|": true, "^": true, "<<": true, ">>": true, "?": true, "??": true, "?:": true,
+	"=>": true, "+++": true, "&&&": true, "|||": true,
+}
+var prefixOperators = map[string]bool{"-": true, "+": true, "!": true, "~": true, "*": true, "&": true, "++": true, "--": true}
+var postfixOperators = map[string]bool{"++": true, "--": true, "!": true, "!!": true, "?": true}
+
+// multiCharTokens are used to know which characters can not be glued together without
+// changing how the source is tokenized.
+var multiCharTokens = []string{
+	"&&", "||", "++", "--", "+=", "-=", "*=", "/=", "%=", "&=", "|=", "^=", "<<=", ">>=", "==", "!=",
+	"<=", ">=", "=>", "->", "::", "...", "??", "?:", "!!", "(<", ">)", "[<", ">]", "{|", "|}", "//",
+	"/*", "*/", "<*", "*>", "+++", "&&&", "|||", "$$", "[]",
+}
+
+var mergeablePairs = func() map[string]bool {
+	pairs := map[string]bool{}
+	for _, op := range multiCharTokens {
+		for k := 1; k < len(op); k++ {
+			pairs[op[k-1:k+1]] = true
+		}
+	}
+	return pairs
+}()
+
+type printer struct {
+	options Options
+	newline string
+	tokens  []token
+	braces  []braceKind
+	anchors map[int]alignKind
+
+	// Filled while laying out tokens.
+	indents     []int  // Indentation of a line starting with the token.
+	depths      []int  // Parentheses, brackets and inline braces open around the token.
+	closesBlock []bool // Token closes a block.
+	stack       []group
+	rootInCase  bool
+	ctLevel     int
+}
+
+func newPrinter(tokens []token, root *sitter.Node, options Options, newline string) *printer {
+	p := &printer{
+		options:     options,
+		newline:     newline,
+		tokens:      tokens,
+		braces:      make([]braceKind, len(tokens)),
+		anchors:     map[int]alignKind{},
+		indents:     make([]int, len(tokens)),
+		depths:      make([]int, len(tokens)),
+		closesBlock: make([]bool, len(tokens)),
+	}
+	p.classifyBraces()
+	p.markAnchors(root)
+
+	return p
+}
+
+func (p *printer) classifyBraces() {
+	for i, tok := range p.tokens {
+		if tok.text != "{" {
+			continue
+		}
+
+		parent := tok.parentType()
+		isBody := parent == "compound_stmt" || parent == "switch_stmt" || strings.HasSuffix(parent, "_body")
+		isEmpty := i+1 < len(p.tokens) && p.tokens[i+1].text == "}"
+		switch {
+		case isBody && isEmpty:
+			p.braces[i] = braceEmptyBlock
+		case isBody:
+			p.braces[i] = braceBlock
+		case !isEmpty && i+1 < len(p.tokens) && p.tokens[i+1].newlinesBefore > 0:
+			p.braces[i] = braceMultiline
+		default:
+			p.braces[i] = braceInline
+		}
+	}
+}
+
+// markAnchors finds the tokens struct members and enum values are aligned on.
+func (p *printer) markAnchors(root *sitter.Node) {
+	if !p.options.AlignStructMembers && !p.options.AlignEnumValues {
+		return
+	}
+
+	tokenAt := map[uint32]int{}
+	for i, tok := range p.tokens {
+		tokenAt[tok.start] = i
+	}
+
+	var walk func(node *sitter.Node)
+	walk = func(node *sitter.Node) {
+		switch node.Type() {
+		case "struct_body", "bitstruct_body":
+			if !p.options.AlignStructMembers {
+				break
+			}
+			for i := 0; i < int(node.NamedChildCount()); i++ {
+				if start, ok := memberNameStart(node.NamedChild(i)); ok {
+					if t, ok := tokenAt[start]; ok {
+						p.anchors[t] = alignStructMember
+					}
+				}
+			}
+		case "enum_body":
+			if !p.options.AlignEnumValues {
+				break
+			}
+			for i := 0; i < int(node.NamedChildCount()); i++ {
+				constant := node.NamedChild(i)
+				if constant.Type() != "enum_constant" || constant.ChildCount() < 2 || constant.Child(1).Type() != "=" {
+					continue
+				}
+				if t, ok := tokenAt[constant.Child(1).StartByte()]; ok {
+					p.anchors[t] = alignEnumValue
+				}
+			}
+		}
+
+		for i := 0; i < int(node.ChildCount()); i++ {
+			walk(node.Child(i))
+		}
+	}
+	walk(root)
+}
+
+// memberNameStart returns where the name following the type of a struct member starts.
+func memberNameStart(member *sitter.Node) (uint32, bool) {
+	seenType := false
+	for i := 0; i < int(member.ChildCount()); i++ {
+		child := member.Child(i)
+		if seenType && child.IsNamed() && !strings.Contains(child.Type(), "comment") {
+			return child.StartByte(), true
+		}
+		if child.Type() == "type" {
+			seenType = true
+		}
+	}
+
+	return 0, false
+}
+
+// layout splits tokens in lines and decides their indentation and spacing.
+func (p *printer) layout() []line {
+	lines := []line{}
+	for i, tok := range p.tokens {
+		newline, blank := false, false
+		if i > 0 {
+			newline, blank = p.breakBefore(i)
+		}
+
+		if ctClosers[tok.text] && p.ctLevel > 0 {
+			p.ctLevel--
+		}
+		if tok.text == "$endswitch" {
+			*p.caseFlag() = false
+		}
+		if (closingTokens[tok.text] || tok.text == "}") && len(p.stack) > 0 {
+			p.closesBlock[i] = p.stack[len(p.stack)-1].kind == groupBlock
+			p.stack = p.stack[:len(p.stack)-1]
+		}
+		p.indents[i] = p.indentFor(i)
+		p.depths[i] = p.openGroups()
+
+		if i == 0 || newline {
+			lines = append(lines, line{indent: p.indents[i], blankBefore: blank, pieces: []piece{{token: i}}})
+		} else {
+			last := &lines[len(lines)-1]
+			last.pieces = append(last.pieces, piece{token: i, space: p.spaceBetween(i-1, i)})
+		}
+
+		switch {
+		case ctOpeners[tok.text]:
+			p.ctLevel++
+		case caseLabels[tok.text]:
+			*p.caseFlag() = true
+		case openingTokens[tok.text]:
+			p.stack = append(p.stack, group{kind: groupParen})
+		case tok.text == "{":
+			kind := groupParen
+			if p.braces[i] == braceBlock || p.braces[i] == braceMultiline {
+				kind = groupBlock
+			}
+			p.stack = append(p.stack, group{kind: kind})
+		}
+	}
+
+	return lines
+}
+
+// breakBefore decides if the token starts a new line, and if that line is preceded by a blank one.
+func (p *printer) breakBefore(i int) (bool, bool) {
+	prev, cur := p.tokens[i-1], p.tokens[i]
+	preserved := cur.newlinesBefore > 0
+	blank := cur.newlinesBefore > 1
+
+	afterBlockOpen := prev.text == "{" && (p.braces[i-1] == braceBlock || p.braces[i-1] == braceMultiline)
+	beforeBlockClose := cur.text == "}" && p.insideBlock()
+	if afterBlockOpen || beforeBlockClose {
+		blank = false
+	}
+
+	switch {
+	case prev.isLineComment():
+		return true, blank
+	case cur.isComment():
+		return preserved, blank
+	case afterBlockOpen, beforeBlockClose:
+		return true, false
+	case p.braces[i-1] == braceEmptyBlock:
+		return false, false
+	case p.braces[i] == braceBlock || p.braces[i] == braceEmptyBlock:
+		if endsStatement(prev) {
+			return true, blank
+		}
+		if p.braces[i] == braceEmptyBlock || p.options.BraceStyle == BraceStyleSameLine {
+			return false, false
+		}
+		return true, false
+	case p.closesBlock[i-1]:
+		if cur.text == ";" || cur.text == "," || closingTokens[cur.text] {
+			return false, false
+		}
+		if p.options.BraceStyle == BraceStyleSameLine && (cur.text == "else" || cur.text == "while") {
+			return false, false
+		}
+		return true, blank
+	case prev.text == ";" && !p.insideParens():
+		return true, blank
+	case cur.text == ";" || cur.text == ",":
+		return false, false
+	}
+
+	return preserved, blank
+}
+
+func endsStatement(tok token) bool {
+	return tok.text == ";" || tok.text == "{" || tok.text == "}" || tok.text == ":" || tok.isComment()
+}
+
+func (p *printer) indentFor(i int) int {
+	text := p.tokens[i].text
+	level := p.ctLevel
+	if text == "$else" && level > 0 {
+		level--
+	}
+
+	// Case labels are indented one level less than the statements they contain.
+	isLabel := caseLabels[text]
+	innermost := p.innermostBlock()
+	if p.rootInCase && !(isLabel && innermost < 0) {
+		level++
+	}
+	for k, g := range p.stack {
+		level++
+		if g.kind == groupBlock && g.inCase && !(isLabel && k == innermost) {
+			level++
+		}
+	}
+
+	// Expressions continuing in the next line.
+	if i > 0 && text != "{" && !p.insideParens() && (p.isBinaryOperator(i-1) || p.isBinaryOperator(i)) {
+		level++
+	}
+
+	return level
+}
+
+func (p *printer) innermostBlock() int {
+	for k := len(p.stack) - 1; k >= 0; k-- {
+		if p.stack[k].kind == groupBlock {
+			return k
+		}
+	}
+
+	return -1
+}
+
+func (p *printer) caseFlag() *bool {
+	if k := p.innermostBlock(); k >= 0 {
+		return &p.stack[k].inCase
+	}
+
+	return &p.rootInCase
+}
+
+func (p *printer) insideBlock() bool {
+	return len(p.stack) > 0 && p.stack[len(p.stack)-1].kind == groupBlock
+}
+
+func (p *printer) insideParens() bool {
+	return len(p.stack) > 0 && p.stack[len(p.stack)-1].kind == groupParen
+}
+
+func (p *printer) openGroups() int {
+	count := 0
+	for _, g := range p.stack {
+		if g.kind == groupParen {
+			count++
+		}
+	}
+
+	return count
+}
+
+func (p *printer) spaceBetween(a int, b int) bool {
+	want := p.wantSpace(a, b)
+	if !want && p.tokens[b].spaceBefore && mergesWith(p.tokens[a].text, p.tokens[b].text) {
+		return true
+	}
+
+	return want
+}
+
+func (p *printer) wantSpace(a int, b int) bool {
+	prev, cur := p.tokens[a], p.tokens[b]
+	switch {
+	case prev.isComment() || cur.isComment():
+		return true
+	case endsWithWord(prev.text) && startsWithWord(cur.text):
+		return true
+	case p.braces[a] == braceEmptyBlock:
+		return false
+	case p.braces[b] == braceBlock || p.braces[b] == braceEmptyBlock || p.closesBlock[a]:
+		return true
+	case cur.text == "," || cur.text == ";":
+		return false
+	case prev.text == "," || prev.text == ";":
+		return true
+	case closingTokens[cur.text] || openingTokens[prev.text]:
+		return false
+	case cur.text == "." || prev.text == "." || cur.text == "::" || prev.text == "::":
+		return false
+	case cur.text == "(" && endsWithWord(prev.text):
+		return controlKeywords[prev.text]
+	case (cur.text == "[" || cur.text == "(<" || cur.text == "[<") && endsWithWord(prev.text):
+		return false
+	case p.isBinaryOperator(a) || p.isBinaryOperator(b):
+		return true
+	case p.isPrefixOperator(a) || p.isPostfixOperator(b):
+		return false
+	}
+
+	return cur.spaceBefore
+}
+
+func (p *printer) isBinaryOperator(i int) bool {
+	index, count, ok := p.operatorPosition(i, binaryOperators)
+	return ok && index > 0 && index < count-1
+}
+
+func (p *printer) isPrefixOperator(i int) bool {
+	index, count, ok := p.operatorPosition(i, prefixOperators)
+	return ok && index == 0 && count > 1
+}
+
+func (p *printer) isPostfixOperator(i int) bool {
+	index, count, ok := p.operatorPosition(i, postfixOperators)
+	return ok && index > 0 && index == count-1
+}
+
+// operatorPosition locates an operator between the children of its expression. Operators
+// inside types, like pointers and optionals, are left as they are written.
+func (p *printer) operatorPosition(i int, operators map[string]bool) (int, int, bool) {
+	tok := p.tokens[i]
+	if !operators[tok.text] || strings.Contains(tok.parentType(), "type") {
+		return 0, 0, false
+	}
+	index, count := tok.childIndex()
+
+	return index, count, true
+}
+
+func mergesWith(a string, b string) bool {
+	if a == "" || b == "" {
+		return false
+	}
+
+	last, first := a[len(a)-1], b[0]
+	if isWordByte(last) && isWordByte(first) {
+		return true
+	}
+	if (last >= '0' && last <= '9' && first == '.') || (last == '.' && first >= '0' && first <= '9') {
+		return true
+	}
+
+	return mergeablePairs[string([]byte{last, first})]
+}
+
+// wrap splits lines longer than the maximum width after the commas separating arguments,
+// parameters and initializer values.
+func (p *printer) wrap(lines []line) []line {
+	if p.options.MaxLineWidth <= 0 {
+		return lines
+	}
+
+	wrapped := []line{}
+	for _, l := range lines {
+		for {
+			at := p.wrapPoint(l)
+			if at < 0 {
+				break
+			}
+			wrapped = append(wrapped, line{indent: l.indent, blankBefore: l.blankBefore, pieces: l.pieces[:at]})
+
+			rest := append([]piece{}, l.pieces[at:]...)
+			rest[0].space = false
+			l = line{indent: p.indents[rest[0].token], pieces: rest}
+		}
+		wrapped = append(wrapped, l)
+	}
+
+	return wrapped
+}
+
+// wrapPoint returns the piece a too long line should be broken before, or -1.
+func (p *printer) wrapPoint(l line) int {
+	if p.lineWidth(l) <= p.options.MaxLineWidth {
+		return -1
+	}
+
+	best := -1
+	width := p.indentWidth(l.indent)
+	for k, pc := range l.pieces {
+		width += p.pieceWidth(pc)
+		if k+1 >= len(l.pieces) {
+			break
+		}
+		if p.tokens[pc.token].text != "," || p.depths[pc.token] == 0 || p.tokens[l.pieces[k+1].token].isComment() {
+			continue
+		}
+		if width <= p.options.MaxLineWidth || best < 0 {
+			best = k + 1
+		}
+		if width > p.options.MaxLineWidth {
+			break
+		}
+	}
+
+	return best
+}
+
+// align pads consecutive struct members or enum values so their names or values start
+// in the same column.
+func (p *printer) align(lines []line) {
+	for start := 0; start < len(lines); {
+		kind, _ := p.anchorOf(lines[start])
+		end := start + 1
+		if kind == alignNone {
+			start = end
+			continue
+		}
+
+		for end < len(lines) && !lines[end].blankBefore && lines[end].indent == lines[start].indent {
+			if next, _ := p.anchorOf(lines[end]); next != kind {
+				break
+			}
+			end++
+		}
+		p.alignRun(lines[start:end])
+		start = end
+	}
+}
+
+func (p *printer) alignRun(run []line) {
+	if len(run) < 2 {
+		return
+	}
+
+	columns := make([]int, len(run))
+	target := 0
+	for j, l := range run {
+		_, at := p.anchorOf(l)
+		for _, pc := range l.pieces[:at] {
+			columns[j] += p.pieceWidth(pc)
+		}
+		target = max(target, columns[j])
+	}
+
+	for j, l := range run {
+		_, at := p.anchorOf(l)
+		pad := target - columns[j]
+		if p.options.MaxLineWidth > 0 && p.lineWidth(l)+pad > p.options.MaxLineWidth {
+			continue
+		}
+		l.pieces[at].pad = pad
+	}
+}
+
+func (p *printer) anchorOf(l line) (alignKind, int) {
+	kind, at := alignNone, -1
+	for k, pc := range l.pieces {
+		if anchor, ok := p.anchors[pc.token]; ok {
+			if kind != alignNone {
+				return alignNone, -1
+			}
+			kind, at = anchor, k
+		}
+	}
+	if at <= 0 || !l.pieces[at].space {
+		return alignNone, -1
+	}
+
+	return kind, at
+}
+
+func (p *printer) render(lines []line) string {
+	var builder strings.Builder
+	for i, l := range lines {
+		if i > 0 && l.blankBefore {
+			builder.WriteString(p.newline)
+		}
+		builder.WriteString(p.indentString(l.indent))
+		for _, pc := range l.pieces {
+			if pc.space {
+				builder.WriteString(strings.Repeat(" ", 1+pc.pad))
+			}
+			builder.WriteString(p.tokens[pc.token].text)
+		}
+		builder.WriteString(p.newline)
+	}
+
+	return builder.String()
+}
+
+func (p *printer) indentString(level int) string {
+	if p.options.UseTabs {
+		return strings.Repeat("\t", level)
+	}
+
+	return strings.Repeat(" ", level*p.options.IndentSize)
+}
+
+func (p *printer) indentWidth(level int) int {
+	return level * p.options.IndentSize
+}
+
+func (p *printer) pieceWidth(pc piece) int {
+	width := utf8.RuneCountInString(p.tokens[pc.token].text)
+	if pc.space {
+		width += 1 + pc.pad
+	}
+
+	return width
+}
+
+func (p *printer) lineWidth(l line) int {
+	width := p.indentWidth(l.indent)
+	for _, pc := range l.pieces {
+		width += p.pieceWidth(pc)
+	}
+
+	return width
+}
@@ -0,0 +1,128 @@
+package formatter
+
+import (
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// token is a leaf of the syntax tree, or a node printed verbatim like comments and strings.
+type token struct {
+	text     string
+	node     *sitter.Node
+	start    uint32
+	end      uint32
+	startRow uint32
+	endRow   uint32
+
+	// Whitespace found in the source between the previous token and this one.
+	newlinesBefore int
+	spaceBefore    bool
+}
+
+// atomicNodeTypes are printed as they are written, without looking at their children.
+var atomicNodeTypes = map[string]bool{
+	"string_literal":     true,
+	"raw_string_literal": true,
+	"char_literal":       true,
+	"bytes_literal":      true,
+}
+
+func collectTokens(root *sitter.Node, source []byte) ([]token, error) {
+	tokens := []token{}
+	var walk func(node *sitter.Node)
+	walk = func(node *sitter.Node) {
+		if node.ChildCount() == 0 || isAtomicNode(node) {
+			// Comments may include the line break ending them.
+			text := strings.TrimRight(node.Content(source), " \t\r\n")
+			if text != "" {
+				tokens = append(tokens, token{
+					text:     text,
+					node:     node,
+					start:    node.StartByte(),
+					end:      node.StartByte() + uint32(len(text)),
+					startRow: node.StartPoint().Row,
+					endRow:   node.StartPoint().Row + uint32(strings.Count(text, "\n")),
+				})
+			}
+			return
+		}
+
+		for i := 0; i < int(node.ChildCount()); i++ {
+			walk(node.Child(i))
+		}
+	}
+	walk(root)
+
+	previousEnd := uint32(0)
+	for i := range tokens {
+		if tokens[i].start < previousEnd {
+			return nil, ErrUnsupportedSource
+		}
+		gap := string(source[previousEnd:tokens[i].start])
+		if strings.TrimSpace(gap) != "" {
+			// Text the tree does not cover would be lost.
+			return nil, ErrUnsupportedSource
+		}
+		tokens[i].newlinesBefore = strings.Count(gap, "\n")
+		tokens[i].spaceBefore = len(gap) > 0
+		previousEnd = tokens[i].end
+	}
+	if strings.TrimSpace(string(source[previousEnd:])) != "" {
+		return nil, ErrUnsupportedSource
+	}
+
+	return tokens, nil
+}
+
+func isAtomicNode(node *sitter.Node) bool {
+	return atomicNodeTypes[node.Type()] || strings.Contains(node.Type(), "comment")
+}
+
+func (t token) isComment() bool {
+	return strings.Contains(t.node.Type(), "comment")
+}
+
+func (t token) isLineComment() bool {
+	return t.isComment() && strings.HasPrefix(t.text, "//")
+}
+
+// childIndex returns the position of the token between its siblings and how many siblings there are.
+func (t token) childIndex() (int, int) {
+	parent := t.node.Parent()
+	if parent == nil {
+		return 0, 1
+	}
+
+	count := int(parent.ChildCount())
+	for i := 0; i < count; i++ {
+		child := parent.Child(i)
+		if child.StartByte() == t.start && child.Type() == t.node.Type() {
+			return i, count
+		}
+	}
+
+	return 0, 1
+}
+
+func (t token) parentType() string {
+	parent := t.node.Parent()
+	if parent == nil {
+		return ""
+	}
+
+	return parent.Type()
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c == '$' || c == '@' || c == '#' || c == '"' || c == '\'' || c == '`' ||
+		(c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c >= 0x80
+}
+
+func startsWithWord(text string) bool {
+	return len(text) > 0 && isWordByte(text[0])
+}
+
+func endsWithWord(text string) bool {
+	return len(text) > 0 && isWordByte(text[len(text)-1])
+}
@@ -0,0 +1,182 @@
+package index_cache
+
+import (
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pherrymason/c3-lsp/pkg/document"
+	"github.com/pherrymason/c3-lsp/pkg/parser"
+	"github.com/pherrymason/c3-lsp/pkg/symbols"
+	"github.com/pherrymason/c3-lsp/pkg/symbols_table"
+)
+
+// formatVersion must be increased whenever the stored symbols change, so entries written
+// by a previous format are ignored. Entries are also ignored after upgrading the server.
+const formatVersion = 1
+
+// maxEntryAge is how long entries are kept without being used. Entries of files deleted, moved
+// or cached by previous versions of the server are removed once that old.
+const maxEntryAge = 30 * 24 * time.Hour
+
+// Cache stores on disk the symbols parsed from files, so files that did not change since
+// they were last parsed are loaded without parsing them again.
+// A nil Cache parses every file.
+type Cache struct {
+	dir     string
+	version string
+}
+
+// entry is the content of a cache file. It is valid while the file it was parsed from
+// has the same size, modification time and content.
+type entry struct {
+	Version  string
+	Path     string
+	Size     int64
+	ModTime  int64
+	Hash     string
+	Snapshot symbols.Snapshot
+}
+
+// DefaultDir returns the directory of the cache, under the user cache directory.
+func DefaultDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "c3-lsp", "index"), nil
+}
+
+// NewCache creates a cache stored in dir for the given version of the server.
+func NewCache(dir string, serverVersion string) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	cache := &Cache{
+		dir:     dir,
+		version: fmt.Sprintf("%d/%s", formatVersion, serverVersion),
+	}
+	cache.evict(time.Now().Add(-maxEntryAge))
+
+	return cache, nil
+}
+
+// evict removes the entries last used before the given time, and temporary files left by
+// servers stopped while storing an entry.
+func (c *Cache) evict(before time.Time) {
+	files, err := os.ReadDir(c.dir)
+	if err != nil {
+		return
+	}
+
+	for _, file := range files {
+		info, err := file.Info()
+		if err != nil || !info.Mode().IsRegular() || !info.ModTime().Before(before) {
+			continue
+		}
+		if ext := filepath.Ext(file.Name()); ext == ".gob" || ext == ".tmp" {
+			os.Remove(filepath.Join(c.dir, file.Name()))
+		}
+	}
+}
+
+// ParseSymbols returns the symbols of doc, loaded from the cache when its file did not
+// change. Otherwise they are parsed and stored in the cache. Documents that are not files
+// on disk, like those inside library archives, are always parsed.
+func (c *Cache) ParseSymbols(doc *document.Document, parser *parser.Parser) (symbols_table.UnitModules, symbols_table.PendingToResolve) {
+	if c == nil {
+		return parser.ParseSymbols(doc)
+	}
+
+	info, err := os.Stat(doc.URI)
+	if err != nil || !info.Mode().IsRegular() {
+		return parser.ParseSymbols(doc)
+	}
+
+	hash := contentHash(doc.SourceCode.Text)
+	if snapshot, ok := c.load(doc.URI, info, hash); ok {
+		modules, pending, err := symbols_table.UnitModulesFromSnapshot(&doc.URI, snapshot)
+		if err == nil {
+			return modules, pending
+		}
+	}
+
+	modules, pending := parser.ParseSymbols(doc)
+	if snapshot, err := modules.Snapshot(); err == nil {
+		c.store(entry{
+			Version:  c.version,
+			Path:     doc.URI,
+			Size:     info.Size(),
+			ModTime:  info.ModTime().UnixNano(),
+			Hash:     hash,
+			Snapshot: snapshot,
+		})
+	}
+
+	return modules, pending
+}
+
+func (c *Cache) load(path string, info os.FileInfo, hash string) (symbols.Snapshot, bool) {
+	entryPath := c.entryPath(path)
+	file, err := os.Open(entryPath)
+	if err != nil {
+		return symbols.Snapshot{}, false
+	}
+	defer file.Close()
+
+	var cached entry
+	if err := gob.NewDecoder(file).Decode(&cached); err != nil {
+		return symbols.Snapshot{}, false
+	}
+
+	valid := cached.Version == c.version &&
+		cached.Path == path &&
+		cached.Size == info.Size() &&
+		cached.ModTime == info.ModTime().UnixNano() &&
+		cached.Hash == hash
+	if valid {
+		// Entries in use are not evicted.
+		now := time.Now()
+		os.Chtimes(entryPath, now, now)
+	}
+
+	return cached.Snapshot, valid
+}
+
+// store writes the entry to a temporary file renamed afterwards, so servers sharing the
+// cache never read a partially written entry.
+func (c *Cache) store(cached entry) error {
+	file, err := os.CreateTemp(c.dir, "entry-*.tmp")
+	if err != nil {
+		return err
+	}
+
+	err = gob.NewEncoder(file).Encode(cached)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), c.entryPath(cached.Path))
+	}
+	if err != nil {
+		os.Remove(file.Name())
+	}
+
+	return err
+}
+
+// entryPath returns the cache file of the file at path.
+func (c *Cache) entryPath(path string) string {
+	return filepath.Join(c.dir, contentHash(path)[:32]+".gob")
+}
+
+func contentHash(content string) string {
+	hash := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(hash[:])
+}
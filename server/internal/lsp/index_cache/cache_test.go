@@ -0,0 +1,115 @@
+package index_cache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pherrymason/c3-lsp/pkg/document"
+	"github.com/pherrymason/c3-lsp/pkg/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/tliron/commonlog"
+)
+
+func parseFile(t *testing.T, cache *Cache, p *parser.Parser, path string) *document.Document {
+	content, err := os.ReadFile(path)
+	assert.Nil(t, err)
+	doc := document.NewDocumentFromString(path, string(content))
+	cache.ParseSymbols(&doc, p)
+
+	return &doc
+}
+
+func isCached(cache *Cache, doc *document.Document) bool {
+	info, err := os.Stat(doc.URI)
+	if err != nil {
+		return false
+	}
+	_, ok := cache.load(doc.URI, info, contentHash(doc.SourceCode.Text))
+
+	return ok
+}
+
+func TestCache_loads_symbols_of_unchanged_files(t *testing.T) {
+	var logger commonlog.Logger
+	p := parser.NewParser(logger)
+	cache, err := NewCache(t.TempDir(), "0.1.0")
+	assert.Nil(t, err)
+
+	file := filepath.Join(t.TempDir(), "app.c3")
+	assert.Nil(t, os.WriteFile(file, []byte("module app;\nstruct Point { int x; }\nfn Point origin() { return {}; }\n"), 0644))
+
+	doc := parseFile(t, cache, &p, file)
+	assert.True(t, isCached(cache, doc))
+
+	modules, _ := cache.ParseSymbols(doc, &p)
+	module := modules.Get("app")
+	assert.Contains(t, module.Structs, "Point")
+	assert.Equal(t, "origin", module.ChildrenFunctions[0].GetName())
+	assert.Equal(t, "Point", module.ChildrenFunctions[0].GetReturnType().GetName())
+}
+
+func TestCache_ignores_entries_of_modified_files(t *testing.T) {
+	var logger commonlog.Logger
+	p := parser.NewParser(logger)
+	cache, err := NewCache(t.TempDir(), "0.1.0")
+	assert.Nil(t, err)
+
+	file := filepath.Join(t.TempDir(), "app.c3")
+	assert.Nil(t, os.WriteFile(file, []byte("module app;\nint a;\n"), 0644))
+	parseFile(t, cache, &p, file)
+
+	assert.Nil(t, os.WriteFile(file, []byte("module app;\nint b;\n"), 0644))
+	content, _ := os.ReadFile(file)
+	doc := document.NewDocumentFromString(file, string(content))
+	assert.False(t, isCached(cache, &doc))
+
+	modules, _ := cache.ParseSymbols(&doc, &p)
+	assert.Contains(t, modules.Get("app").Variables, "b")
+	assert.True(t, isCached(cache, &doc))
+}
+
+func TestCache_ignores_entries_of_other_server_versions(t *testing.T) {
+	var logger commonlog.Logger
+	p := parser.NewParser(logger)
+	dir := t.TempDir()
+	cache, err := NewCache(dir, "0.1.0")
+	assert.Nil(t, err)
+
+	file := filepath.Join(t.TempDir(), "app.c3")
+	assert.Nil(t, os.WriteFile(file, []byte("module app;\nint a;\n"), 0644))
+	doc := parseFile(t, cache, &p, file)
+
+	upgraded, err := NewCache(dir, "0.2.0")
+	assert.Nil(t, err)
+	assert.False(t, isCached(upgraded, doc))
+}
+
+func TestCache_evicts_entries_not_used_recently(t *testing.T) {
+	var logger commonlog.Logger
+	p := parser.NewParser(logger)
+	cache, err := NewCache(t.TempDir(), "0.1.0")
+	assert.Nil(t, err)
+
+	dir := t.TempDir()
+	used := filepath.Join(dir, "used.c3")
+	unused := filepath.Join(dir, "unused.c3")
+	assert.Nil(t, os.WriteFile(used, []byte("module app;\nint a;\n"), 0644))
+	assert.Nil(t, os.WriteFile(unused, []byte("module app;\nint b;\n"), 0644))
+	usedDoc := parseFile(t, cache, &p, used)
+	unusedDoc := parseFile(t, cache, &p, unused)
+
+	old := time.Now().Add(-2 * maxEntryAge)
+	assert.Nil(t, os.Chtimes(cache.entryPath(used), old, old))
+	assert.Nil(t, os.Chtimes(cache.entryPath(unused), old, old))
+	// Loading an entry marks it as used.
+	assert.True(t, isCached(cache, usedDoc))
+
+	cache.evict(time.Now().Add(-maxEntryAge))
+
+	assert.True(t, isCached(cache, usedDoc))
+	assert.False(t, isCached(cache, unusedDoc))
+	_, err = os.Stat(cache.entryPath(unused))
+	assert.True(t, os.IsNotExist(err))
+}
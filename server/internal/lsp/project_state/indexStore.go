@@ -27,3 +27,7 @@ func (i *IndexStore) ClearByTag(tag string) {
 func (i *IndexStore) SearchByFQN(query string) []idx.Indexable {
 	return i.store.Search(query)
 }
+
+func (i *IndexStore) All() []idx.Indexable {
+	return i.store.All()
+}
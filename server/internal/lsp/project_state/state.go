@@ -2,7 +2,11 @@ package project_state
 
 import (
 	"fmt"
+	"maps"
+	"os"
+	"slices"
 	"strings"
+	"sync"
 
 	"github.com/pherrymason/c3-lsp/pkg/document"
 	"github.com/pherrymason/c3-lsp/pkg/fs"
@@ -16,102 +20,379 @@ import (
 )
 
 // ProjectState will be the center of knowledge of everything parsed.
+// It is safe for concurrent use: writes are serialized and reads never see a write in progress.
+// Documents and maps returned are snapshots: later writes replace them instead of modifying them.
 type ProjectState struct {
-	_documents      map[string]*document.Document
-	documents       *document.DocumentStore
-	symbolsTable    symbols_table.SymbolsTable
-	indexByFQN      IndexStore // TODO simplify this and use trie.Trie directly!
-	languageVersion Version
+	mutex *sync.RWMutex
+
+	_documents   map[string]*document.Document
+	documents    *document.DocumentStore
+	symbolsTable *symbols_table.SymbolsTable
+	indexByFQN   IndexStore // TODO simplify this and use trie.Trie directly!
 
 	diagnostics map[string][]protocol.Diagnostic
+	// Diagnostics found by the language server analysing documents.
+	analysisDiagnostics map[string]analysis
+	// Syntax errors of documents, found as soon as they change.
+	syntaxDiagnostics map[string][]protocol.Diagnostic
+	// Increased whenever the symbols of a document change, which might change the analysis of others.
+	revision uint64
+	// Documents of the libraries the project depends on, telling if they were read from an
+	// archive. They are read-only.
+	libraryDocuments map[string]bool
+	// Documents of the stdlib sources, with the key of their stdlib.
+	stdlibDocuments map[string]string
+	// Documents open in the editor, with the number of clients having them open. Their content
+	// may differ from the file on disk.
+	openDocuments map[string]int
+	// Stdlibs in use by key, and the key of the stdlib used by each workspace folder. Documents
+	// outside every folder use the stdlib of folder "".
+	stdlibs       map[string]*stdlibSymbols
+	folderStdlibs map[string]string
+	// Folder whose stdlib is seen by searches, see ForDocument.
+	folder string
 
 	logger       commonlog.Logger
 	debugEnabled bool
 }
 
 func NewProjectState(logger commonlog.Logger, languageVersion option.Option[string], debug bool) ProjectState {
+	symbolsTable := symbols_table.NewSymbolsTable()
 	projectState := ProjectState{
+		mutex:        &sync.RWMutex{},
 		_documents:   map[string]*document.Document{},
 		documents:    document.NewDocumentStore(fs.FileStorage{}),
-		symbolsTable: symbols_table.NewSymbolsTable(),
+		symbolsTable: &symbolsTable,
 		indexByFQN:   NewIndexStore(),
 		diagnostics:  make(map[string][]protocol.Diagnostic),
 
-		logger:          logger,
-		languageVersion: GetVersion(languageVersion),
-		debugEnabled:    debug,
+		analysisDiagnostics: make(map[string]analysis),
+		syntaxDiagnostics:   make(map[string][]protocol.Diagnostic),
+		libraryDocuments:    map[string]bool{},
+		stdlibDocuments:     map[string]string{},
+		openDocuments:       map[string]int{},
+		stdlibs:             map[string]*stdlibSymbols{},
+		folderStdlibs:       map[string]string{},
+
+		logger:       logger,
+		debugEnabled: debug,
 	}
 
 	// Install stdlib symbols
-	projectState.SetLanguageVersion(projectState.languageVersion)
+	projectState.UseBundledStdlib("", GetVersion(languageVersion))
 
 	return projectState
 }
 
 func (s ProjectState) GetProjectRootURI() string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
 	return s.documents.RootURI
 }
 func (s *ProjectState) SetProjectRootURI(rootURI string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
 	s.documents.RootURI = rootURI
 }
 
+// GetDocument returns the document as it is now. It is not modified by later changes, which
+// store a new document.
 func (s *ProjectState) GetDocument(docId string) *document.Document {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
 	return s._documents[docId]
 }
 
 func (s *ProjectState) GetUnitModulesByDoc(docId string) *symbols_table.UnitModules {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
 	value := s.symbolsTable.GetByDoc(docId)
 	return value
 }
 
+// GetAllUnitModules returns the modules of every document, which can be used while documents
+// are indexed, as registered modules are never modified. Only the modules of the stdlib seen
+// by the state are included.
 func (s *ProjectState) GetAllUnitModules() map[protocol.DocumentUri]symbols_table.UnitModules {
-	return s.symbolsTable.All()
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	unitModules := maps.Clone(s.symbolsTable.All())
+	visible := s.visibleStdlib()
+	for _, stdlib := range s.stdlibs {
+		if stdlib == visible {
+			continue
+		}
+		for _, unitId := range stdlib.unitIds {
+			delete(unitModules, unitId)
+		}
+	}
+
+	return unitModules
 }
 
 func (s *ProjectState) SearchByFQN(query string) []symbols.Indexable {
-	return s.indexByFQN.SearchByFQN(query)
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	found := s.indexByFQN.SearchByFQN(query)
+	if stdlib := s.visibleStdlib(); stdlib != nil {
+		found = append(found, stdlib.index.SearchByFQN(query)...)
+	}
+
+	return found
+}
+
+// IndexedSymbols returns every root symbol registered in the index, those of the stdlib
+// seen by the state included.
+func (s *ProjectState) IndexedSymbols() []symbols.Indexable {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	indexed := s.indexByFQN.All()
+	if stdlib := s.visibleStdlib(); stdlib != nil {
+		indexed = append(indexed, stdlib.index.All()...)
+	}
+
+	return indexed
 }
 
+// GetDocumentDiagnostics returns a snapshot of the diagnostics published for each document.
 func (s *ProjectState) GetDocumentDiagnostics() map[string][]protocol.Diagnostic {
-	return s.diagnostics
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	diagnostics := make(map[string][]protocol.Diagnostic, len(s.diagnostics))
+	for docId, docDiagnostics := range s.diagnostics {
+		diagnostics[docId] = docDiagnostics
+	}
+
+	return diagnostics
 }
 
-func (s *ProjectState) SetLanguageVersion(languageVersion Version) {
-	s.languageVersion = languageVersion
-	stdlibModules := languageVersion.stdLibSymbols()
-	s.indexParsedSymbols(stdlibModules, stdlibModules.DocId())
+// DiagnosableDocumentIds returns the documents that can have diagnostics: those known by
+// the state, and those c3c reported diagnostics for.
+func (s *ProjectState) DiagnosableDocumentIds() []string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
 
-	s.symbolsTable.Register(stdlibModules, symbols_table.PendingToResolve{})
+	docIds := []string{}
+	for docId := range s._documents {
+		docIds = append(docIds, docId)
+	}
+	for docId := range s.diagnostics {
+		if _, ok := s._documents[docId]; !ok {
+			docIds = append(docIds, docId)
+		}
+	}
+	slices.Sort(docIds)
+
+	return docIds
 }
 
 func (s *ProjectState) SetDocumentDiagnostics(docId string, diagnostics []protocol.Diagnostic) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
 	s.diagnostics[docId] = diagnostics
 }
 
-func (s *ProjectState) ClearDocumentDiagnostics() {
+// ClearDocumentDiagnostics removes every diagnostic, returning the documents that had any.
+func (s *ProjectState) ClearDocumentDiagnostics() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	docIds := []string{}
 	for k := range s.diagnostics {
+		docIds = append(docIds, k)
 		delete(s.diagnostics, k)
 	}
+
+	return docIds
 }
 func (s *ProjectState) RemoveDocumentDiagnostics(docId string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
 	delete(s.diagnostics, docId)
 }
 
+// analysis are the diagnostics found analysing a document, when the state had the given revision.
+type analysis struct {
+	document    *document.Document
+	revision    uint64
+	diagnostics []protocol.Diagnostic
+}
+
+// Revision identifies the symbols known, it changes whenever the symbols of a document change.
+func (s *ProjectState) Revision() uint64 {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.revision
+}
+
+// InvalidateAnalysis makes every analysis outdated, like when the checks to run change.
+func (s *ProjectState) InvalidateAnalysis() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.revision++
+}
+
+// SetAnalysisDiagnostics stores the diagnostics found analysing doc at the given revision. They
+// are dropped when the document changed meanwhile, as its new content is analysed later.
+func (s *ProjectState) SetAnalysisDiagnostics(doc *document.Document, revision uint64, diagnostics []protocol.Diagnostic) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s._documents[doc.URI] != doc {
+		return
+	}
+	s.analysisDiagnostics[doc.URI] = analysis{document: doc, revision: revision, diagnostics: diagnostics}
+}
+
+// HasCurrentAnalysis tells if the document was analysed as it is now, with the symbols known now.
+func (s *ProjectState) HasCurrentAnalysis(docId string) bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	analysis, ok := s.analysisDiagnostics[docId]
+	return ok && analysis.document == s._documents[docId] && analysis.revision == s.revision
+}
+
+// SetSyntaxDiagnostics stores the syntax errors found in doc. They are dropped when the document
+// changed meanwhile.
+func (s *ProjectState) SetSyntaxDiagnostics(doc *document.Document, diagnostics []protocol.Diagnostic) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s._documents[doc.URI] != doc {
+		return
+	}
+	s.syntaxDiagnostics[doc.URI] = diagnostics
+}
+
+func (s *ProjectState) RemoveAnalysisDiagnostics(docId string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.analysisDiagnostics, docId)
+	delete(s.syntaxDiagnostics, docId)
+}
+
+// PublishableDiagnostics returns the diagnostics of the document reported both by c3c and by the analysis.
+func (s *ProjectState) PublishableDiagnostics(docId string) []protocol.Diagnostic {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	diagnostics := []protocol.Diagnostic{}
+	diagnostics = append(diagnostics, s.diagnostics[docId]...)
+	diagnostics = append(diagnostics, s.syntaxDiagnostics[docId]...)
+	diagnostics = append(diagnostics, s.analysisDiagnostics[docId].diagnostics...)
+
+	return diagnostics
+}
+
 func (s *ProjectState) RefreshDocumentIdentifiers(doc *document.Document, parser *parser.Parser) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.refreshDocumentIdentifiers(doc, parser)
+}
+
+func (s *ProjectState) IsLibraryDocument(docId string) bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	_, ok := s.libraryDocuments[docId]
+
+	return ok
+}
+
+// DocumentURI returns the URI of the document for the client. Documents read from library archives
+// use the library scheme, clients get their content with the "c3lsp/libraryFileContent" request.
+func (s *ProjectState) DocumentURI(docId string, stdlibPath option.Option[string]) string {
+	s.mutex.RLock()
+	archived := s.libraryDocuments[docId]
+	s.mutex.RUnlock()
+
+	if archived {
+		return fs.ConvertArchivePathToURI(docId)
+	}
+
+	return fs.ConvertPathToURI(docId, stdlibPath)
+}
+
+// IndexDocument indexes a document with symbols parsed beforehand, like those loaded from the index cache.
+// Documents already known, like those opened in the editor while they were parsed, are kept.
+func (s *ProjectState) IndexDocument(doc *document.Document, parsedModules symbols_table.UnitModules, pendingTypes symbols_table.PendingToResolve) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s._documents[doc.URI]; ok {
+		return
+	}
+	s.registerDocument(doc, parsedModules, pendingTypes)
+}
+
+// IndexLibraryDocument indexes a document of a library, which cannot be modified, with symbols parsed beforehand.
+// Archived tells if it was read from a library archive.
+func (s *ProjectState) IndexLibraryDocument(doc *document.Document, archived bool, parsedModules symbols_table.UnitModules, pendingTypes symbols_table.PendingToResolve) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s._documents[doc.URI]; ok {
+		return
+	}
+	s.libraryDocuments[doc.URI] = archived
+	s.registerDocument(doc, parsedModules, pendingTypes)
+}
+
+func (s *ProjectState) refreshDocumentIdentifiers(doc *document.Document, parser *parser.Parser) {
 	parsedModules, pendingTypes := parser.ParseSymbols(doc)
 
+	s.registerDocument(doc, parsedModules, pendingTypes)
+}
+
+func (s *ProjectState) registerDocument(doc *document.Document, parsedModules symbols_table.UnitModules, pendingTypes symbols_table.PendingToResolve) {
+	s.revision++
 	// Store elements in the state
 	s._documents[doc.URI] = doc
-	s.symbolsTable.Register(parsedModules, pendingTypes)
-	s.indexParsedSymbols(parsedModules, doc.URI)
+	resolved := s.symbolsTable.Register(parsedModules, pendingTypes)
+	s.indexParsedSymbols(*s.symbolsTable.GetByDoc(doc.URI), doc.URI)
+	s.indexResolvedDocuments(resolved)
 }
 
 func (s *ProjectState) DeleteDocument(docId string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.deleteDocument(docId)
+}
+
+func (s *ProjectState) deleteDocument(docId string) {
+	index := s.indexOf(docId)
+	index.ClearByTag(docId)
+	delete(s._documents, docId)
+	s.revision++
+	delete(s.analysisDiagnostics, docId)
+	delete(s.syntaxDiagnostics, docId)
+	delete(s.libraryDocuments, docId)
+	delete(s.stdlibDocuments, docId)
 	s.symbolsTable.DeleteDocument(docId)
-	s.indexByFQN.ClearByTag(docId)
 }
 
 func (s *ProjectState) RenameDocument(oldDocId string, newDocId string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.revision++
+
 	s.indexByFQN.ClearByTag(oldDocId)
 	s.symbolsTable.RenameDocument(oldDocId, newDocId)
 
@@ -120,44 +401,111 @@ func (s *ProjectState) RenameDocument(oldDocId string, newDocId string) {
 }
 
 func (s *ProjectState) UpdateDocument(docURI protocol.DocumentUri, changes []interface{}, parser *parser.Parser) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
 	docId := utils.NormalizePath(docURI)
 	doc, ok := s._documents[docId]
 	if !ok {
 		return
 	}
 
-	doc.ApplyChanges(changes)
+	// The previous document might still be used by a request running meanwhile.
+	s.refreshDocumentIdentifiers(doc.WithChanges(changes), parser)
+}
+
+// OpenDocument indexes a document opened in the editor.
+func (s *ProjectState) OpenDocument(doc *document.Document, parser *parser.Parser) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.openDocuments[doc.URI]++
+	s.refreshDocumentIdentifiers(doc, parser)
+}
+
+func (s *ProjectState) IsOpenDocument(docId string) bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
 
-	s.RefreshDocumentIdentifiers(doc, parser)
+	return s.openDocuments[docId] > 0
 }
 
-func (s *ProjectState) CloseDocument(uri protocol.DocumentUri) {
+// CloseDocument keeps the document indexed with the content of its file, as the changes not
+// saved are discarded by the editor, so references to it are still found. Documents without
+// file are removed. Documents still open by another client are kept as they are.
+func (s *ProjectState) CloseDocument(uri protocol.DocumentUri, parser *parser.Parser) {
 	docId := utils.NormalizePath(uri)
-	delete(s._documents, docId)
+	content, err := os.ReadFile(docId)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// It is parsed from scratch when opened again.
+	defer parser.ForgetDocument(docId)
+	if s.openDocuments[docId] > 1 {
+		s.openDocuments[docId]--
+		return
+	}
+	delete(s.openDocuments, docId)
+	// Libraries cannot be modified, their document is already the one indexed.
+	if _, ok := s.libraryDocuments[docId]; ok {
+		return
+	}
+	if err != nil {
+		s.deleteDocument(docId)
+		return
+	}
+
+	doc := document.NewDocumentFromString(docId, string(content))
+	s.refreshDocumentIdentifiers(&doc, parser)
 }
 
 func (s *ProjectState) indexParsedSymbols(parsedModules symbols_table.UnitModules, docId string) {
-	s.indexByFQN.ClearByTag(docId)
+	index := s.indexOf(docId)
+	index.ClearByTag(docId)
+	registerSymbols(index, parsedModules)
+}
+
+// indexResolvedDocuments indexes again documents whose types were resolved with the symbols of
+// another one, as the symbols table replaced their modules.
+func (s *ProjectState) indexResolvedDocuments(docIds []string) {
+	if len(docIds) > 0 {
+		s.revision++
+	}
+	for _, docId := range docIds {
+		s.indexParsedSymbols(*s.symbolsTable.GetByDoc(docId), docId)
+	}
+}
+
+// indexOf returns the index of the document: that of its stdlib for stdlib documents.
+func (s *ProjectState) indexOf(docId string) IndexStore {
+	if key, ok := s.stdlibDocuments[docId]; ok {
+		return s.stdlibs[key].index
+	}
+
+	return s.indexByFQN
+}
 
+func registerSymbols(index IndexStore, parsedModules symbols_table.UnitModules) {
 	// Register in the index, the root elements
 	for _, module := range parsedModules.Modules() {
 		for _, fun := range module.ChildrenFunctions {
-			s.indexByFQN.RegisterSymbol(fun)
+			index.RegisterSymbol(fun)
 		}
 		for _, variable := range module.Variables {
-			s.indexByFQN.RegisterSymbol(variable)
+			index.RegisterSymbol(variable)
 		}
 		for _, enum := range module.Enums {
-			s.indexByFQN.RegisterSymbol(enum)
+			index.RegisterSymbol(enum)
 		}
 		for _, fault := range module.Faults {
-			s.indexByFQN.RegisterSymbol(fault)
+			index.RegisterSymbol(fault)
 		}
 		for _, strukt := range module.Structs {
-			s.indexByFQN.RegisterSymbol(strukt)
+			index.RegisterSymbol(strukt)
 		}
 		for _, def := range module.Defs {
-			s.indexByFQN.RegisterSymbol(def)
+			index.RegisterSymbol(def)
 		}
 	}
 }
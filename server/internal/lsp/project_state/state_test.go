@@ -1,13 +1,19 @@
 package project_state
 
 import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
 	"testing"
 
 	"github.com/pherrymason/c3-lsp/pkg/document"
+	"github.com/pherrymason/c3-lsp/pkg/fs"
 	"github.com/pherrymason/c3-lsp/pkg/option"
 	"github.com/pherrymason/c3-lsp/pkg/parser"
 	"github.com/stretchr/testify/assert"
 	"github.com/tliron/commonlog"
+	protocol "github.com/tliron/glsp/protocol_3_16"
 )
 
 func TestRefreshDocumentIdentifiers_should_clear_cached_stuff_test(t *testing.T) {
@@ -38,3 +44,143 @@ func TestRefreshDocumentIdentifiers_should_clear_cached_stuff_test(t *testing.T)
 	result = s.indexByFQN.SearchByFQN("app::something_new.main")
 	assert.Equal(t, 1, len(result))
 }
+
+func TestDocumentDiagnostics_can_be_accessed_concurrently(t *testing.T) {
+	var logger commonlog.Logger
+	s := NewProjectState(logger, option.Some("dummy"), false)
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			s.SetDocumentDiagnostics(fmt.Sprintf("doc-%d", i), []protocol.Diagnostic{})
+		}(i)
+		go func() {
+			defer wg.Done()
+			for range s.GetDocumentDiagnostics() {
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 10, len(s.GetDocumentDiagnostics()))
+	assert.Equal(t, 10, len(s.ClearDocumentDiagnostics()))
+	assert.Equal(t, 0, len(s.GetDocumentDiagnostics()))
+}
+
+func TestIndexDocument_keeps_documents_open_in_the_editor(t *testing.T) {
+	var logger commonlog.Logger
+	s := NewProjectState(logger, option.Some("dummy"), false)
+	p := parser.NewParser(logger)
+
+	opened := document.NewDocumentFromString("doc-id", "module app;\nfn void edited() {}\n")
+	s.OpenDocument(&opened, &p)
+	assert.True(t, s.IsOpenDocument("doc-id"))
+
+	fromDisk := document.NewDocumentFromString("doc-id", "module app;\nfn void saved() {}\n")
+	modules, pending := p.ParseSymbols(&fromDisk)
+	s.IndexDocument(&fromDisk, modules, pending)
+
+	assert.Equal(t, 1, len(s.SearchByFQN("app.edited")))
+	assert.Equal(t, 0, len(s.SearchByFQN("app.saved")))
+
+	s.CloseDocument("doc-id", &p)
+	assert.False(t, s.IsOpenDocument("doc-id"))
+}
+
+func TestDocumentURI_uses_the_library_scheme_for_archived_documents(t *testing.T) {
+	var logger commonlog.Logger
+	s := NewProjectState(logger, option.Some("dummy"), false)
+	p := parser.NewParser(logger)
+
+	index := func(docId string, archived bool) {
+		doc := document.NewDocumentFromString(docId, "module lib;\n")
+		modules, pending := p.ParseSymbols(&doc)
+		s.IndexLibraryDocument(&doc, archived, modules, pending)
+	}
+	archived := fs.ArchiveEntryPath("/project/lib/raylib.c3l", "raylib.c3i")
+	index(archived, true)
+	// Unpacked libraries may have any name.
+	unpacked := "/project/lib/weird!/json.c3i"
+	index(unpacked, false)
+
+	assert.Equal(t, "c3l:///project/lib/raylib.c3l!/raylib.c3i", s.DocumentURI(archived, option.None[string]()))
+	assert.Equal(t, "file:///project/lib/weird!/json.c3i", s.DocumentURI(unpacked, option.None[string]()))
+	assert.True(t, s.IsLibraryDocument(unpacked))
+}
+
+func TestGetAllUnitModules_can_be_iterated_while_documents_are_indexed(t *testing.T) {
+	var logger commonlog.Logger
+	s := NewProjectState(logger, option.Some("dummy"), false)
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			p := parser.NewParser(logger)
+			doc := document.NewDocumentFromString(fmt.Sprintf("doc-%d", i), "module app;\nfn void main() {}\n")
+			modules, pending := p.ParseSymbols(&doc)
+			s.IndexDocument(&doc, modules, pending)
+		}(i)
+		go func() {
+			defer wg.Done()
+			for docId := range s.GetAllUnitModules() {
+				s.GetDocument(docId)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 11, len(s.GetAllUnitModules()), "documents and the stdlib")
+}
+
+func TestUpdateDocument_keeps_documents_returned_before(t *testing.T) {
+	var logger commonlog.Logger
+	s := NewProjectState(logger, option.Some("dummy"), false)
+	p := parser.NewParser(logger)
+
+	opened := document.NewDocumentFromString("doc-id", "module app;\nfn void main() {}\n")
+	s.OpenDocument(&opened, &p)
+	before := s.GetDocument("doc-id")
+
+	s.UpdateDocument("doc-id", []interface{}{
+		protocol.TextDocumentContentChangeEventWhole{Text: "module app;\nfn void other() {}\n"},
+	}, &p)
+
+	assert.Equal(t, "module app;\nfn void main() {}\n", before.SourceCode.Text)
+	assert.Equal(t, "module app;\nfn void other() {}\n", s.GetDocument("doc-id").SourceCode.Text)
+	assert.Equal(t, 1, len(s.SearchByFQN("app.other")))
+}
+
+func TestCloseDocument_indexes_the_content_of_the_file(t *testing.T) {
+	var logger commonlog.Logger
+	s := NewProjectState(logger, option.Some("dummy"), false)
+	p := parser.NewParser(logger)
+	path := filepath.Join(fs.GetCanonicalPath(t.TempDir()), "app.c3")
+	assert.Nil(t, os.WriteFile(path, []byte("module app;\nfn void saved() {}\n"), 0644))
+
+	opened := document.NewDocumentFromString(path, "module app;\nfn void unsaved() {}\n")
+	s.OpenDocument(&opened, &p)
+	s.CloseDocument(path, &p)
+
+	assert.NotNil(t, s.GetDocument(path))
+	assert.Equal(t, "module app;\nfn void saved() {}\n", s.GetDocument(path).SourceCode.Text)
+	assert.Equal(t, 1, len(s.SearchByFQN("app.saved")))
+	assert.Equal(t, 0, len(s.SearchByFQN("app.unsaved")))
+}
+
+func TestCloseDocument_removes_documents_without_file(t *testing.T) {
+	var logger commonlog.Logger
+	s := NewProjectState(logger, option.Some("dummy"), false)
+	p := parser.NewParser(logger)
+	path := filepath.Join(t.TempDir(), "untitled.c3")
+
+	opened := document.NewDocumentFromString(path, "module app;\nfn void unsaved() {}\n")
+	s.OpenDocument(&opened, &p)
+	s.CloseDocument(path, &p)
+
+	assert.Nil(t, s.GetDocument(path))
+	assert.Equal(t, 0, len(s.SearchByFQN("app.unsaved")))
+}
@@ -0,0 +1,219 @@
+package project_state
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/pherrymason/c3-lsp/internal/lsp/index_cache"
+	"github.com/pherrymason/c3-lsp/pkg/document"
+	"github.com/pherrymason/c3-lsp/pkg/fs"
+	"github.com/pherrymason/c3-lsp/pkg/parser"
+	"github.com/pherrymason/c3-lsp/pkg/symbols_table"
+)
+
+// StdlibDocument is a file of the stdlib sources, with its parsed symbols.
+type StdlibDocument struct {
+	Document *document.Document
+	Modules  symbols_table.UnitModules
+	Pending  symbols_table.PendingToResolve
+}
+
+// ParseStdlib parses the stdlib sources found in path, or loads them from cache when they did not
+// change, without building their syntax trees until they are used. It does not use the state, so it can run in the background while the state is in use,
+// as long as parser is not shared.
+func ParseStdlib(path string, parser *parser.Parser, cache *index_cache.Cache) ([]StdlibDocument, error) {
+	files, err := fs.ScanForC3(fs.GetCanonicalPath(path))
+	if err != nil {
+		return nil, err
+	}
+
+	documents := []StdlibDocument{}
+	for _, filePath := range files {
+		content, err := os.ReadFile(filePath)
+		if err != nil {
+			return nil, err
+		}
+
+		doc := document.NewUnparsedDocument(filePath, string(content))
+		modules, pending := cache.ParseSymbols(&doc, parser)
+		documents = append(documents, StdlibDocument{Document: &doc, Modules: modules, Pending: pending})
+	}
+
+	return documents, nil
+}
+
+// stdlibSymbols are the symbols of a stdlib, bundled for a language version or parsed from its
+// sources. Each workspace folder sees those of the stdlib it uses.
+type stdlibSymbols struct {
+	// Ids of its modules in the symbols table.
+	unitIds []string
+	// Documents of its sources, none for bundled symbols.
+	documents []string
+	index     IndexStore
+}
+
+func bundledStdlibKey(version Version) string {
+	return "bundled:" + version.Number
+}
+
+func stdlibSourcesKey(path string) string {
+	return "sources:" + path
+}
+
+// UseBundledStdlib makes the documents of folder see the stdlib symbols bundled for the language version.
+// Folder "" is used for documents outside every folder.
+func (s *ProjectState) UseBundledStdlib(folder string, languageVersion Version) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	key := bundledStdlibKey(languageVersion)
+	if _, ok := s.stdlibs[key]; !ok {
+		stdlibModules := languageVersion.stdLibSymbols()
+		// Every version has the same document id, they are told apart by version.
+		stdlibModules = stdlibModules.WithDocId(stdlibModules.DocId() + "@" + languageVersion.Number)
+
+		stdlib := &stdlibSymbols{unitIds: []string{stdlibModules.DocId()}, index: NewIndexStore()}
+		resolved := s.symbolsTable.Register(stdlibModules, symbols_table.PendingToResolve{})
+		registerSymbols(stdlib.index, *s.symbolsTable.GetByDoc(stdlibModules.DocId()))
+		s.indexResolvedDocuments(resolved)
+		s.stdlibs[key] = stdlib
+	}
+
+	s.setFolderStdlib(folder, key)
+}
+
+// UseStdlibSources makes the documents of folder see the symbols parsed from the stdlib sources
+// at path, instead of those bundled for the language version. Documents are only used when these
+// sources are not indexed yet, see HasStdlibSources. Stdlib documents are read-only, like libraries.
+func (s *ProjectState) UseStdlibSources(folder string, path string, documents []StdlibDocument) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	key := stdlibSourcesKey(path)
+	if _, ok := s.stdlibs[key]; !ok {
+		stdlib := &stdlibSymbols{index: NewIndexStore()}
+		s.stdlibs[key] = stdlib
+
+		for _, stdlibDocument := range documents {
+			docId := stdlibDocument.Document.URI
+			// Opened in the editor while the stdlib was being parsed.
+			if _, ok := s._documents[docId]; ok {
+				continue
+			}
+
+			s._documents[docId] = stdlibDocument.Document
+			s.libraryDocuments[docId] = false
+			s.stdlibDocuments[docId] = key
+			resolved := s.symbolsTable.Register(stdlibDocument.Modules, stdlibDocument.Pending)
+			registerSymbols(stdlib.index, *s.symbolsTable.GetByDoc(docId))
+			s.indexResolvedDocuments(resolved)
+			stdlib.unitIds = append(stdlib.unitIds, docId)
+			stdlib.documents = append(stdlib.documents, docId)
+		}
+	}
+
+	s.setFolderStdlib(folder, key)
+}
+
+// HasStdlibSources tells if the stdlib sources at path are indexed, so that other folders can use
+// them without parsing them again.
+func (s *ProjectState) HasStdlibSources(path string) bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	_, ok := s.stdlibs[stdlibSourcesKey(path)]
+	return ok
+}
+
+// RetainFolders forgets the stdlib used by folders other than those given. Documents outside every
+// folder keep theirs.
+func (s *ProjectState) RetainFolders(folders []string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for folder := range s.folderStdlibs {
+		if folder != "" && !slices.Contains(folders, folder) {
+			delete(s.folderStdlibs, folder)
+		}
+	}
+	s.removeUnusedStdlibs()
+}
+
+// ForDocument returns the state as seen from the document, whose stdlib symbols are those used by
+// its folder. It shares everything else with s, and is meant for searching.
+func (s *ProjectState) ForDocument(docId string) *ProjectState {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	view := *s
+	view.folder = ""
+	for folder := range s.folderStdlibs {
+		if folder != "" && isInsideFolder(docId, folder) && len(folder) > len(view.folder) {
+			view.folder = folder
+		}
+	}
+
+	return &view
+}
+
+// Folder returns the workspace folder the state is seen from, see ForDocument. It is "" for
+// documents outside every folder.
+func (s *ProjectState) Folder() string {
+	return s.folder
+}
+
+// visibleStdlib returns the stdlib seen by the state, nil when there is none.
+func (s *ProjectState) visibleStdlib() *stdlibSymbols {
+	key, ok := s.folderStdlibs[s.folder]
+	if !ok {
+		key = s.folderStdlibs[""]
+	}
+
+	return s.stdlibs[key]
+}
+
+func (s *ProjectState) setFolderStdlib(folder string, key string) {
+	if s.folderStdlibs[folder] != key {
+		s.revision++
+	}
+	s.folderStdlibs[folder] = key
+	s.removeUnusedStdlibs()
+}
+
+// removeUnusedStdlibs removes the stdlibs no folder uses anymore.
+func (s *ProjectState) removeUnusedStdlibs() {
+	used := map[string]bool{}
+	for _, key := range s.folderStdlibs {
+		used[key] = true
+	}
+
+	for key, stdlib := range s.stdlibs {
+		if used[key] {
+			continue
+		}
+
+		for _, unitId := range stdlib.unitIds {
+			s.symbolsTable.DeleteDocument(unitId)
+		}
+		for _, docId := range stdlib.documents {
+			delete(s._documents, docId)
+			delete(s.libraryDocuments, docId)
+			delete(s.stdlibDocuments, docId)
+		}
+		delete(s.stdlibs, key)
+	}
+}
+
+func isInsideFolder(path string, folder string) bool {
+	return path == folder || strings.HasPrefix(path, strings.TrimSuffix(folder, string(filepath.Separator))+string(filepath.Separator))
+}
+
+func (s *ProjectState) IsStdlibDocument(docId string) bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	_, ok := s.stdlibDocuments[docId]
+	return ok
+}
@@ -0,0 +1,92 @@
+package project_state
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pherrymason/c3-lsp/pkg/option"
+	"github.com/pherrymason/c3-lsp/pkg/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/tliron/commonlog"
+)
+
+func TestUseStdlibSources_replaces_bundled_stdlib_symbols(t *testing.T) {
+	var logger commonlog.Logger
+	s := NewProjectState(logger, option.Some("0.6.2"), false)
+	p := parser.NewParser(logger)
+
+	bundled := s.SearchByFQN("std::io.printn")
+	assert.NotEmpty(t, bundled)
+	assert.False(t, bundled[0].HasSourceCode())
+
+	stdlibPath := t.TempDir()
+	file := filepath.Join(stdlibPath, "io.c3")
+	assert.Nil(t, os.WriteFile(file, []byte("module std::io;\nfn void printn(String s) {}\n"), 0644))
+
+	documents, err := ParseStdlib(stdlibPath, &p, nil)
+	assert.Nil(t, err)
+	s.UseStdlibSources("", stdlibPath, documents)
+
+	parsed := s.SearchByFQN("std::io.printn")
+	assert.Equal(t, 1, len(parsed))
+	assert.True(t, parsed[0].HasSourceCode())
+	assert.True(t, s.IsStdlibDocument(parsed[0].GetDocumentURI()))
+	assert.True(t, s.IsLibraryDocument(parsed[0].GetDocumentURI()))
+	assert.True(t, s.HasStdlibSources(stdlibPath))
+}
+
+func TestUseBundledStdlib_is_seen_by_the_documents_of_the_folder(t *testing.T) {
+	var logger commonlog.Logger
+	s := NewProjectState(logger, option.Some("0.6.2"), false)
+	folder055 := filepath.Join(t.TempDir(), "old")
+	folder062 := filepath.Join(t.TempDir(), "new")
+	s.UseBundledStdlib(folder055, GetVersion(option.Some("0.5.5")))
+	s.UseBundledStdlib(folder062, GetVersion(option.Some("0.6.2")))
+
+	// ElasticArray was added after 0.5.5.
+	query := "std::collections::elastic_array.ElasticArray"
+	assert.Empty(t, s.ForDocument(filepath.Join(folder055, "main.c3")).SearchByFQN(query))
+	assert.Equal(t, 1, len(s.ForDocument(filepath.Join(folder062, "main.c3")).SearchByFQN(query)))
+	// Outside every folder, the stdlib of the server is seen.
+	assert.Equal(t, 1, len(s.ForDocument("/elsewhere/main.c3").SearchByFQN(query)))
+
+	assert.Equal(t, 1, len(s.ForDocument(filepath.Join(folder055, "main.c3")).GetAllUnitModules()))
+	assert.Equal(t, 1, len(s.ForDocument(filepath.Join(folder062, "main.c3")).GetAllUnitModules()))
+}
+
+func TestUseStdlibSources_only_changes_the_stdlib_of_the_folder(t *testing.T) {
+	var logger commonlog.Logger
+	s := NewProjectState(logger, option.Some("0.6.2"), false)
+	p := parser.NewParser(logger)
+	folder := filepath.Join(t.TempDir(), "project")
+	other := filepath.Join(t.TempDir(), "other")
+	s.UseBundledStdlib(folder, GetVersion(option.Some("0.6.2")))
+	s.UseBundledStdlib(other, GetVersion(option.Some("0.6.2")))
+
+	stdlibPath := t.TempDir()
+	assert.Nil(t, os.WriteFile(filepath.Join(stdlibPath, "io.c3"), []byte("module std::io;\nfn void printn(String s) {}\n"), 0644))
+	documents, err := ParseStdlib(stdlibPath, &p, nil)
+	assert.Nil(t, err)
+	s.UseStdlibSources(folder, stdlibPath, documents)
+
+	parsed := s.ForDocument(filepath.Join(folder, "main.c3")).SearchByFQN("std::io.printn")
+	assert.Equal(t, 1, len(parsed))
+	assert.True(t, parsed[0].HasSourceCode())
+	bundled := s.ForDocument(filepath.Join(other, "main.c3")).SearchByFQN("std::io.printn")
+	assert.Equal(t, 1, len(bundled))
+	assert.False(t, bundled[0].HasSourceCode())
+
+	// Sources no folder uses are removed.
+	s.RetainFolders([]string{other})
+	assert.False(t, s.HasStdlibSources(stdlibPath))
+	assert.Nil(t, s.GetDocument(filepath.Join(stdlibPath, "io.c3")))
+}
+
+func TestGetVersion_uses_latest_bundled_stdlib_for_unknown_versions(t *testing.T) {
+	versions := SupportedVersions()
+
+	version := GetVersion(option.Some("0.7.3"))
+
+	assert.Equal(t, versions[len(versions)-1].Number, version.Number)
+}
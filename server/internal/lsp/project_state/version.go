@@ -1,7 +1,7 @@
 package project_state
 
 import (
-	"fmt"
+	"log"
 
 	"github.com/pherrymason/c3-lsp/internal/lsp/stdlib"
 	"github.com/pherrymason/c3-lsp/pkg/option"
@@ -54,5 +54,9 @@ func GetVersion(number option.Option[string]) Version {
 		}
 	}
 
-	panic(fmt.Sprintf("Requested C3 language version \"%s\" not supported", requestedVersion))
+	// Newer compilers get the most recent stdlib known, until the stdlib sources are parsed.
+	latest := versions[len(versions)-1]
+	log.Printf("Requested C3 language version \"%s\" has no bundled stdlib symbols. Using %s", requestedVersion, latest.Number)
+
+	return latest
 }
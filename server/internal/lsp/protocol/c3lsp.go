@@ -0,0 +1,15 @@
+package protocol
+
+import (
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// Requests specific to c3-lsp, not part of LSP.
+
+// MethodLibraryFileContent returns the content of a file inside a `.c3l` library archive.
+// Those files have `c3l://` URIs, that clients cannot read by themselves.
+const MethodLibraryFileContent = protocol.Method("c3lsp/libraryFileContent")
+
+type LibraryFileContentParams struct {
+	TextDocument protocol.TextDocumentIdentifier `json:"textDocument"`
+}
@@ -0,0 +1,118 @@
+package protocol
+
+import (
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// Types from LSP 3.17 not available yet in glsp.
+
+// ServerCapabilities extends the 3.16 server capabilities with 3.17 providers.
+type ServerCapabilities struct {
+	protocol.ServerCapabilities
+
+	InlayHintProvider  any `json:"inlayHintProvider,omitempty"`  // nil | bool | InlayHintOptions
+	DiagnosticProvider any `json:"diagnosticProvider,omitempty"` // nil | DiagnosticOptions
+}
+
+type InitializeResult struct {
+	Capabilities ServerCapabilities                   `json:"capabilities"`
+	ServerInfo   *protocol.InitializeResultServerInfo `json:"serverInfo,omitempty"`
+}
+
+// https://microsoft.github.io/language-server-protocol/specifications/lsp/3.17/specification/#textDocument_inlayHint
+
+const MethodTextDocumentInlayHint = protocol.Method("textDocument/inlayHint")
+
+type InlayHintParams struct {
+	protocol.WorkDoneProgressParams
+
+	TextDocument protocol.TextDocumentIdentifier `json:"textDocument"`
+	Range        protocol.Range                  `json:"range"`
+}
+
+type InlayHintKind protocol.UInteger
+
+const (
+	InlayHintKindType      = InlayHintKind(1)
+	InlayHintKindParameter = InlayHintKind(2)
+)
+
+type InlayHint struct {
+	Position     protocol.Position `json:"position"`
+	Label        string            `json:"label"`
+	Kind         *InlayHintKind    `json:"kind,omitempty"`
+	PaddingLeft  *bool             `json:"paddingLeft,omitempty"`
+	PaddingRight *bool             `json:"paddingRight,omitempty"`
+}
+
+// https://microsoft.github.io/language-server-protocol/specifications/lsp/3.17/specification/#textDocument_pullDiagnostics
+
+const (
+	MethodTextDocumentDiagnostic     = protocol.Method("textDocument/diagnostic")
+	MethodWorkspaceDiagnostic        = protocol.Method("workspace/diagnostic")
+	ServerWorkspaceDiagnosticRefresh = protocol.Method("workspace/diagnostic/refresh")
+)
+
+type DiagnosticOptions struct {
+	Identifier            *string `json:"identifier,omitempty"`
+	InterFileDependencies bool    `json:"interFileDependencies"`
+	WorkspaceDiagnostics  bool    `json:"workspaceDiagnostics"`
+}
+
+type DocumentDiagnosticParams struct {
+	protocol.WorkDoneProgressParams
+	protocol.PartialResultParams
+
+	TextDocument     protocol.TextDocumentIdentifier `json:"textDocument"`
+	Identifier       *string                         `json:"identifier,omitempty"`
+	PreviousResultID *string                         `json:"previousResultId,omitempty"`
+}
+
+type DocumentDiagnosticReportKind string
+
+const (
+	DocumentDiagnosticReportKindFull      = DocumentDiagnosticReportKind("full")
+	DocumentDiagnosticReportKindUnchanged = DocumentDiagnosticReportKind("unchanged")
+)
+
+type FullDocumentDiagnosticReport struct {
+	Kind     DocumentDiagnosticReportKind `json:"kind"`
+	ResultID *string                      `json:"resultId,omitempty"`
+	Items    []protocol.Diagnostic        `json:"items"`
+}
+
+type UnchangedDocumentDiagnosticReport struct {
+	Kind     DocumentDiagnosticReportKind `json:"kind"`
+	ResultID string                       `json:"resultId"`
+}
+
+type PreviousResultID struct {
+	URI   protocol.DocumentUri `json:"uri"`
+	Value string               `json:"value"`
+}
+
+type WorkspaceDiagnosticParams struct {
+	protocol.WorkDoneProgressParams
+	protocol.PartialResultParams
+
+	Identifier        *string            `json:"identifier,omitempty"`
+	PreviousResultIDs []PreviousResultID `json:"previousResultIds"`
+}
+
+type WorkspaceFullDocumentDiagnosticReport struct {
+	FullDocumentDiagnosticReport
+
+	URI     protocol.DocumentUri `json:"uri"`
+	Version *protocol.Integer    `json:"version"`
+}
+
+type WorkspaceUnchangedDocumentDiagnosticReport struct {
+	UnchangedDocumentDiagnosticReport
+
+	URI     protocol.DocumentUri `json:"uri"`
+	Version *protocol.Integer    `json:"version"`
+}
+
+type WorkspaceDiagnosticReport struct {
+	Items []any `json:"items"` // WorkspaceFullDocumentDiagnosticReport | WorkspaceUnchangedDocumentDiagnosticReport
+}
@@ -1,6 +1,8 @@
 package search
 
 import (
+	"fmt"
+
 	log "github.com/tliron/commonlog"
 )
 
@@ -56,6 +58,7 @@ func (self MockLogger) Error(message string, keysAndValues ...any) {
 
 // ([Logger] interface)
 func (self MockLogger) Errorf(format string, args ...any) {
+	self.tracker["error"] = append(self.tracker["error"], fmt.Sprintf(format, args...))
 }
 
 // ([Logger] interface)
@@ -18,14 +18,18 @@ import (
 )
 
 type Search struct {
-	debugEnabled bool
-	logger       commonlog.Logger
+	debugEnabled   bool
+	logger         commonlog.Logger
+	resolutions    *resolutionCache
+	semanticTokens *semanticTokensCache
 }
 
 func NewSearch(logger commonlog.Logger, debugEnabled bool) Search {
 	return Search{
-		debugEnabled: debugEnabled,
-		logger:       logger,
+		debugEnabled:   debugEnabled,
+		logger:         logger,
+		resolutions:    &resolutionCache{},
+		semanticTokens: &semanticTokensCache{},
 	}
 }
 
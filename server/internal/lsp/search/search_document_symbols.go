@@ -0,0 +1,165 @@
+package search
+
+import (
+	"cmp"
+	"slices"
+
+	l "github.com/pherrymason/c3-lsp/internal/lsp/project_state"
+	_prot "github.com/pherrymason/c3-lsp/internal/lsp/protocol"
+	"github.com/pherrymason/c3-lsp/pkg/symbols"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// BuildDocumentSymbols returns the outline of a document: its modules, and inside them
+// the symbols declared at module level with their members.
+func (s *Search) BuildDocumentSymbols(docId string, state *l.ProjectState) []protocol.DocumentSymbol {
+	documentSymbols := []protocol.DocumentSymbol{}
+	unitModules := state.GetUnitModulesByDoc(docId)
+	if unitModules == nil {
+		return documentSymbols
+	}
+
+	for _, module := range unitModules.Modules() {
+		children := []symbols.Indexable{}
+		for _, variable := range module.Variables {
+			children = append(children, variable)
+		}
+		for _, enum := range module.Enums {
+			children = append(children, enum)
+		}
+		for _, fault := range module.Faults {
+			children = append(children, fault)
+		}
+		for _, strukt := range module.Structs {
+			children = append(children, strukt)
+		}
+		for _, bitstruct := range module.Bitstructs {
+			children = append(children, bitstruct)
+		}
+		for _, def := range module.Defs {
+			children = append(children, def)
+		}
+		for _, _interface := range module.Interfaces {
+			children = append(children, _interface)
+		}
+		for _, function := range module.ChildrenFunctions {
+			children = append(children, function)
+		}
+
+		moduleSymbol := toDocumentSymbol(module)
+		moduleSymbol.Children = toDocumentSymbols(children)
+		documentSymbols = append(documentSymbols, moduleSymbol)
+	}
+
+	return documentSymbols
+}
+
+func toDocumentSymbols(indexables []symbols.Indexable) []protocol.DocumentSymbol {
+	slices.SortFunc(indexables, func(a, b symbols.Indexable) int {
+		return cmp.Or(
+			cmp.Compare(a.GetIdRange().Start.Line, b.GetIdRange().Start.Line),
+			cmp.Compare(a.GetIdRange().Start.Character, b.GetIdRange().Start.Character),
+		)
+	})
+
+	documentSymbols := []protocol.DocumentSymbol{}
+	for _, indexable := range indexables {
+		documentSymbol := toDocumentSymbol(indexable)
+
+		var members []symbols.Indexable
+		switch symbol := indexable.(type) {
+		case *symbols.Struct:
+			for _, member := range symbol.GetMembers() {
+				members = append(members, member)
+			}
+		case *symbols.Bitstruct:
+			for _, member := range symbol.Members() {
+				members = append(members, member)
+			}
+		case *symbols.Enum:
+			for _, enumerator := range symbol.GetEnumerators() {
+				members = append(members, enumerator)
+			}
+		case *symbols.Fault:
+			for _, constant := range symbol.GetConstants() {
+				members = append(members, constant)
+			}
+		case *symbols.Interface:
+			members = symbol.Children()
+		}
+
+		if len(members) > 0 {
+			documentSymbol.Children = toDocumentSymbols(members)
+		}
+		documentSymbols = append(documentSymbols, documentSymbol)
+	}
+
+	return documentSymbols
+}
+
+func toDocumentSymbol(indexable symbols.Indexable) protocol.DocumentSymbol {
+	var detail *string
+	switch symbol := indexable.(type) {
+	case *symbols.Variable:
+		detail = symbolDetail(symbol.GetType().String())
+	case *symbols.StructMember:
+		detail = symbolDetail(symbol.GetType().String())
+	case *symbols.Function:
+		detail = symbolDetail(symbol.GetHoverInfo())
+	}
+
+	// Members like enumerators do not track the range of their whole declaration.
+	documentRange := indexable.GetDocumentRange()
+	if documentRange == symbols.NewRange(0, 0, 0, 0) {
+		documentRange = indexable.GetIdRange()
+	}
+
+	return protocol.DocumentSymbol{
+		Name:           indexable.GetName(),
+		Detail:         detail,
+		Kind:           toSymbolKind(indexable),
+		Range:          _prot.Lsp_NewRangeFromRange(documentRange),
+		SelectionRange: _prot.Lsp_NewRangeFromRange(indexable.GetIdRange()),
+	}
+}
+
+func symbolDetail(detail string) *string {
+	if detail == "" {
+		return nil
+	}
+
+	return &detail
+}
+
+func toSymbolKind(indexable symbols.Indexable) protocol.SymbolKind {
+	switch symbol := indexable.(type) {
+	case *symbols.Module:
+		return protocol.SymbolKindModule
+	case *symbols.Variable:
+		if symbol.IsConstant() {
+			return protocol.SymbolKindConstant
+		}
+		return protocol.SymbolKindVariable
+	case *symbols.Function:
+		if symbol.FunctionType() == symbols.Method {
+			return protocol.SymbolKindMethod
+		}
+		return protocol.SymbolKindFunction
+	case *symbols.Struct, *symbols.Bitstruct:
+		return protocol.SymbolKindStruct
+	case *symbols.StructMember:
+		return protocol.SymbolKindField
+	case *symbols.Enum, *symbols.Fault:
+		return protocol.SymbolKindEnum
+	case *symbols.Enumerator, *symbols.FaultConstant:
+		return protocol.SymbolKindEnumMember
+	case *symbols.Interface:
+		return protocol.SymbolKindInterface
+	case *symbols.Def:
+		return protocol.SymbolKindTypeParameter
+	case *symbols.GenericParameter:
+		return protocol.SymbolKindTypeParameter
+	}
+
+	return protocol.SymbolKindVariable
+}
@@ -0,0 +1,61 @@
+package search
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func TestBuildDocumentSymbols(t *testing.T) {
+	state := NewTestState()
+	state.registerDoc(
+		"app.c3",
+		`module app;
+		const int MAX = 3;
+		enum Color { RED, GREEN }
+		struct Point { int x; int y; }
+		fn void Point.move(Point* self) {}
+		fn void main() {}`,
+	)
+	search := NewSearchWithoutLog()
+
+	documentSymbols := search.BuildDocumentSymbols("app.c3", &state.state)
+
+	assert.Equal(t, 1, len(documentSymbols))
+	module := documentSymbols[0]
+	assert.Equal(t, "app", module.Name)
+	assert.Equal(t, protocol.SymbolKindModule, module.Kind)
+
+	names := []string{}
+	kinds := []protocol.SymbolKind{}
+	for _, child := range module.Children {
+		names = append(names, child.Name)
+		kinds = append(kinds, child.Kind)
+	}
+	assert.Equal(t, []string{"MAX", "Color", "Point", "Point.move", "main"}, names)
+	assert.Equal(t, []protocol.SymbolKind{
+		protocol.SymbolKindConstant,
+		protocol.SymbolKindEnum,
+		protocol.SymbolKindStruct,
+		protocol.SymbolKindMethod,
+		protocol.SymbolKindFunction,
+	}, kinds)
+
+	enum := module.Children[1]
+	assert.Equal(t, "RED", enum.Children[0].Name)
+	assert.Equal(t, protocol.SymbolKindEnumMember, enum.Children[0].Kind)
+	assert.Equal(t, "GREEN", enum.Children[1].Name)
+
+	strukt := module.Children[2]
+	assert.Equal(t, "x", strukt.Children[0].Name)
+	assert.Equal(t, protocol.SymbolKindField, strukt.Children[0].Kind)
+	assert.Equal(t, "y", strukt.Children[1].Name)
+}
+
+func TestBuildDocumentSymbols_unknown_document(t *testing.T) {
+	state := NewTestState()
+	search := NewSearchWithoutLog()
+
+	assert.Equal(t, 0, len(search.BuildDocumentSymbols("missing.c3", &state.state)))
+}
@@ -0,0 +1,234 @@
+package search
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+	"sync"
+
+	l "github.com/pherrymason/c3-lsp/internal/lsp/project_state"
+	"github.com/pherrymason/c3-lsp/pkg/document"
+	"github.com/pherrymason/c3-lsp/pkg/option"
+	"github.com/pherrymason/c3-lsp/pkg/symbols"
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// Node types of the tree-sitter grammar that hold an identifier that can refer to a symbol.
+var identifierNodeTypes = map[string]bool{
+	"ident":       true,
+	"type_ident":  true,
+	"const_ident": true,
+	"ct_ident":    true,
+	"at_ident":    true,
+	"hash_ident":  true,
+}
+
+// Reference is a location in a document where a symbol is used.
+type Reference struct {
+	DocId string
+	Range symbols.Range
+}
+
+// FindReferencesInWorkspace finds the declaration of the symbol under cursor,
+// and returns every place in the workspace referring to it.
+func (s *Search) FindReferencesInWorkspace(
+	docId string,
+	position symbols.Position,
+	state *l.ProjectState,
+	includeDeclaration bool,
+) []Reference {
+	declarationOption := s.FindSymbolDeclarationInWorkspace(docId, position, state)
+	if declarationOption.IsNone() {
+		return []Reference{}
+	}
+
+	return s.FindSymbolReferences(declarationOption.Get(), state, includeDeclaration)
+}
+
+// FindSymbolReferences returns every place in the workspace where `declaration` is used.
+// Only identifiers with the same name are inspected, and each one of them is resolved
+// using the same search used by "Go to declaration", so module paths, access paths
+// and local scopes are respected.
+func (s *Search) FindSymbolReferences(declaration symbols.Indexable, state *l.ProjectState, includeDeclaration bool) []Reference {
+	references := []Reference{}
+	name := referenceName(declaration)
+	declarationFound := false
+
+	for docId := range state.GetAllUnitModules() {
+		doc := state.GetDocument(docId)
+		if doc == nil {
+			// Documents without source, like stdlib symbols.
+			continue
+		}
+
+		for _, candidate := range findIdentifiersByName(doc, name) {
+			isDeclaration := docId == declaration.GetDocumentURI() && candidate == declaration.GetIdRange()
+			if !isDeclaration {
+				if !s.resolveReferenceCandidate(docId, candidate.Start, state).refersTo(declaration) {
+					continue
+				}
+			}
+
+			if isDeclaration {
+				declarationFound = true
+				if !includeDeclaration {
+					continue
+				}
+			}
+
+			references = append(references, Reference{DocId: docId, Range: candidate})
+		}
+	}
+
+	if includeDeclaration && !declarationFound && declaration.HasSourceCode() {
+		references = append(references, Reference{
+			DocId: declaration.GetDocumentURI(),
+			Range: declaration.GetIdRange(),
+		})
+	}
+
+	slices.SortFunc(references, func(a, b Reference) int {
+		return cmp.Or(
+			cmp.Compare(a.DocId, b.DocId),
+			cmp.Compare(a.Range.Start.Line, b.Range.Start.Line),
+			cmp.Compare(a.Range.Start.Character, b.Range.Start.Character),
+		)
+	})
+
+	return references
+}
+
+// resolution is the declaration an identifier refers to.
+type resolution struct {
+	declaration option.Option[symbols.Indexable]
+	// unknown is true when the search failed, so it is not known whether the identifier
+	// refers to a declaration or not.
+	unknown bool
+}
+
+// refersTo tells if the identifier is known to refer to declaration.
+func (r resolution) refersTo(declaration symbols.Indexable) bool {
+	return r.declaration.IsSome() && isSameSymbol(r.declaration.Get(), declaration)
+}
+
+// resolveReferenceCandidate finds the declaration of the identifier located at position.
+// Resolutions are kept until the symbols known change, as the same identifiers are resolved
+// by every check of the documents referring to them.
+func (s *Search) resolveReferenceCandidate(docId string, position symbols.Position, state *l.ProjectState) resolution {
+	revision := state.Revision()
+	key := resolutionKey{folder: state.Folder(), docId: docId, position: position}
+	if resolved, ok := s.resolutions.get(revision, key); ok {
+		return resolved
+	}
+
+	resolved := s.resolveIdentifier(docId, position, state)
+	s.resolutions.set(revision, key, resolved)
+
+	return resolved
+}
+
+// resolveIdentifier finds the declaration of the identifier located at position. Resolution of incomplete
+// code might panic deep in the search. It is logged, and a single unresolvable identifier does
+// not abort the whole references search.
+func (s *Search) resolveIdentifier(docId string, position symbols.Position, state *l.ProjectState) (result resolution) {
+	defer func() {
+		if r := recover(); r != nil {
+			s.logger.Errorf("could not resolve identifier at %s:%d:%d: %v", docId, position.Line, position.Character, r)
+			result = resolution{unknown: true}
+		}
+	}()
+
+	return resolution{declaration: s.FindSymbolDeclarationInWorkspace(docId, position, state)}
+}
+
+// resolutionCache keeps the resolutions found while the state has the same revision.
+type resolutionCache struct {
+	mutex       sync.Mutex
+	revision    uint64
+	resolutions map[resolutionKey]resolution
+}
+
+type resolutionKey struct {
+	// Folders see different stdlib symbols.
+	folder   string
+	docId    string
+	position symbols.Position
+}
+
+func (c *resolutionCache) get(revision uint64, key resolutionKey) (resolution, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.revision != revision || c.resolutions == nil {
+		c.revision = revision
+		c.resolutions = map[resolutionKey]resolution{}
+	}
+	resolved, ok := c.resolutions[key]
+
+	return resolved, ok
+}
+
+func (c *resolutionCache) set(revision uint64, key resolutionKey, resolved resolution) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	// Symbols changed while resolving it.
+	if c.revision != revision {
+		return
+	}
+	c.resolutions[key] = resolved
+}
+
+// referenceName returns the text used in source code to refer to a symbol.
+func referenceName(symbol symbols.Indexable) string {
+	switch sym := symbol.(type) {
+	case *symbols.Function:
+		return sym.GetMethodName()
+	case *symbols.Module:
+		path := strings.Split(sym.GetName(), "::")
+		return path[len(path)-1]
+	}
+
+	return symbol.GetName()
+}
+
+func isSameSymbol(a symbols.Indexable, b symbols.Indexable) bool {
+	_, aIsModule := a.(*symbols.Module)
+	_, bIsModule := b.(*symbols.Module)
+	if aIsModule || bIsModule {
+		// The same module can be declared in multiple documents.
+		return aIsModule && bIsModule && a.GetName() == b.GetName()
+	}
+
+	return a.GetDocumentURI() == b.GetDocumentURI() &&
+		a.GetIdRange() == b.GetIdRange() &&
+		a.GetName() == b.GetName() &&
+		a.GetKind() == b.GetKind()
+}
+
+// findIdentifiersByName returns the ranges of all identifiers in doc named `name`.
+func findIdentifiersByName(doc *document.Document, name string) []symbols.Range {
+	ranges := []symbols.Range{}
+	// Checked first, so documents not mentioning the name are not parsed.
+	if !strings.Contains(doc.SourceCode.Text, name) || doc.SyntaxTree() == nil {
+		return ranges
+	}
+
+	sourceCode := []byte(doc.SourceCode.Text)
+	var walk func(node *sitter.Node)
+	walk = func(node *sitter.Node) {
+		if node.ChildCount() == 0 {
+			if identifierNodeTypes[node.Type()] && node.Content(sourceCode) == name {
+				ranges = append(ranges, symbols.NewRangeFromTreeSitterPositions(node.StartPoint(), node.EndPoint()))
+			}
+			return
+		}
+
+		for i := 0; i < int(node.ChildCount()); i++ {
+			walk(node.Child(i))
+		}
+	}
+	walk(doc.SyntaxTree().RootNode())
+
+	return ranges
+}
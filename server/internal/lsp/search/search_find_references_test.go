@@ -0,0 +1,129 @@
+package search
+
+import (
+	"testing"
+
+	"github.com/pherrymason/c3-lsp/pkg/symbols"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindReferences_local_variable(t *testing.T) {
+	state := NewTestState()
+	state.registerDoc(
+		"app.c3",
+		`module app;
+		fn void main() {
+			int value = 1;
+			value = value + 2;
+		}
+		fn void other() {
+			int value = 3;
+		}`,
+	)
+	search := NewSearchWithoutLog()
+
+	references := search.FindReferencesInWorkspace("app.c3", buildPosition(4, 4), &state.state, true)
+
+	assert.Equal(t, []Reference{
+		{DocId: "app.c3", Range: symbols.NewRange(2, 7, 2, 12)},
+		{DocId: "app.c3", Range: symbols.NewRange(3, 3, 3, 8)},
+		{DocId: "app.c3", Range: symbols.NewRange(3, 11, 3, 16)},
+	}, references)
+}
+
+func TestFindReferences_excludes_declaration(t *testing.T) {
+	state := NewTestState()
+	state.registerDoc(
+		"app.c3",
+		`module app;
+		fn void main() {
+			int value = 1;
+			value = 2;
+		}`,
+	)
+	search := NewSearchWithoutLog()
+
+	references := search.FindReferencesInWorkspace("app.c3", buildPosition(3, 7), &state.state, false)
+
+	assert.Equal(t, []Reference{
+		{DocId: "app.c3", Range: symbols.NewRange(3, 3, 3, 8)},
+	}, references)
+}
+
+func TestFindReferences_function_across_documents(t *testing.T) {
+	state := NewTestState()
+	state.registerDoc(
+		"app.c3",
+		`module app;
+		import util;
+		fn void main() {
+			util::run();
+		}`,
+	)
+	state.registerDoc(
+		"util.c3",
+		`module util;
+		fn void run() {}
+		fn void again() { run(); }`,
+	)
+	search := NewSearchWithoutLog()
+
+	references := search.FindReferencesInWorkspace("util.c3", buildPosition(2, 11), &state.state, true)
+
+	assert.Equal(t, []Reference{
+		{DocId: "app.c3", Range: symbols.NewRange(3, 9, 3, 12)},
+		{DocId: "util.c3", Range: symbols.NewRange(1, 10, 1, 13)},
+		{DocId: "util.c3", Range: symbols.NewRange(2, 20, 2, 23)},
+	}, references)
+}
+
+func TestFindReferences_struct_member(t *testing.T) {
+	state := NewTestState()
+	state.registerDoc(
+		"app.c3",
+		`module app;
+		struct Point { int x; int y; }
+		fn void main() {
+			Point p;
+			p.x = 1;
+			int x = p.y;
+		}`,
+	)
+	search := NewSearchWithoutLog()
+
+	references := search.FindReferencesInWorkspace("app.c3", buildPosition(2, 21), &state.state, true)
+
+	assert.Equal(t, []Reference{
+		{DocId: "app.c3", Range: symbols.NewRange(1, 21, 1, 22)},
+		{DocId: "app.c3", Range: symbols.NewRange(4, 5, 4, 6)},
+	}, references)
+}
+
+func TestResolveReferenceCandidate_tells_failed_searches_apart(t *testing.T) {
+	state := NewTestState()
+	logger := &MockLogger{tracker: make(map[string][]string)}
+	search := NewSearch(logger, false)
+
+	// Searching in a document that is not indexed fails.
+	resolved := search.resolveReferenceCandidate("missing.c3", buildPosition(1, 1), &state.state)
+
+	assert.True(t, resolved.unknown)
+	assert.True(t, resolved.declaration.IsNone())
+	assert.Len(t, logger.tracker["error"], 1)
+}
+
+func TestResolutionCache_forgets_resolutions_when_symbols_change(t *testing.T) {
+	cache := resolutionCache{}
+	key := resolutionKey{docId: "app.c3", position: buildPosition(1, 1)}
+
+	_, found := cache.get(1, key)
+	assert.False(t, found)
+	cache.set(1, key, resolution{unknown: true})
+
+	resolved, found := cache.get(1, key)
+	assert.True(t, found)
+	assert.True(t, resolved.unknown)
+
+	_, found = cache.get(2, key)
+	assert.False(t, found)
+}
@@ -0,0 +1,196 @@
+package search
+
+import (
+	"github.com/pherrymason/c3-lsp/internal/lsp/ast"
+	l "github.com/pherrymason/c3-lsp/internal/lsp/project_state"
+	_prot "github.com/pherrymason/c3-lsp/internal/lsp/protocol"
+	"github.com/pherrymason/c3-lsp/pkg/cast"
+	"github.com/pherrymason/c3-lsp/pkg/option"
+	"github.com/pherrymason/c3-lsp/pkg/symbols"
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// BuildInlayHints returns the hints to display inside `limit`:
+//   - Parameter names in front of the arguments of a call.
+//   - Element type of `foreach` variables declared without type.
+//   - Type of variables declared with `var`.
+func (s *Search) BuildInlayHints(docId string, limit symbols.Range, state *l.ProjectState) []_prot.InlayHint {
+	hints := []_prot.InlayHint{}
+	doc := state.GetDocument(docId)
+	if doc == nil || doc.SyntaxTree() == nil {
+		return hints
+	}
+
+	sourceCode := []byte(doc.SourceCode.Text)
+	var walk func(node *sitter.Node)
+	walk = func(node *sitter.Node) {
+		nodeRange := symbols.NewRangeFromTreeSitterPositions(node.StartPoint(), node.EndPoint())
+		// Skip nodes ending before or starting after the requested range.
+		if nodeRange.IsAfterPosition(limit.Start) || nodeRange.IsBeforePosition(limit.End) {
+			return
+		}
+
+		switch node.Type() {
+		case "call_expr":
+			hints = append(hints, s.parameterNameHints(node, docId, sourceCode, state)...)
+		case "foreach_cond":
+			hints = append(hints, s.foreachTypeHints(node, docId, sourceCode, state)...)
+		case "var_decl":
+			hints = append(hints, s.varTypeHints(node, docId, sourceCode, state)...)
+		}
+
+		for i := 0; i < int(node.ChildCount()); i++ {
+			walk(node.Child(i))
+		}
+	}
+	walk(doc.SyntaxTree().RootNode())
+
+	return hints
+}
+
+func (s *Search) parameterNameHints(callNode *sitter.Node, docId string, sourceCode []byte, state *l.ProjectState) []_prot.InlayHint {
+	hints := []_prot.InlayHint{}
+	callee := callNode.Child(0)
+	function, ok := ast.ResolveLastIdentifier(callee, documentResolver{s, docId, state}).(*symbols.Function)
+	if !ok {
+		return hints
+	}
+
+	parameters := function.GetArguments()
+	// `value.method()` passes `value` as `self`, while `Type.method(value)` does not.
+	if function.FunctionType() == symbols.Method && ast.FirstLeaf(callee).Type() != "type_ident" && len(parameters) > 0 {
+		parameters = parameters[1:]
+	}
+
+	arguments := []*sitter.Node{}
+	for i := 1; i < int(callNode.ChildCount()); i++ {
+		if callNode.Child(i).Type() != "call_invocation" {
+			continue
+		}
+		invocation := callNode.Child(i)
+		for a := 0; a < int(invocation.NamedChildCount()); a++ {
+			if invocation.NamedChild(a).Type() == "call_arg" {
+				arguments = append(arguments, invocation.NamedChild(a))
+			}
+		}
+	}
+
+	for i, argument := range arguments {
+		if i >= len(parameters) || parameters[i] == nil {
+			break
+		}
+		if isNamedOrSplatArgument(argument) {
+			break
+		}
+
+		name := parameters[i].GetName()
+		last := ast.LastLeaf(argument)
+		if name == "" || last.Content(sourceCode) == name {
+			continue
+		}
+
+		hints = append(hints, _prot.InlayHint{
+			Position:     symbols.NewPositionFromTreeSitterPoint(argument.StartPoint()).ToLSPPosition(),
+			Label:        name + ":",
+			Kind:         cast.ToPtr(_prot.InlayHintKindParameter),
+			PaddingRight: cast.ToPtr(true),
+		})
+	}
+
+	return hints
+}
+
+func (s *Search) foreachTypeHints(condNode *sitter.Node, docId string, sourceCode []byte, state *l.ProjectState) []_prot.InlayHint {
+	hints := []_prot.InlayHint{}
+	variables := []*sitter.Node{}
+	var collection *sitter.Node
+	for i := 0; i < int(condNode.NamedChildCount()); i++ {
+		child := condNode.NamedChild(i)
+		if child.Type() == "foreach_var" {
+			variables = append(variables, child)
+		} else {
+			collection = child
+		}
+	}
+	if collection == nil || len(variables) == 0 {
+		return hints
+	}
+
+	collectionType := ast.InferExpressionType(collection, sourceCode, documentResolver{s, docId, state})
+	if collectionType.IsNone() || !collectionType.Get().IsCollection() {
+		return hints
+	}
+
+	// Only the element has its type known, the index type of the collection is not.
+	hints = append(hints, untypedVariableHint(variables[len(variables)-1], collectionType.Get().ElementType().String())...)
+
+	return hints
+}
+
+func (s *Search) varTypeHints(declNode *sitter.Node, docId string, sourceCode []byte, state *l.ProjectState) []_prot.InlayHint {
+	var identifier *sitter.Node
+	for i := 0; i < int(declNode.NamedChildCount()); i++ {
+		if declNode.NamedChild(i).Type() == "ident" {
+			identifier = declNode.NamedChild(i)
+			break
+		}
+	}
+	initializer := declNode.NamedChild(int(declNode.NamedChildCount()) - 1)
+	if identifier == nil || initializer == nil || initializer.Equal(identifier) {
+		return []_prot.InlayHint{}
+	}
+
+	inferred := ast.InferExpressionType(initializer, sourceCode, documentResolver{s, docId, state})
+	if inferred.IsNone() {
+		return []_prot.InlayHint{}
+	}
+
+	return []_prot.InlayHint{typeHint(identifier, inferred.Get().String())}
+}
+
+// documentResolver resolves identifiers of a document for the ast package.
+type documentResolver struct {
+	search *Search
+	docId  string
+	state  *l.ProjectState
+}
+
+func (r documentResolver) ResolveIdentifier(identifier *sitter.Node) option.Option[symbols.Indexable] {
+	return r.search.resolveReferenceCandidate(r.docId, symbols.NewPositionFromTreeSitterPoint(identifier.StartPoint()), r.state).declaration
+}
+
+func isNamedOrSplatArgument(argument *sitter.Node) bool {
+	for i := 0; i < int(argument.ChildCount()); i++ {
+		childType := argument.Child(i).Type()
+		if childType == ":" || childType == "..." {
+			return true
+		}
+	}
+
+	return false
+}
+
+func untypedVariableHint(variable *sitter.Node, typeName string) []_prot.InlayHint {
+	var identifier *sitter.Node
+	for i := 0; i < int(variable.NamedChildCount()); i++ {
+		switch variable.NamedChild(i).Type() {
+		case "type":
+			return []_prot.InlayHint{}
+		case "ident":
+			identifier = variable.NamedChild(i)
+		}
+	}
+	if identifier == nil {
+		return []_prot.InlayHint{}
+	}
+
+	return []_prot.InlayHint{typeHint(identifier, typeName)}
+}
+
+func typeHint(identifier *sitter.Node, typeName string) _prot.InlayHint {
+	return _prot.InlayHint{
+		Position: symbols.NewPositionFromTreeSitterPoint(identifier.EndPoint()).ToLSPPosition(),
+		Label:    ": " + typeName,
+		Kind:     cast.ToPtr(_prot.InlayHintKindType),
+	}
+}
@@ -0,0 +1,80 @@
+package search
+
+import (
+	"testing"
+
+	_prot "github.com/pherrymason/c3-lsp/internal/lsp/protocol"
+	"github.com/pherrymason/c3-lsp/pkg/symbols"
+	"github.com/stretchr/testify/assert"
+)
+
+func hintLabels(hints []_prot.InlayHint) []string {
+	labels := []string{}
+	for _, hint := range hints {
+		labels = append(labels, hint.Label)
+	}
+
+	return labels
+}
+
+func TestBuildInlayHints_parameter_names(t *testing.T) {
+	state := NewTestState()
+	state.registerDoc(
+		"app.c3",
+		`module app;
+		struct Window { int width; }
+		fn void Window.resize(Window* self, int width, bool animate) {}
+		fn void open(int width, bool visible) {}
+		fn void main() {
+			int width = 3;
+			open(width, true);
+			Window w;
+			w.resize(10, false);
+		}`,
+	)
+	search := NewSearchWithoutLog()
+
+	hints := search.BuildInlayHints("app.c3", symbols.NewRange(0, 0, 10, 0), &state.state)
+
+	assert.Equal(t, []string{"visible:", "width:", "animate:"}, hintLabels(hints))
+	assert.Equal(t, _prot.InlayHintKindParameter, *hints[0].Kind)
+	assert.Equal(t, symbols.NewPosition(6, 15).ToLSPPosition(), hints[0].Position)
+}
+
+func TestBuildInlayHints_var_declarations(t *testing.T) {
+	state := NewTestState()
+	state.registerDoc(
+		"app.c3",
+		`module app;
+		fn float ratio() { return 1.0; }
+		fn void main() {
+			var count = 1;
+			var r = ratio();
+		}`,
+	)
+	search := NewSearchWithoutLog()
+
+	hints := search.BuildInlayHints("app.c3", symbols.NewRange(0, 0, 6, 0), &state.state)
+
+	assert.Equal(t, []string{": int", ": float"}, hintLabels(hints))
+	assert.Equal(t, symbols.NewPosition(3, 11).ToLSPPosition(), hints[0].Position)
+}
+
+func TestBuildInlayHints_foreach_element_type(t *testing.T) {
+	state := NewTestState()
+	state.registerDoc(
+		"app.c3",
+		`module app;
+		fn void main() {
+			int[3] values;
+			foreach (i, value : values) {}
+			foreach (int typed : values) {}
+		}`,
+	)
+	search := NewSearchWithoutLog()
+
+	hints := search.BuildInlayHints("app.c3", symbols.NewRange(0, 0, 6, 0), &state.state)
+
+	assert.Equal(t, []string{": int"}, hintLabels(hints))
+	assert.Equal(t, symbols.NewPosition(3, 20).ToLSPPosition(), hints[0].Position)
+}
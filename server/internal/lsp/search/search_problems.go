@@ -0,0 +1,337 @@
+package search
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+
+	l "github.com/pherrymason/c3-lsp/internal/lsp/project_state"
+	"github.com/pherrymason/c3-lsp/pkg/symbols"
+	"github.com/pherrymason/c3-lsp/pkg/symbols_table"
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// Codes of the problems found by FindProblems. They are part of the configuration
+// of the server, so they must not change.
+const (
+	ProblemUnresolvedIdentifier = "unresolved-identifier"
+	ProblemUnknownModule        = "unknown-module"
+	ProblemUnusedImport         = "unused-import"
+	ProblemUnusedVariable       = "unused-variable"
+	ProblemUnusedFunction       = "unused-function"
+)
+
+// Problem is an issue found in a document by the semantic analysis.
+type Problem struct {
+	Code    string
+	Range   symbols.Range
+	Message string
+}
+
+// Identifiers only checked to know what is used: they refer to compile time values or macros
+// that the index does not always know about.
+var unreportedIdentifierNodeTypes = map[string]bool{
+	"ct_ident":   true,
+	"at_ident":   true,
+	"hash_ident": true,
+}
+
+// Identifiers under these nodes are names of modules or declarations the index does not keep.
+var unresolvableParentTypes = map[string]bool{
+	"module":                    true,
+	"import_declaration":        true,
+	"path_ident":                true,
+	"module_resolution":         true,
+	"attributes":                true,
+	"generic_parameters":        true,
+	"generic_module_parameters": true,
+	"foreach_var":               true,
+}
+
+// FindProblems checks that the identifiers of the document refer to known symbols, that
+// its imports exist and are used, and that its local variables and private functions are used.
+func (s *Search) FindProblems(docId string, state *l.ProjectState) []Problem {
+	problems := []Problem{}
+	doc := state.GetDocument(docId)
+	unitModules := state.GetUnitModulesByDoc(docId)
+	if doc == nil || doc.SyntaxTree() == nil || unitModules == nil {
+		return problems
+	}
+
+	sourceCode := []byte(doc.SourceCode.Text)
+	declarations := map[symbols.Range]bool{}
+	for _, module := range unitModules.Modules() {
+		collectDeclarationRanges(module, declarations)
+	}
+
+	used := map[symbols.Indexable]bool{}
+	usedModules := map[string]bool{}
+
+	var walk func(node *sitter.Node)
+	walk = func(node *sitter.Node) {
+		// Syntax errors are already reported.
+		if node.IsError() {
+			return
+		}
+
+		if node.ChildCount() > 0 {
+			if node.Type() == "module_resolution" {
+				usedModules[strings.TrimSuffix(node.Content(sourceCode), "::")] = true
+			}
+			for i := 0; i < int(node.ChildCount()); i++ {
+				walk(node.Child(i))
+			}
+			return
+		}
+
+		if !identifierNodeTypes[node.Type()] || unresolvableParentTypes[node.Parent().Type()] {
+			return
+		}
+
+		nodeRange := symbols.NewRangeFromTreeSitterPositions(node.StartPoint(), node.EndPoint())
+		if declarations[nodeRange] {
+			return
+		}
+		// Members are resolved from the type of the expression before them, which might be unknown.
+		if previous := previousLeaf(node); previous != nil && previous.Type() == "." {
+			return
+		}
+
+		resolved := s.resolveReferenceCandidate(docId, nodeRange.Start, state)
+		if resolved.declaration.IsSome() {
+			used[resolved.declaration.Get()] = true
+			usedModules[resolved.declaration.Get().GetModuleString()] = true
+			return
+		}
+		// The search failed, the identifier might refer to anything.
+		if resolved.unknown {
+			return
+		}
+
+		// Named arguments and labels.
+		if next := nextLeaf(node); next != nil && next.Type() == ":" {
+			return
+		}
+		if !unreportedIdentifierNodeTypes[node.Type()] {
+			problems = append(problems, Problem{
+				Code:    ProblemUnresolvedIdentifier,
+				Range:   nodeRange,
+				Message: fmt.Sprintf("'%s' could not be found", node.Content(sourceCode)),
+			})
+		}
+	}
+	root := doc.SyntaxTree().RootNode()
+	walk(root)
+
+	problems = append(problems, s.findImportProblems(root, sourceCode, usedModules, state)...)
+
+	for _, module := range unitModules.Modules() {
+		for _, function := range module.ChildrenFunctions {
+			problems = append(problems, findUnusedVariables(function, used)...)
+
+			if isPrivateFunction(function, module) && function.GetName() != "main" &&
+				!s.isReferenced(function, module, docId, state) {
+				problems = append(problems, Problem{
+					Code:    ProblemUnusedFunction,
+					Range:   function.GetIdRange(),
+					Message: fmt.Sprintf("Private function '%s' is never used", function.GetName()),
+				})
+			}
+		}
+	}
+
+	slices.SortFunc(problems, func(a, b Problem) int {
+		return cmp.Or(
+			cmp.Compare(a.Range.Start.Line, b.Range.Start.Line),
+			cmp.Compare(a.Range.Start.Character, b.Range.Start.Character),
+		)
+	})
+
+	return problems
+}
+
+// findImportProblems reports imports of modules that do not exist, or that nothing in the document uses.
+func (s *Search) findImportProblems(root *sitter.Node, sourceCode []byte, usedModules map[string]bool, state *l.ProjectState) []Problem {
+	problems := []Problem{}
+
+	knownModules := map[string]bool{}
+	for _, unitModules := range state.GetAllUnitModules() {
+		for _, module := range unitModules.Modules() {
+			knownModules[module.GetName()] = true
+		}
+	}
+
+	for i := 0; i < int(root.ChildCount()); i++ {
+		declaration := root.Child(i)
+		if declaration.Type() != "import_declaration" {
+			continue
+		}
+
+		for j := 0; j < int(declaration.ChildCount()); j++ {
+			path := declaration.Child(j)
+			if path.Type() != "path_ident" {
+				continue
+			}
+
+			name := path.Content(sourceCode)
+			pathRange := symbols.NewRangeFromTreeSitterPositions(path.StartPoint(), path.EndPoint())
+			switch {
+			case !matchesAnyModule(name, knownModules):
+				problems = append(problems, Problem{
+					Code:    ProblemUnknownModule,
+					Range:   pathRange,
+					Message: fmt.Sprintf("Module '%s' could not be found", name),
+				})
+			case !matchesAnyModule(name, usedModules):
+				problems = append(problems, Problem{
+					Code:    ProblemUnusedImport,
+					Range:   pathRange,
+					Message: fmt.Sprintf("Module '%s' is imported but never used", name),
+				})
+			}
+		}
+	}
+
+	return problems
+}
+
+// matchesAnyModule tells if module, or one of its submodules, is in modules.
+// Modules used through a partial path, like `io::` for `std::io`, also match.
+func matchesAnyModule(module string, modules map[string]bool) bool {
+	for name := range modules {
+		if name == module || strings.HasPrefix(name, module+"::") || strings.HasSuffix(module, "::"+name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func findUnusedVariables(function *symbols.Function, used map[symbols.Indexable]bool) []Problem {
+	problems := []Problem{}
+	arguments := map[string]bool{}
+	for _, argument := range function.ArgumentIds() {
+		arguments[argument] = true
+	}
+
+	for name, variable := range function.Variables {
+		if arguments[name] || used[variable] {
+			continue
+		}
+
+		problems = append(problems, Problem{
+			Code:    ProblemUnusedVariable,
+			Range:   variable.GetIdRange(),
+			Message: fmt.Sprintf("Variable '%s' is never used", name),
+		})
+	}
+
+	return problems
+}
+
+// isReferenced tells if the private function is used. Only documents where it is visible are
+// searched, stopping at the first reference: the document for @local functions, or those of
+// its module and submodules otherwise.
+func (s *Search) isReferenced(function *symbols.Function, module *symbols.Module, docId string, state *l.ProjectState) bool {
+	name := referenceName(function)
+	for candidateDocId, unitModules := range state.GetAllUnitModules() {
+		if !canSeePrivateSymbols(candidateDocId, unitModules, function, module, docId) {
+			continue
+		}
+		doc := state.GetDocument(candidateDocId)
+		if doc == nil {
+			continue
+		}
+
+		for _, candidate := range findIdentifiersByName(doc, name) {
+			if candidateDocId == function.GetDocumentURI() && candidate == function.GetIdRange() {
+				continue
+			}
+
+			resolved := s.resolveReferenceCandidate(candidateDocId, candidate.Start, state)
+			// Functions are only reported when they are known to be unused.
+			if resolved.unknown || resolved.refersTo(function) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// canSeePrivateSymbols tells if the candidate document can use the private function declared in
+// module of docId. Functions of private modules are looked for in every document.
+func canSeePrivateSymbols(candidateDocId string, unitModules symbols_table.UnitModules, function *symbols.Function, module *symbols.Module, docId string) bool {
+	if slices.Contains(function.GetAttributes(), "@local") {
+		return candidateDocId == docId
+	}
+	if module.IsPrivate() {
+		return true
+	}
+
+	for _, candidate := range unitModules.Modules() {
+		if candidate.GetName() == module.GetName() || strings.HasPrefix(candidate.GetName(), module.GetName()+"::") {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isPrivateFunction(function *symbols.Function, module *symbols.Module) bool {
+	if module.IsPrivate() {
+		return true
+	}
+
+	for _, attribute := range function.GetAttributes() {
+		if attribute == "@private" || attribute == "@local" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// collectDeclarationRanges registers the identifier range of symbol and of the symbols declared inside it.
+func collectDeclarationRanges(symbol symbols.Indexable, ranges map[symbols.Range]bool) {
+	ranges[symbol.GetIdRange()] = true
+	for _, child := range symbol.Children() {
+		collectDeclarationRanges(child, ranges)
+	}
+	for _, scope := range symbol.NestedScopes() {
+		collectDeclarationRanges(scope, ranges)
+	}
+}
+
+func previousLeaf(node *sitter.Node) *sitter.Node {
+	for node != nil && node.PrevSibling() == nil {
+		node = node.Parent()
+	}
+	if node == nil {
+		return nil
+	}
+
+	node = node.PrevSibling()
+	for node.ChildCount() > 0 {
+		node = node.Child(int(node.ChildCount()) - 1)
+	}
+
+	return node
+}
+
+func nextLeaf(node *sitter.Node) *sitter.Node {
+	for node != nil && node.NextSibling() == nil {
+		node = node.Parent()
+	}
+	if node == nil {
+		return nil
+	}
+
+	node = node.NextSibling()
+	for node.ChildCount() > 0 {
+		node = node.Child(0)
+	}
+
+	return node
+}
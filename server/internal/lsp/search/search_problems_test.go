@@ -0,0 +1,130 @@
+package search
+
+import (
+	"testing"
+
+	"github.com/pherrymason/c3-lsp/pkg/symbols"
+	"github.com/stretchr/testify/assert"
+)
+
+func problemCodes(problems []Problem) []string {
+	codes := []string{}
+	for _, problem := range problems {
+		codes = append(codes, problem.Code)
+	}
+
+	return codes
+}
+
+func TestFindProblems_reports_nothing_for_valid_code(t *testing.T) {
+	state := NewTestState()
+	state.registerDoc(
+		"app.c3",
+		`module app;
+		struct Point { int x; }
+		fn int sum(Point p, int y) { return p.x + y; }
+		fn void main() {
+			Point p = { .x = 1 };
+			sum(p, 2);
+		}`,
+	)
+	search := NewSearchWithoutLog()
+
+	problems := search.FindProblems("app.c3", &state.state)
+
+	assert.Empty(t, problems)
+}
+
+func TestFindProblems_reports_unresolved_identifiers(t *testing.T) {
+	state := NewTestState()
+	state.registerDoc(
+		"app.c3",
+		`module app;
+		fn void main() {
+			missing(1);
+		}`,
+	)
+	search := NewSearchWithoutLog()
+
+	problems := search.FindProblems("app.c3", &state.state)
+
+	assert.Equal(t, []Problem{{
+		Code:    ProblemUnresolvedIdentifier,
+		Range:   symbols.NewRange(2, 3, 2, 10),
+		Message: "'missing' could not be found",
+	}}, problems)
+}
+
+func TestFindProblems_reports_unknown_and_unused_imports(t *testing.T) {
+	state := NewTestState()
+	state.registerDoc(
+		"lib.c3",
+		`module lib;
+		fn void help() {}`,
+	)
+	state.registerDoc(
+		"app.c3",
+		`module app;
+		import lib, nowhere;
+		fn void main() {}`,
+	)
+	search := NewSearchWithoutLog()
+
+	problems := search.FindProblems("app.c3", &state.state)
+
+	assert.Equal(t, []string{ProblemUnusedImport, ProblemUnknownModule}, problemCodes(problems))
+	assert.Equal(t, symbols.NewRange(1, 9, 1, 12), problems[0].Range)
+	assert.Equal(t, symbols.NewRange(1, 14, 1, 21), problems[1].Range)
+}
+
+func TestFindProblems_reports_unused_variables_and_private_functions(t *testing.T) {
+	state := NewTestState()
+	state.registerDoc(
+		"app.c3",
+		`module app;
+		fn void helper() @private {}
+		fn void used() @private {}
+		fn void main() {
+			int unused = 1;
+			used();
+		}`,
+	)
+	search := NewSearchWithoutLog()
+
+	problems := search.FindProblems("app.c3", &state.state)
+
+	assert.Equal(t, []string{ProblemUnusedFunction, ProblemUnusedVariable}, problemCodes(problems))
+	assert.Equal(t, "Private function 'helper' is never used", problems[0].Message)
+	assert.Equal(t, "Variable 'unused' is never used", problems[1].Message)
+}
+
+func TestFindProblems_finds_private_functions_used_by_other_documents_of_the_module(t *testing.T) {
+	state := NewTestState()
+	state.registerDoc(
+		"helpers.c3",
+		`module app;
+		fn void helper() @private {}
+		fn void unused() @private {}`,
+	)
+	state.registerDoc(
+		"app.c3",
+		`module app;
+		fn void main() {
+			helper();
+		}`,
+	)
+	state.registerDoc(
+		"other.c3",
+		`module other;
+		fn void unused() {}
+		fn void run() {
+			unused();
+		}`,
+	)
+	search := NewSearchWithoutLog()
+
+	problems := search.FindProblems("helpers.c3", &state.state)
+
+	assert.Equal(t, []string{ProblemUnusedFunction}, problemCodes(problems))
+	assert.Equal(t, "Private function 'unused' is never used", problems[0].Message)
+}
@@ -0,0 +1,67 @@
+package search
+
+import (
+	"errors"
+	"fmt"
+
+	l "github.com/pherrymason/c3-lsp/internal/lsp/project_state"
+	"github.com/pherrymason/c3-lsp/pkg/c3"
+	"github.com/pherrymason/c3-lsp/pkg/symbols"
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+var ErrNothingToRename = errors.New("no symbol to rename at this position")
+
+// FindRenameTarget resolves the symbol under cursor and checks it can be renamed.
+// Returns the declaration of the symbol and the range of the identifier under cursor.
+func (s *Search) FindRenameTarget(docId string, position symbols.Position, state *l.ProjectState) (symbols.Indexable, symbols.Range, error) {
+	doc := state.GetDocument(docId)
+	if doc == nil || doc.SyntaxTree() == nil {
+		return nil, symbols.Range{}, ErrNothingToRename
+	}
+
+	point := sitter.Point{Row: uint32(position.Line), Column: uint32(position.Character)}
+	node := doc.SyntaxTree().RootNode().NamedDescendantForPointRange(point, point)
+	if node == nil || !identifierNodeTypes[node.Type()] {
+		return nil, symbols.Range{}, ErrNothingToRename
+	}
+
+	name := node.Content([]byte(doc.SourceCode.Text))
+	if c3.IsLanguageKeyword(name) {
+		return nil, symbols.Range{}, fmt.Errorf("%s is a language keyword and cannot be renamed", name)
+	}
+
+	declarationOption := s.resolveReferenceCandidate(docId, position, state).declaration
+	if declarationOption.IsNone() {
+		return nil, symbols.Range{}, fmt.Errorf("could not find the declaration of %s", name)
+	}
+
+	declaration := declarationOption.Get()
+	if !declaration.HasSourceCode() || state.IsStdlibDocument(declaration.GetDocumentURI()) {
+		return nil, symbols.Range{}, fmt.Errorf("%s is declared in the standard library and cannot be renamed", name)
+	}
+	if state.IsLibraryDocument(declaration.GetDocumentURI()) {
+		return nil, symbols.Range{}, fmt.Errorf("%s is declared in a library and cannot be renamed", name)
+	}
+	if _, ok := declaration.(*symbols.Module); ok {
+		return nil, symbols.Range{}, errors.New("renaming modules is not supported")
+	}
+
+	return declaration, symbols.NewRangeFromTreeSitterPositions(node.StartPoint(), node.EndPoint()), nil
+}
+
+// Rename returns the locations to replace with `newName` in order to rename the
+// symbol under cursor across the workspace, including its declaration.
+func (s *Search) Rename(docId string, position symbols.Position, newName string, state *l.ProjectState) ([]Reference, error) {
+	declaration, _, err := s.FindRenameTarget(docId, position, state)
+	if err != nil {
+		return nil, err
+	}
+
+	oldName := referenceName(declaration)
+	if c3.IdentifierClass(newName) == "" || c3.IdentifierClass(newName) != c3.IdentifierClass(oldName) {
+		return nil, fmt.Errorf("%s is not a valid name to replace %s", newName, oldName)
+	}
+
+	return s.FindSymbolReferences(declaration, state, true), nil
+}
@@ -0,0 +1,97 @@
+package search
+
+import (
+	"testing"
+
+	"github.com/pherrymason/c3-lsp/pkg/symbols"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindRenameTarget(t *testing.T) {
+	state := NewTestState()
+	state.registerDoc(
+		"app.c3",
+		`module app;
+		fault IOResult { IO_ERROR }
+		fn void main() {
+			int value = 1;
+			anyfault err = IOResult.IO_ERROR;
+		}`,
+	)
+	search := NewSearchWithoutLog()
+
+	t.Run("returns range of identifier under cursor", func(t *testing.T) {
+		declaration, identifierRange, err := search.FindRenameTarget("app.c3", buildPosition(4, 8), &state.state)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "value", declaration.GetName())
+		assert.Equal(t, symbols.NewRange(3, 7, 3, 12), identifierRange)
+	})
+
+	t.Run("allows renaming fault constants", func(t *testing.T) {
+		declaration, _, err := search.FindRenameTarget("app.c3", buildPosition(5, 29), &state.state)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "IO_ERROR", declaration.GetName())
+	})
+
+	t.Run("refuses positions without identifier", func(t *testing.T) {
+		_, _, err := search.FindRenameTarget("app.c3", buildPosition(4, 13), &state.state)
+
+		assert.Equal(t, ErrNothingToRename, err)
+	})
+}
+
+func TestRename(t *testing.T) {
+	state := NewTestState()
+	state.registerDoc(
+		"app.c3",
+		`module app;
+		import util;
+		fn void main() {
+			Point p;
+			p.move(1);
+			util::run();
+		}`,
+	)
+	state.registerDoc(
+		"util.c3",
+		`module util;
+		struct Point { int x; }
+		fn void Point.move(Point* self, int dx) { self.x += dx; }
+		fn void run() {}`,
+	)
+	search := NewSearchWithoutLog()
+
+	t.Run("renames methods", func(t *testing.T) {
+		references, err := search.Rename("util.c3", buildPosition(3, 18), "translate", &state.state)
+
+		assert.Nil(t, err)
+		assert.Equal(t, []Reference{
+			{DocId: "app.c3", Range: symbols.NewRange(4, 5, 4, 9)},
+			{DocId: "util.c3", Range: symbols.NewRange(2, 16, 2, 20)},
+		}, references)
+	})
+
+	t.Run("renames module qualified functions", func(t *testing.T) {
+		references, err := search.Rename("app.c3", buildPosition(6, 10), "start", &state.state)
+
+		assert.Nil(t, err)
+		assert.Equal(t, []Reference{
+			{DocId: "app.c3", Range: symbols.NewRange(5, 9, 5, 12)},
+			{DocId: "util.c3", Range: symbols.NewRange(3, 10, 3, 13)},
+		}, references)
+	})
+
+	t.Run("refuses names of a different identifier kind", func(t *testing.T) {
+		_, err := search.Rename("util.c3", buildPosition(4, 11), "Run", &state.state)
+
+		assert.NotNil(t, err)
+	})
+
+	t.Run("refuses keywords", func(t *testing.T) {
+		_, err := search.Rename("util.c3", buildPosition(4, 11), "while", &state.state)
+
+		assert.NotNil(t, err)
+	})
+}
@@ -0,0 +1,278 @@
+package search
+
+import (
+	"cmp"
+	"slices"
+	"sync"
+
+	l "github.com/pherrymason/c3-lsp/internal/lsp/project_state"
+	"github.com/pherrymason/c3-lsp/pkg/document"
+	"github.com/pherrymason/c3-lsp/pkg/option"
+	"github.com/pherrymason/c3-lsp/pkg/symbols"
+	"github.com/pherrymason/c3-lsp/pkg/symbols_table"
+	sitter "github.com/smacker/go-tree-sitter"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// SemanticToken classifies an identifier of a document using the symbol it refers to.
+type SemanticToken struct {
+	Range     symbols.Range
+	Type      protocol.SemanticTokenType
+	Modifiers []protocol.SemanticTokenModifier
+}
+
+// BuildSemanticTokens classifies every identifier of the document, or only those inside
+// `limit` if given. Identifiers that cannot be resolved to a symbol are left out, so the
+// editor keeps its own syntax highlighting for them. Tokens are kept until the document or
+// the symbols of the workspace change.
+func (s *Search) BuildSemanticTokens(docId string, state *l.ProjectState, limit option.Option[symbols.Range]) []SemanticToken {
+	doc := state.GetDocument(docId)
+	if doc == nil || doc.SyntaxTree() == nil {
+		return []SemanticToken{}
+	}
+
+	revision := state.Revision()
+	key := semanticTokensKey{folder: state.Folder(), docId: docId}
+	tokens, ok := s.semanticTokens.get(key, doc, revision)
+	if !ok {
+		tokens = s.classifyIdentifiers(doc, state)
+		s.semanticTokens.set(key, doc, revision, tokens)
+	}
+
+	inside := []SemanticToken{}
+	for _, token := range tokens {
+		// Skip tokens ending before or starting after the requested range.
+		if limit.IsSome() && (token.Range.IsAfterPosition(limit.Get().Start) || token.Range.IsBeforePosition(limit.Get().End)) {
+			continue
+		}
+		inside = append(inside, token)
+	}
+
+	return inside
+}
+
+func (s *Search) classifyIdentifiers(doc *document.Document, state *l.ProjectState) []SemanticToken {
+	tokens := []SemanticToken{}
+	locals := newLocalSymbols(doc.URI, state.GetUnitModulesByDoc(doc.URI))
+	sourceCode := []byte(doc.SourceCode.Text)
+
+	var walk func(node *sitter.Node)
+	walk = func(node *sitter.Node) {
+		if node.ChildCount() > 0 {
+			for i := 0; i < int(node.ChildCount()); i++ {
+				walk(node.Child(i))
+			}
+			return
+		}
+
+		if !identifierNodeTypes[node.Type()] {
+			return
+		}
+
+		nodeRange := symbols.NewRangeFromTreeSitterPositions(node.StartPoint(), node.EndPoint())
+		parentType := node.Parent().Type()
+		if parentType == "module_resolution" || parentType == "path_ident" {
+			tokens = append(tokens, SemanticToken{Range: nodeRange, Type: protocol.SemanticTokenTypeNamespace})
+			return
+		}
+
+		symbol := locals.find(node, nodeRange, sourceCode)
+		if symbol == nil {
+			resolved := s.resolveReferenceCandidate(doc.URI, nodeRange.Start, state)
+			if resolved.declaration.IsNone() {
+				return
+			}
+			symbol = resolved.declaration.Get()
+		}
+
+		tokenType, ok := semanticTokenType(symbol, locals.parameters)
+		if !ok {
+			return
+		}
+
+		tokens = append(tokens, SemanticToken{
+			Range:     nodeRange,
+			Type:      tokenType,
+			Modifiers: semanticTokenModifiers(symbol, doc.URI, nodeRange, !symbol.HasSourceCode() || state.IsStdlibDocument(symbol.GetDocumentURI())),
+		})
+	}
+	walk(doc.SyntaxTree().RootNode())
+
+	slices.SortFunc(tokens, func(a, b SemanticToken) int {
+		return cmp.Or(
+			cmp.Compare(a.Range.Start.Line, b.Range.Start.Line),
+			cmp.Compare(a.Range.Start.Character, b.Range.Start.Character),
+		)
+	})
+
+	return tokens
+}
+
+// localSymbols are the symbols declared in a document, taken from its own unit modules to
+// classify their identifiers without searching the workspace.
+type localSymbols struct {
+	declarations map[symbols.Range]symbols.Indexable
+	functions    []*symbols.Function
+	parameters   map[symbols.Indexable]bool
+}
+
+func newLocalSymbols(docId string, unitModules *symbols_table.UnitModules) localSymbols {
+	locals := localSymbols{
+		declarations: map[symbols.Range]symbols.Indexable{},
+		parameters:   map[symbols.Indexable]bool{},
+	}
+
+	var collect func(symbol symbols.Indexable)
+	collect = func(symbol symbols.Indexable) {
+		if symbol.GetDocumentURI() == docId {
+			locals.declarations[symbol.GetIdRange()] = symbol
+		}
+		if function, ok := symbol.(*symbols.Function); ok {
+			locals.functions = append(locals.functions, function)
+			for _, argument := range function.GetArguments() {
+				locals.parameters[argument] = true
+			}
+		}
+
+		for _, child := range symbol.Children() {
+			collect(child)
+		}
+		for _, scope := range symbol.NestedScopes() {
+			collect(scope)
+		}
+	}
+	for _, module := range unitModules.Modules() {
+		collect(module)
+	}
+
+	return locals
+}
+
+// find returns the symbol declared by identifier, or the variable of the enclosing function
+// it refers to. Variables cannot be shadowed in C3, so their name is enough. Nil when it is
+// neither and has to be searched in the workspace.
+func (l localSymbols) find(identifier *sitter.Node, identifierRange symbols.Range, sourceCode []byte) symbols.Indexable {
+	if symbol, ok := l.declarations[identifierRange]; ok {
+		return symbol
+	}
+
+	// Members are searched in the type of what they are accessed from.
+	if previous := identifier.PrevSibling(); previous != nil && previous.Type() == "." {
+		return nil
+	}
+
+	for _, function := range l.functions {
+		if !function.GetDocumentRange().HasPosition(identifierRange.Start) {
+			continue
+		}
+
+		variable, ok := function.Variables[identifier.Content(sourceCode)]
+		if ok && variable != nil && !variable.GetIdRange().IsBeforePosition(identifierRange.Start) {
+			return variable
+		}
+	}
+
+	return nil
+}
+
+// semanticTokensCache keeps the tokens of documents while they and the symbols of the workspace
+// do not change.
+type semanticTokensCache struct {
+	mutex  sync.Mutex
+	tokens map[semanticTokensKey]documentTokens
+}
+
+type semanticTokensKey struct {
+	// Folders see different stdlib symbols.
+	folder string
+	docId  string
+}
+
+type documentTokens struct {
+	document *document.Document
+	revision uint64
+	tokens   []SemanticToken
+}
+
+func (c *semanticTokensCache) get(key semanticTokensKey, doc *document.Document, revision uint64) ([]SemanticToken, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	cached, ok := c.tokens[key]
+	if !ok || cached.document != doc || cached.revision != revision {
+		return nil, false
+	}
+
+	return cached.tokens, true
+}
+
+func (c *semanticTokensCache) set(key semanticTokensKey, doc *document.Document, revision uint64, tokens []SemanticToken) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.tokens == nil {
+		c.tokens = map[semanticTokensKey]documentTokens{}
+	}
+	c.tokens[key] = documentTokens{document: doc, revision: revision, tokens: tokens}
+}
+
+func semanticTokenType(symbol symbols.Indexable, parameters map[symbols.Indexable]bool) (protocol.SemanticTokenType, bool) {
+	switch sym := symbol.(type) {
+	case *symbols.Module:
+		return protocol.SemanticTokenTypeNamespace, true
+	case *symbols.Struct, *symbols.Bitstruct:
+		return protocol.SemanticTokenTypeStruct, true
+	case *symbols.Enum, *symbols.Fault:
+		return protocol.SemanticTokenTypeEnum, true
+	case *symbols.Enumerator, *symbols.FaultConstant:
+		return protocol.SemanticTokenTypeEnumMember, true
+	case *symbols.Interface:
+		return protocol.SemanticTokenTypeInterface, true
+	case *symbols.Def:
+		if sym.ResolvesToType() {
+			return protocol.SemanticTokenTypeType, true
+		}
+		return protocol.SemanticTokenTypeFunction, true
+	case *symbols.GenericParameter:
+		return protocol.SemanticTokenTypeTypeParameter, true
+	case *symbols.StructMember:
+		return protocol.SemanticTokenTypeProperty, true
+	case *symbols.Function:
+		switch sym.FunctionType() {
+		case symbols.Macro:
+			return protocol.SemanticTokenTypeMacro, true
+		case symbols.Method:
+			return protocol.SemanticTokenTypeMethod, true
+		}
+		return protocol.SemanticTokenTypeFunction, true
+	case *symbols.Variable:
+		if parameters[sym] {
+			return protocol.SemanticTokenTypeParameter, true
+		}
+		return protocol.SemanticTokenTypeVariable, true
+	}
+
+	return "", false
+}
+
+func semanticTokenModifiers(symbol symbols.Indexable, docId string, tokenRange symbols.Range, defaultLibrary bool) []protocol.SemanticTokenModifier {
+	modifiers := []protocol.SemanticTokenModifier{}
+	if symbol.GetDocumentURI() == docId && symbol.GetIdRange() == tokenRange {
+		modifiers = append(modifiers, protocol.SemanticTokenModifierDeclaration)
+	}
+
+	switch sym := symbol.(type) {
+	case *symbols.Variable:
+		if sym.IsConstant() {
+			modifiers = append(modifiers, protocol.SemanticTokenModifierReadonly)
+		}
+	case *symbols.Enumerator, *symbols.FaultConstant:
+		modifiers = append(modifiers, protocol.SemanticTokenModifierReadonly)
+	}
+
+	if defaultLibrary {
+		modifiers = append(modifiers, protocol.SemanticTokenModifierDefaultLibrary)
+	}
+
+	return modifiers
+}
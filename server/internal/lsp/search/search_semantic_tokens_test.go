@@ -0,0 +1,102 @@
+package search
+
+import (
+	"testing"
+
+	"github.com/pherrymason/c3-lsp/pkg/option"
+	"github.com/pherrymason/c3-lsp/pkg/symbols"
+	"github.com/stretchr/testify/assert"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func TestBuildSemanticTokens(t *testing.T) {
+	state := NewTestState()
+	state.registerDoc(
+		"app.c3",
+		`module app;
+		const int MAX = 3;
+		enum Color { RED }
+		fn int twice(int value) {
+			Color c = Color.RED;
+			return value * MAX;
+		}`,
+	)
+	search := NewSearchWithoutLog()
+
+	tokens := search.BuildSemanticTokens("app.c3", &state.state, option.None[symbols.Range]())
+
+	tokenAt := func(line uint, character uint) SemanticToken {
+		for _, token := range tokens {
+			if token.Range.Start == symbols.NewPosition(line, character) {
+				return token
+			}
+		}
+		t.Fatalf("no token found at %d:%d", line, character)
+		return SemanticToken{}
+	}
+
+	maxDeclaration := tokenAt(1, 12)
+	assert.Equal(t, protocol.SemanticTokenTypeVariable, maxDeclaration.Type)
+	assert.Equal(t, []protocol.SemanticTokenModifier{
+		protocol.SemanticTokenModifierDeclaration,
+		protocol.SemanticTokenModifierReadonly,
+	}, maxDeclaration.Modifiers)
+
+	assert.Equal(t, protocol.SemanticTokenTypeEnum, tokenAt(2, 7).Type)
+	assert.Equal(t, protocol.SemanticTokenTypeEnumMember, tokenAt(2, 15).Type)
+	assert.Equal(t, protocol.SemanticTokenTypeFunction, tokenAt(3, 9).Type)
+	assert.Equal(t, protocol.SemanticTokenTypeParameter, tokenAt(3, 19).Type)
+	assert.Equal(t, protocol.SemanticTokenTypeEnum, tokenAt(4, 3).Type)
+	assert.Equal(t, protocol.SemanticTokenTypeEnumMember, tokenAt(4, 19).Type)
+	assert.Equal(t, protocol.SemanticTokenTypeParameter, tokenAt(5, 10).Type)
+	assert.Equal(t, []protocol.SemanticTokenModifier{protocol.SemanticTokenModifierReadonly}, tokenAt(5, 18).Modifiers)
+}
+
+func TestBuildSemanticTokens_in_range(t *testing.T) {
+	state := NewTestState()
+	state.registerDoc(
+		"app.c3",
+		`module app;
+		int first = 1;
+		int second = 2;`,
+	)
+	search := NewSearchWithoutLog()
+
+	tokens := search.BuildSemanticTokens("app.c3", &state.state, option.Some(symbols.NewRange(2, 0, 2, 20)))
+
+	assert.Equal(t, 1, len(tokens))
+	assert.Equal(t, symbols.NewRange(2, 6, 2, 12), tokens[0].Range)
+}
+
+func TestBuildSemanticTokens_classifies_local_variables(t *testing.T) {
+	state := NewTestState()
+	state.registerDoc(
+		"app.c3",
+		`module app;
+		struct Point { int x; }
+		fn void main() {
+			Point x;
+			x.x = 1;
+		}`,
+	)
+	search := NewSearchWithoutLog()
+
+	tokens := search.BuildSemanticTokens("app.c3", &state.state, option.Some(symbols.NewRange(4, 0, 4, 20)))
+
+	assert.Equal(t, 2, len(tokens))
+	assert.Equal(t, protocol.SemanticTokenTypeVariable, tokens[0].Type)
+	assert.Equal(t, protocol.SemanticTokenTypeProperty, tokens[1].Type)
+}
+
+func TestBuildSemanticTokens_classifies_changed_documents_again(t *testing.T) {
+	state := NewTestState()
+	state.registerDoc("app.c3", "module app;\nint value = 1;")
+	search := NewSearchWithoutLog()
+
+	first := search.BuildSemanticTokens("app.c3", &state.state, option.None[symbols.Range]())
+	state.registerDoc("app.c3", "module app;\nconst int VALUE = 1;")
+	second := search.BuildSemanticTokens("app.c3", &state.state, option.None[symbols.Range]())
+
+	assert.Equal(t, symbols.NewRange(1, 4, 1, 9), first[0].Range)
+	assert.Equal(t, symbols.NewRange(1, 10, 1, 15), second[0].Range)
+}
@@ -0,0 +1,183 @@
+package search
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+	"unicode"
+
+	l "github.com/pherrymason/c3-lsp/internal/lsp/project_state"
+	_prot "github.com/pherrymason/c3-lsp/internal/lsp/protocol"
+	"github.com/pherrymason/c3-lsp/pkg/option"
+	"github.com/pherrymason/c3-lsp/pkg/symbols"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// Maximum number of results returned to the editor on a workspace symbol query.
+const maxWorkspaceSymbols = 256
+
+// Fuzzy match ranks, from best to worst.
+const (
+	matchExact = iota
+	matchPrefix
+	matchCamelHumps
+	matchSubsequence
+	matchSubsequenceInFQN
+	noMatch
+)
+
+// FindWorkspaceSymbols returns the indexed symbols matching `query`, best matches first.
+// Matching is case insensitive and ignores separators, so `listpush` finds `List.push`.
+// Symbols are ranked: exact matches, then prefix, camel humps and subsequence matches.
+func (s *Search) FindWorkspaceSymbols(query string, state *l.ProjectState) []symbols.Indexable {
+	type rankedSymbol struct {
+		symbol symbols.Indexable
+		rank   int
+	}
+
+	ranked := []rankedSymbol{}
+	for _, symbol := range state.IndexedSymbols() {
+		rank := fuzzyMatchRank(query, symbol.GetName(), symbol.GetFQN())
+		if rank != noMatch {
+			ranked = append(ranked, rankedSymbol{symbol: symbol, rank: rank})
+		}
+	}
+
+	slices.SortFunc(ranked, func(a, b rankedSymbol) int {
+		return cmp.Or(
+			cmp.Compare(a.rank, b.rank),
+			cmp.Compare(len(a.symbol.GetName()), len(b.symbol.GetName())),
+			cmp.Compare(a.symbol.GetFQN(), b.symbol.GetFQN()),
+		)
+	})
+
+	result := make([]symbols.Indexable, len(ranked))
+	for i, r := range ranked {
+		result[i] = r.symbol
+	}
+
+	return result
+}
+
+// BuildWorkspaceSymbols returns the best symbols matching `query` ready to be sent to the editor.
+// Stdlib symbols are only listed when their sources can be reached through `stdlibPath`.
+func (s *Search) BuildWorkspaceSymbols(query string, state *l.ProjectState, stdlibPath option.Option[string]) []protocol.SymbolInformation {
+	symbolInformation := []protocol.SymbolInformation{}
+	for _, symbol := range s.FindWorkspaceSymbols(query, state) {
+		if !symbol.HasSourceCode() && stdlibPath.IsNone() {
+			continue
+		}
+
+		containerName := symbol.GetModuleString()
+		symbolInformation = append(symbolInformation, protocol.SymbolInformation{
+			Name: symbol.GetName(),
+			Kind: toSymbolKind(symbol),
+			Location: protocol.Location{
+				URI:   state.DocumentURI(symbol.GetDocumentURI(), stdlibPath),
+				Range: _prot.Lsp_NewRangeFromRange(symbol.GetIdRange()),
+			},
+			ContainerName: &containerName,
+		})
+		if len(symbolInformation) == maxWorkspaceSymbols {
+			break
+		}
+	}
+
+	return symbolInformation
+}
+
+func fuzzyMatchRank(query string, name string, fqn string) int {
+	lowerQuery := normalizeSymbolQuery(query)
+	if lowerQuery == "" {
+		return matchSubsequenceInFQN
+	}
+
+	lowerName := normalizeSymbolQuery(name)
+	switch {
+	case lowerName == lowerQuery:
+		return matchExact
+	case strings.HasPrefix(lowerName, lowerQuery):
+		return matchPrefix
+	case matchesCamelHumps(lowerQuery, name):
+		return matchCamelHumps
+	case isSubsequence(lowerQuery, lowerName):
+		return matchSubsequence
+	case isSubsequence(lowerQuery, normalizeSymbolQuery(fqn)):
+		return matchSubsequenceInFQN
+	}
+
+	return noMatch
+}
+
+// normalizeSymbolQuery lowercases text and removes the separators of symbol paths.
+func normalizeSymbolQuery(text string) string {
+	return strings.Map(func(r rune) rune {
+		if r == ':' || r == '.' || r == '_' || unicode.IsSpace(r) {
+			return -1
+		}
+		return unicode.ToLower(r)
+	}, text)
+}
+
+// matchesCamelHumps checks if every chunk of `query` matches the beginning of a word
+// of `name`, in order. Words start at uppercase letters and after separators:
+// `gpt` and `getpoty` match `getPointerType`.
+func matchesCamelHumps(query string, name string) bool {
+	words := splitWords(name)
+
+	var match func(query string, words []string) bool
+	match = func(query string, words []string) bool {
+		if query == "" {
+			return true
+		}
+		if len(words) == 0 {
+			return false
+		}
+
+		word := strings.ToLower(words[0])
+		for i := 1; i <= len(word) && i <= len(query) && query[i-1] == word[i-1]; i++ {
+			if match(query[i:], words[1:]) {
+				return true
+			}
+		}
+
+		return match(query, words[1:])
+	}
+
+	return match(query, words)
+}
+
+func splitWords(name string) []string {
+	words := []string{}
+	current := []rune{}
+	previous := rune(0)
+	for _, r := range name {
+		isSeparator := r == ':' || r == '.' || r == '_'
+		startsWord := unicode.IsUpper(r) && !unicode.IsUpper(previous)
+		if (isSeparator || startsWord) && len(current) > 0 {
+			words = append(words, string(current))
+			current = []rune{}
+		}
+		if !isSeparator {
+			current = append(current, r)
+		}
+		previous = r
+	}
+	if len(current) > 0 {
+		words = append(words, string(current))
+	}
+
+	return words
+}
+
+func isSubsequence(query string, text string) bool {
+	queryRunes := []rune(query)
+	i := 0
+	for _, r := range text {
+		if i < len(queryRunes) && queryRunes[i] == r {
+			i++
+		}
+	}
+
+	return i == len(queryRunes)
+}
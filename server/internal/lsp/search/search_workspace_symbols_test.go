@@ -0,0 +1,61 @@
+package search
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFuzzyMatchRank(t *testing.T) {
+	cases := []struct {
+		query    string
+		name     string
+		fqn      string
+		expected int
+	}{
+		{"push", "push", "app::push", matchExact},
+		{"listpush", "List.push", "std::collections::list::List.push", matchExact},
+		{"List.pu", "List.push", "std::collections::list::List.push", matchPrefix},
+		{"gpt", "getPointerType", "app::getPointerType", matchCamelHumps},
+		{"getpoty", "getPointerType", "app::getPointerType", matchCamelHumps},
+		{"gtp", "getPointerType", "app::getPointerType", matchSubsequence},
+		{"collpush", "List.push", "std::collections::list::List.push", matchSubsequenceInFQN},
+		{"xyz", "List.push", "std::collections::list::List.push", noMatch},
+		{"cfé", "cafeCrème", "app::cafeCrème", matchSubsequence},
+		{"ée", "cafeCreme", "app::cafeCreme", noMatch},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.query, func(t *testing.T) {
+			assert.Equal(t, tt.expected, fuzzyMatchRank(tt.query, tt.name, tt.fqn))
+		})
+	}
+}
+
+func TestFindWorkspaceSymbols(t *testing.T) {
+	state := NewTestState()
+	state.registerDoc(
+		"app.c3",
+		`module app;
+		fn void process() {}
+		fn void process_all() {}
+		fn void pre_process() {}`,
+	)
+	search := NewSearchWithoutLog()
+
+	found := search.FindWorkspaceSymbols("process", &state.state)
+
+	assert.Equal(t, 3, len(found))
+	assert.Equal(t, "process", found[0].GetName())
+	assert.Equal(t, "process_all", found[1].GetName())
+	assert.Equal(t, "pre_process", found[2].GetName())
+}
+
+func TestFindWorkspaceSymbols_finds_stdlib_methods(t *testing.T) {
+	state := NewTestStateWithStdLibVersion("0.6.2")
+	search := NewSearchWithoutLog()
+
+	found := search.FindWorkspaceSymbols("listpush", &state.state)
+
+	assert.Equal(t, "std::collections::list::List.push", found[0].GetFQN())
+}
@@ -1,143 +1,370 @@
 package server
 
 import (
+	"fmt"
 	"log"
-	"strconv"
+	"os"
 	"strings"
+	"time"
+	"unicode/utf16"
 
+	"github.com/bep/debounce"
 	"github.com/pherrymason/c3-lsp/internal/c3c"
+	"github.com/pherrymason/c3-lsp/internal/lsp/diagnostics"
 	"github.com/pherrymason/c3-lsp/internal/lsp/project_state"
 	"github.com/pherrymason/c3-lsp/pkg/cast"
+	"github.com/pherrymason/c3-lsp/pkg/document"
 	"github.com/pherrymason/c3-lsp/pkg/fs"
+	"github.com/pherrymason/c3-lsp/pkg/utils"
+	sitter "github.com/smacker/go-tree-sitter"
 	"github.com/tliron/glsp"
 	protocol "github.com/tliron/glsp/protocol_3_16"
 )
 
+// analysisDelay is how long a changed document must stay unchanged before it is analysed.
+const analysisDelay = 300 * time.Millisecond
+
+// RunDiagnostics checks with c3c every workspace folder having diagnostics enabled, each one with
+// its own options. Problems of a file are those found when checking the folder containing it.
 func (s *Server) RunDiagnostics(state *project_state.ProjectState, notify glsp.NotifyFunc, delay bool) {
-	if !s.options.Diagnostics.Enabled {
+	folders := []*workspaceFolder{}
+	for _, folder := range s.workspaceFolders() {
+		if folder.options.Diagnostics.Enabled {
+			folders = append(folders, folder)
+		}
+	}
+	if len(folders) == 0 {
 		return
 	}
 
 	runDiagnostics := func() {
-		out, stdErr, err := c3c.CheckC3ErrorsCommand(s.options.C3, state.GetProjectRootURI())
-		log.Println("output:", out.String())
-		log.Println("output:", stdErr.String())
-		if err == nil {
-			clearOldDiagnostics(s.state, notify)
-			return
-		}
+		diagnosticsByFile := map[string][]protocol.Diagnostic{}
+		previousDiagnostics := state.GetDocumentDiagnostics()
+		for _, folder := range folders {
+			folderDiagnostics, supported := s.checkFolder(folder)
+			if !supported {
+				// Disable future diagnostics of the folders using this c3c, looks like an old version.
+				// Their previous diagnostics are cleared below.
+				s.disableDiagnostics(folder.options.C3.Path.GetOrElse(""))
+				continue
+			}
 
-		log.Println("An error:", err)
-		errorsInfo, diagnosticsDisabled := extractErrorDiagnostics(stdErr.String())
+			// Keep the previous diagnostics rather than clearing them without knowing.
+			if folderDiagnostics == nil {
+				folderDiagnostics = map[string][]protocol.Diagnostic{}
+				for file, d := range previousDiagnostics {
+					if s.folderOf(file) == folder {
+						folderDiagnostics[file] = d
+					}
+				}
+			}
 
-		if diagnosticsDisabled {
-			s.options.Diagnostics.Enabled = false
-			clearOldDiagnostics(s.state, notify)
-			return
+			for file, d := range folderDiagnostics {
+				// Files of another folder are reported when checking that folder.
+				if owner := s.folderOf(file); owner == nil || owner == folder {
+					diagnosticsByFile[file] = append(diagnosticsByFile[file], d...)
+				}
+			}
 		}
 
 		// Send empty diagnostics for those files that had previously an error, but not anymore.
 		// If this is not done, the IDE will keep displaying the errors.
-		for k := range s.state.GetDocumentDiagnostics() {
-			if !hasDiagnosticForFile(k, errorsInfo) {
+		for k := range previousDiagnostics {
+			if _, ok := diagnosticsByFile[k]; !ok {
 				s.state.RemoveDocumentDiagnostics(k)
-				notify(protocol.ServerTextDocumentPublishDiagnostics,
-					protocol.PublishDiagnosticsParams{
-						URI:         k,
-						Diagnostics: []protocol.Diagnostic{},
-					})
+				s.publishDiagnostics(k, notify)
 			}
 		}
 
-		for _, errInfo := range errorsInfo {
-			newDiagnostics := []protocol.Diagnostic{
-				errInfo.Diagnostic,
-			}
-			state.SetDocumentDiagnostics(errInfo.File, newDiagnostics)
-			notify(
-				protocol.ServerTextDocumentPublishDiagnostics,
-				protocol.PublishDiagnosticsParams{
-					URI:         fs.ConvertPathToURI(errInfo.File, s.options.C3.StdlibPath),
-					Diagnostics: newDiagnostics,
-				})
+		for file, newDiagnostics := range diagnosticsByFile {
+			state.SetDocumentDiagnostics(file, newDiagnostics)
+			s.publishDiagnostics(file, notify)
+		}
+
+		if s.pullDiagnostics {
+			s.refreshDiagnostics()
 		}
 	}
 
 	if delay {
-		s.diagnosticDebounced(runDiagnostics)
+		s.optionsMutex.RLock()
+		debounced := s.diagnosticDebounced
+		s.optionsMutex.RUnlock()
+		debounced(runDiagnostics)
 	} else {
 		runDiagnostics()
 	}
 }
 
-type ErrorInfo struct {
-	File       string
-	Diagnostic protocol.Diagnostic
-}
+// checkFolder checks the targets of the folder with c3c, returning the diagnostics found by file.
+// They are nil when every target timed out, and supported is false when c3c cannot report them.
+func (s *Server) checkFolder(folder *workspaceFolder) (diagnosticsByFile map[string][]protocol.Diagnostic, supported bool) {
+	options := folder.options
+	targets := s.checkedTargets(options, folder.path)
+	checks := c3c.CheckTargets(options.C3, folder.path, targets, options.Diagnostics.Timeout*time.Millisecond)
 
-func extractErrorDiagnostics(output string) ([]ErrorInfo, bool) {
-	errorsInfo := []ErrorInfo{}
-	diagnosticsDisabled := false
+	completed := 0
+	for _, check := range checks {
+		if check.Unsupported {
+			return nil, false
+		}
+		if !check.TimedOut {
+			completed++
+		}
+	}
+	if completed == 0 {
+		return nil, true
+	}
 
-	lines := strings.Split(output, "\n")
-	for _, line := range lines {
-		if strings.HasPrefix(line, "Error") {
-			// Procesa la línea de error
-			parts := strings.Split(line, "|")
-			if parts[0] == "Error" {
-				if len(parts) != 5 {
-					// Disable future diagnostics, looks like c3c is an old version.
-					diagnosticsDisabled = true
-				} else {
-					line, err := strconv.Atoi(parts[2])
-					if err != nil {
-						continue
-					}
-					line -= 1
-					character, err := strconv.Atoi(parts[3])
-					if err != nil {
-						continue
-					}
-					character -= 1
-
-					errorsInfo = append(errorsInfo, ErrorInfo{
-						File: parts[1],
-						Diagnostic: protocol.Diagnostic{
-							Range: protocol.Range{
-								Start: protocol.Position{Line: protocol.UInteger(line), Character: protocol.UInteger(character)},
-								End:   protocol.Position{Line: protocol.UInteger(line), Character: protocol.UInteger(99)},
-							},
-							Severity: cast.ToPtr(protocol.DiagnosticSeverityError),
-							Source:   cast.ToPtr("c3c build --test"),
-							Message:  parts[4],
-						},
-					})
-				}
+	compilerDiagnostics := c3c.MergeTargetDiagnostics(checks)
+	if completed > 1 {
+		for i, d := range compilerDiagnostics {
+			if len(d.Targets) < completed {
+				compilerDiagnostics[i].Message += fmt.Sprintf(" (only on target %s)", strings.Join(d.Targets, ", "))
 			}
-			break
 		}
 	}
 
-	return errorsInfo, diagnosticsDisabled
+	return s.groupDiagnostics(compilerDiagnostics), true
+}
+
+// disableDiagnostics stops checking the folders using the c3c at compilerPath, "" being the one in PATH.
+func (s *Server) disableDiagnostics(compilerPath string) {
+	s.optionsMutex.Lock()
+	if s.unsupportedCompilers == nil {
+		s.unsupportedCompilers = map[string]bool{}
+	}
+	s.unsupportedCompilers[compilerPath] = true
+	if s.options.C3.Path.GetOrElse("") == compilerPath {
+		s.options.Diagnostics.Enabled = false
+	}
+	s.optionsMutex.Unlock()
+
+	s.foldersMutex.Lock()
+	defer s.foldersMutex.Unlock()
+	for i, folder := range s.folders {
+		if folder.options.C3.Path.GetOrElse("") != compilerPath {
+			continue
+		}
+		disabled := *folder
+		disabled.options.Diagnostics.Enabled = false
+		s.folders[i] = &disabled
+	}
+}
+
+// checkedTargets returns the targets of the project.json at projectPath selected in the options.
+// None means checking the default target.
+func (s *Server) checkedTargets(options ServerOpts, projectPath string) []string {
+	if len(options.C3.Targets) == 0 {
+		return []string{}
+	}
+
+	projectTargets, err := c3c.ReadProjectTargets(projectPath)
+	if err != nil {
+		log.Println("Could not read targets of project.json:", err)
+		return []string{}
+	}
+
+	targets := c3c.SelectTargets(projectTargets, options.C3.Targets)
+	if len(targets) == 0 {
+		log.Printf("No target of project.json matches %v", options.C3.Targets)
+	}
+
+	return targets
 }
 
-func clearOldDiagnostics(state *project_state.ProjectState, notify glsp.NotifyFunc) {
-	for k := range state.GetDocumentDiagnostics() {
-		go notify(protocol.ServerTextDocumentPublishDiagnostics,
-			protocol.PublishDiagnosticsParams{
-				URI:         k,
-				Diagnostics: []protocol.Diagnostic{},
+// groupDiagnostics converts the diagnostics reported by c3c, grouping them by file.
+// Notes are attached as related information of the error or warning they follow.
+func (s *Server) groupDiagnostics(reported []c3c.Diagnostic) map[string][]protocol.Diagnostic {
+	sources := map[string][]string{}
+	rangeOf := func(d c3c.Diagnostic) protocol.Range {
+		lines, ok := sources[d.File]
+		if !ok {
+			lines = strings.Split(s.diagnosticSource(d.File), "\n")
+			sources[d.File] = lines
+		}
+
+		lineText := ""
+		if int(d.Line) < len(lines) {
+			lineText = strings.TrimRight(lines[d.Line], "\r")
+		}
+
+		return tokenRange(lineText, d.Line, d.Column)
+	}
+
+	diagnosticsByFile := map[string][]protocol.Diagnostic{}
+	for _, d := range reported {
+		severity := protocol.DiagnosticSeverityError
+		if d.Severity == c3c.SeverityWarning {
+			severity = protocol.DiagnosticSeverityWarning
+		}
+
+		diagnostic := protocol.Diagnostic{
+			Range:    rangeOf(d),
+			Severity: cast.ToPtr(severity),
+			Source:   cast.ToPtr("c3c build --test"),
+			Message:  d.Message,
+		}
+		for _, note := range d.Notes {
+			diagnostic.RelatedInformation = append(diagnostic.RelatedInformation, protocol.DiagnosticRelatedInformation{
+				Location: protocol.Location{URI: s.fileURI(note.File), Range: rangeOf(note)},
+				Message:  note.Message,
 			})
+		}
+
+		// Files are identified like documents, so both kinds of diagnostics can be merged.
+		docId := fs.GetCanonicalPath(d.File)
+		diagnosticsByFile[docId] = append(diagnosticsByFile[docId], diagnostic)
+	}
+
+	return diagnosticsByFile
+}
+
+func (s *Server) fileURI(file string) protocol.DocumentUri {
+	return s.state.DocumentURI(file, s.serverOptions().C3.StdlibPath)
+}
+
+// diagnosticSource returns the content of file, preferring the version open in the editor.
+func (s *Server) diagnosticSource(file string) string {
+	if doc := s.state.GetDocument(fs.GetCanonicalPath(file)); doc != nil {
+		return doc.SourceCode.Text
+	}
+
+	path := file
+	if stdlibPath := s.serverOptions().C3.StdlibPath; stdlibPath.IsSome() {
+		path = strings.Replace(path, "<stdlib-path>", stdlibPath.Get()+"/", 1)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+
+	return string(content)
+}
+
+// tokenRange returns the range of the token found at column in lineText: a whole identifier
+// or a single character. Columns are counted in bytes by c3c and in UTF-16 units by LSP.
+func tokenRange(lineText string, line uint, column uint) protocol.Range {
+	start := utils.Min(int(column), len(lineText))
+	end := start
+	if end < len(lineText) {
+		end++
+		if isIdentifierByte(lineText[start]) {
+			for end < len(lineText) && isIdentifierByte(lineText[end]) {
+				end++
+			}
+		}
+	}
+
+	return protocol.Range{
+		Start: protocol.Position{Line: protocol.UInteger(line), Character: utf16Length(lineText[:start])},
+		End:   protocol.Position{Line: protocol.UInteger(line), Character: utf16Length(lineText[:end])},
+	}
+}
+
+func isIdentifierByte(c byte) bool {
+	return c == '_' || c == '$' || c == '@' || c == '#' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+func utf16Length(text string) protocol.UInteger {
+	return protocol.UInteger(len(utf16.Encode([]rune(text))))
+}
+
+// analyzeDocument reports the syntax errors of the document, and the problems found by
+// the semantic checks, right away without waiting for c3c.
+func (s *Server) analyzeDocument(doc *document.Document, notify glsp.NotifyFunc) {
+	// Clients pulling diagnostics get them analysed when they ask for them.
+	if s.pullDiagnostics {
+		return
 	}
-	state.ClearDocumentDiagnostics()
+
+	s.analyze(doc)
+	s.publishDiagnostics(doc.URI, notify)
 }
 
-func hasDiagnosticForFile(file string, errorsInfo []ErrorInfo) bool {
-	for _, v := range errorsInfo {
-		if file == v.File {
-			return true
+// analyzeDocumentLater analyzes the document once it stops changing for analysisDelay, so typing
+// does not run the analysis, which searches the workspace, on every key.
+func (s *Server) analyzeDocumentLater(docId string, notify glsp.NotifyFunc) {
+	if s.pullDiagnostics {
+		return
+	}
+
+	s.analysisMutex.Lock()
+	if s.analysisDebounced == nil {
+		s.analysisDebounced = map[string]func(func()){}
+	}
+	debounced, ok := s.analysisDebounced[docId]
+	if !ok {
+		debounced = debounce.New(analysisDelay)
+		s.analysisDebounced[docId] = debounced
+	}
+	s.analysisMutex.Unlock()
+
+	debounced(func() {
+		// It might have been closed meanwhile.
+		if doc := s.state.GetDocument(docId); doc != nil && s.state.IsOpenDocument(docId) {
+			s.analyzeDocument(doc, notify)
 		}
+	})
+}
+
+// forgetDocumentAnalysis stops waiting to analyze the document.
+func (s *Server) forgetDocumentAnalysis(docId string) {
+	s.analysisMutex.Lock()
+	defer s.analysisMutex.Unlock()
+
+	delete(s.analysisDebounced, docId)
+}
+
+// publishSyntaxErrors reports the syntax errors of the document right away, while the analysis
+// waits for it to stop changing.
+func (s *Server) publishSyntaxErrors(docId string, notify glsp.NotifyFunc) {
+	if s.pullDiagnostics {
+		return
+	}
+
+	if doc := s.state.GetDocument(docId); doc != nil {
+		s.analyzeSyntax(doc)
+		s.publishDiagnostics(docId, notify)
+	}
+}
+
+func (s *Server) analyze(doc *document.Document) {
+	// Libraries are not part of the project, their problems cannot be fixed.
+	if s.state.IsLibraryDocument(doc.URI) {
+		return
+	}
+
+	// Documents indexed meanwhile make it outdated.
+	revision := s.state.Revision()
+	s.analyzeSyntax(doc)
+	problems := s.search.FindProblems(doc.URI, s.state.ForDocument(doc.URI))
+
+	s.state.SetAnalysisDiagnostics(doc, revision, diagnostics.Problems(problems, s.optionsFor(doc.URI).Diagnostics.Checks, doc.SourceCode.Text))
+}
+
+func (s *Server) analyzeSyntax(doc *document.Document) {
+	if s.state.IsLibraryDocument(doc.URI) {
+		return
+	}
+
+	var root *sitter.Node
+	if tree := doc.SyntaxTree(); tree != nil {
+		root = tree.RootNode()
+	}
+
+	s.state.SetSyntaxDiagnostics(doc, diagnostics.SyntaxErrors(root, []byte(doc.SourceCode.Text)))
+}
+
+// publishDiagnostics sends every diagnostic known for the document, from c3c and from the analysis.
+func (s *Server) publishDiagnostics(docId string, notify glsp.NotifyFunc) {
+	if s.pullDiagnostics {
+		return
 	}
 
-	return false
+	notify(protocol.ServerTextDocumentPublishDiagnostics,
+		protocol.PublishDiagnosticsParams{
+			URI:         s.fileURI(docId),
+			Diagnostics: s.state.PublishableDiagnostics(docId),
+		})
 }
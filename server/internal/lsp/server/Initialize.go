@@ -1,12 +1,20 @@
 package server
 
 import (
+	"encoding/json"
+	"errors"
+	"log"
 	"os"
+	"runtime"
+	"sync"
+	"sync/atomic"
 
+	"github.com/pherrymason/c3-lsp/internal/c3c"
+	_prot "github.com/pherrymason/c3-lsp/internal/lsp/protocol"
 	"github.com/pherrymason/c3-lsp/pkg/cast"
 	"github.com/pherrymason/c3-lsp/pkg/document"
 	"github.com/pherrymason/c3-lsp/pkg/fs"
-	"github.com/pherrymason/c3-lsp/pkg/utils"
+	p "github.com/pherrymason/c3-lsp/pkg/parser"
 	"github.com/tliron/glsp"
 	protocol "github.com/tliron/glsp/protocol_3_16"
 )
@@ -25,11 +33,30 @@ func (s *Server) Initialize(serverName string, serverVersion string, capabilitie
 	capabilities.CompletionProvider = &protocol.CompletionOptions{
 		TriggerCharacters: []string{".", ":"},
 	}
+	capabilities.RenameProvider = protocol.RenameOptions{
+		PrepareProvider: cast.ToPtr(true),
+	}
+	capabilities.SemanticTokensProvider = &protocol.SemanticTokensOptions{
+		Legend: semanticTokensLegend(),
+		Range:  true,
+		Full:   &protocol.SemanticDelta{Delta: cast.ToPtr(true)},
+	}
+	capabilities.DocumentOnTypeFormattingProvider = &protocol.DocumentOnTypeFormattingOptions{
+		FirstTriggerCharacter: "}",
+		MoreTriggerCharacter:  []string{";", "\n"},
+	}
 	capabilities.SignatureHelpProvider = &protocol.SignatureHelpOptions{
 		TriggerCharacters:   []string{"(", ","},
 		RetriggerCharacters: []string{")"},
 	}
+	capabilities.ExecuteCommandProvider = &protocol.ExecuteCommandOptions{
+		Commands: []string{commandExportDiagnostics},
+	}
 	capabilities.Workspace = &protocol.ServerCapabilitiesWorkspace{
+		WorkspaceFolders: &protocol.WorkspaceFoldersServerCapabilities{
+			Supported:           cast.ToPtr(true),
+			ChangeNotifications: &protocol.BoolOrString{Value: true},
+		},
 		FileOperations: &protocol.ServerCapabilitiesWorkspaceFileOperations{
 			DidDelete: &protocol.FileOperationRegistrationOptions{
 				Filters: []protocol.FileOperationFilter{{
@@ -48,21 +75,42 @@ func (s *Server) Initialize(serverName string, serverVersion string, capabilitie
 		},
 	}
 
-	if params.RootURI != nil {
-		s.state.SetProjectRootURI(utils.NormalizePath(*params.RootURI))
-		path, _ := fs.UriToPath(*params.RootURI)
-		s.loadServerConfigurationForWorkspace(path)
-		s.indexWorkspace()
-
-		s.RunDiagnostics(s.state, context.Notify, false)
+	// Clients pulling diagnostics must be told when c3c finds new ones. Those that cannot be
+	// told get them pushed instead.
+	pullDiagnostics, refreshSupport := clientPullDiagnosticsSupport(context.Params)
+	s.pullDiagnostics = pullDiagnostics && refreshSupport
+	if !s.pullDiagnostics && !clientSupportsRelatedInformation(params) {
+		s.optionsMutex.Lock()
+		s.diagnosticsSupported = false
+		s.optionsMutex.Unlock()
 	}
 
-	if *params.Capabilities.TextDocument.PublishDiagnostics.RelatedInformation == false {
-		s.options.Diagnostics.Enabled = false
+	s.setWorkspaceFolders(initialWorkspaceFolders(params))
+	s.reloadConfiguration()
+	s.pullConfiguration = clientSupportsConfiguration(params)
+	s.workDoneProgress = clientSupportsWorkDoneProgress(params)
+	s.registerFileWatchers = clientSupportsWatchedFilesRegistration(params)
+	s.registerConfigurationChanges = clientSupportsConfigurationRegistration(params)
+
+	s.shared.indexStdlib()
+
+	var diagnosticProvider any
+	if s.pullDiagnostics {
+		s.refreshDiagnostics = func() {
+			context.Call(_prot.ServerWorkspaceDiagnosticRefresh, nil, nil)
+		}
+		diagnosticProvider = _prot.DiagnosticOptions{
+			InterFileDependencies: true,
+			WorkspaceDiagnostics:  true,
+		}
 	}
 
-	return protocol.InitializeResult{
-		Capabilities: capabilities,
+	return _prot.InitializeResult{
+		Capabilities: _prot.ServerCapabilities{
+			ServerCapabilities: capabilities,
+			InlayHintProvider:  true,
+			DiagnosticProvider: diagnosticProvider,
+		},
 		ServerInfo: &protocol.InitializeResultServerInfo{
 			Name:    serverName,
 			Version: &serverVersion,
@@ -70,13 +118,219 @@ func (s *Server) Initialize(serverName string, serverVersion string, capabilitie
 	}, nil
 }
 
-func (h *Server) indexWorkspace() {
-	path := h.state.GetProjectRootURI()
-	files, _ := fs.ScanForC3(fs.GetCanonicalPath(path))
+// indexWorkspace indexes the files of the projects of every workspace folder and of the libraries
+// they depend on. When indexing again, files no longer part of a project are removed, unless they
+// are open, and files already known are kept as they are. Files are parsed in parallel, reporting the progress when
+// progress is not nil.
+func (h *Server) indexWorkspace(progress *workDoneProgress) {
+	h.shared.indexing.Lock()
+	defer h.shared.indexing.Unlock()
 
-	for _, filePath := range files {
-		content, _ := os.ReadFile(filePath)
-		doc := document.NewDocumentFromString(filePath, string(content))
-		h.state.RefreshDocumentIdentifiers(&doc, h.parser)
+	indexed := map[string]bool{}
+	jobs := []indexJob{}
+	for _, folder := range h.workspaceFolders() {
+		files, libraryFiles := workspaceFiles(folder.path)
+		for _, filePath := range files {
+			if !indexed[filePath] && h.state.GetDocument(filePath) == nil {
+				jobs = append(jobs, indexJob{path: filePath})
+			}
+			indexed[filePath] = true
+		}
+
+		for _, file := range libraryFiles {
+			if !indexed[file.Path] && h.state.GetDocument(file.Path) == nil {
+				jobs = append(jobs, indexJob{path: file.Path, library: true, archived: file.Archived, content: &file.Content})
+			}
+			indexed[file.Path] = true
+		}
+	}
+
+	h.indexFiles(jobs, progress)
+	h.shared.setIndexedFiles(h, indexed)
+}
+
+// reindexFiles parses again the files of the workspace modified on disk. Open documents are kept,
+// as the editor has their latest content.
+func (h *Server) reindexFiles(paths []string) {
+	h.shared.indexing.Lock()
+	defer h.shared.indexing.Unlock()
+
+	jobs := []indexJob{}
+	for _, path := range paths {
+		if h.shared.indexed(path) && !h.state.IsOpenDocument(path) {
+			library := h.state.IsLibraryDocument(path)
+			h.state.DeleteDocument(path)
+			jobs = append(jobs, indexJob{path: path, library: library})
+		}
 	}
+
+	h.indexFiles(jobs, nil)
+}
+
+// indexJob is a file to index. Files inside library archives come with their content,
+// the others are read from disk.
+type indexJob struct {
+	path     string
+	library  bool
+	archived bool
+	content  *string
+}
+
+// indexFiles parses the files with a pool of workers, each with its own parser, as the
+// parser of the server is used by requests answered meanwhile. Files unchanged since cached
+// are not parsed, their syntax tree is only built when opened or searched.
+func (h *Server) indexFiles(jobs []indexJob, progress *workDoneProgress) {
+	queue := make(chan indexJob)
+	done := atomic.Int64{}
+	workers := sync.WaitGroup{}
+
+	for i := 0; i < min(runtime.NumCPU(), len(jobs)); i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			parser := p.NewParser(h.server.Log)
+			for job := range queue {
+				if job.content == nil {
+					content, _ := os.ReadFile(job.path)
+					job.content = cast.ToPtr(string(content))
+				}
+
+				doc := document.NewUnparsedDocument(job.path, *job.content)
+				modules, pending := h.indexCache.ParseSymbols(&doc, &parser)
+				if job.library {
+					h.state.IndexLibraryDocument(&doc, job.archived, modules, pending)
+				} else {
+					h.state.IndexDocument(&doc, modules, pending)
+				}
+				progress.report(int(done.Add(1)), len(jobs))
+			}
+		}()
+	}
+
+	for _, job := range jobs {
+		queue <- job
+	}
+	close(queue)
+	workers.Wait()
+}
+
+// stdlibOptions returns the options selecting the stdlib of each folder, by folder path. Files
+// outside every folder use those of the server, under "". See sharedState.stdlibOptions.
+func (s *Server) stdlibOptions() map[string]ServerOpts {
+	options := map[string]ServerOpts{"": s.serverOptions()}
+	for _, folder := range s.workspaceFolders() {
+		options[folder.path] = folder.options
+	}
+
+	return options
+}
+
+// workspaceFiles returns the files declared in the project.json of the workspace, and the sources
+// of the libraries it depends on. Without project.json, every C3 file of the workspace is returned.
+func workspaceFiles(path string) ([]string, []c3c.LibraryFile) {
+	project, err := c3c.ReadProject(path)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Printf("Could not read %s/project.json: %v", path, err)
+		}
+		files, _ := fs.ScanForC3(path)
+		return files, []c3c.LibraryFile{}
+	}
+
+	libraryFiles := []c3c.LibraryFile{}
+	for _, library := range project.Libraries(path) {
+		files, err := c3c.ReadLibrary(library)
+		if err != nil {
+			log.Printf("Could not read library %s: %v", library, err)
+			continue
+		}
+		libraryFiles = append(libraryFiles, files...)
+	}
+
+	return project.SourceFiles(path), libraryFiles
+}
+
+// isProjectFile tells if uri is the project.json of a workspace folder.
+func (h *Server) isProjectFile(uri protocol.DocumentUri) bool {
+	return h.workspaceRootFile(uri, "project.json") != nil
+}
+
+// isConfigurationFile tells if uri is the c3lsp.json of a workspace folder.
+func (h *Server) isConfigurationFile(uri protocol.DocumentUri) bool {
+	return h.workspaceRootFile(uri, configurationFile) != nil
+}
+
+// clientPullDiagnosticsSupport reads from the raw initialize params the LSP 3.17 capabilities
+// about pull diagnostics, which glsp does not know.
+func clientPullDiagnosticsSupport(rawParams json.RawMessage) (pull bool, refresh bool) {
+	var params struct {
+		Capabilities struct {
+			TextDocument struct {
+				Diagnostic *struct{} `json:"diagnostic"`
+			} `json:"textDocument"`
+			Workspace struct {
+				Diagnostics *struct {
+					RefreshSupport bool `json:"refreshSupport"`
+				} `json:"diagnostics"`
+			} `json:"workspace"`
+		} `json:"capabilities"`
+	}
+	if err := json.Unmarshal(rawParams, &params); err != nil {
+		return false, false
+	}
+
+	pull = params.Capabilities.TextDocument.Diagnostic != nil
+	refresh = pull && params.Capabilities.Workspace.Diagnostics != nil && params.Capabilities.Workspace.Diagnostics.RefreshSupport
+
+	return pull, refresh
+}
+
+// clientSupportsConfiguration tells if the client answers "workspace/configuration" requests.
+func clientSupportsConfiguration(params *protocol.InitializeParams) bool {
+	workspace := params.Capabilities.Workspace
+	if workspace == nil || workspace.Configuration == nil {
+		return false
+	}
+
+	return *workspace.Configuration
+}
+
+// clientSupportsConfigurationRegistration tells if the client reports the changes of its settings
+// once the server asks for them.
+func clientSupportsConfigurationRegistration(params *protocol.InitializeParams) bool {
+	workspace := params.Capabilities.Workspace
+	if workspace == nil || workspace.DidChangeConfiguration == nil || workspace.DidChangeConfiguration.DynamicRegistration == nil {
+		return false
+	}
+
+	return *workspace.DidChangeConfiguration.DynamicRegistration
+}
+
+// clientSupportsWatchedFilesRegistration tells if the client watches the files the server asks for.
+func clientSupportsWatchedFilesRegistration(params *protocol.InitializeParams) bool {
+	workspace := params.Capabilities.Workspace
+	if workspace == nil || workspace.DidChangeWatchedFiles == nil || workspace.DidChangeWatchedFiles.DynamicRegistration == nil {
+		return false
+	}
+
+	return *workspace.DidChangeWatchedFiles.DynamicRegistration
+}
+
+// clientSupportsWorkDoneProgress tells if the client shows the progress of tasks started by the server.
+func clientSupportsWorkDoneProgress(params *protocol.InitializeParams) bool {
+	window := params.Capabilities.Window
+	if window == nil || window.WorkDoneProgress == nil {
+		return false
+	}
+
+	return *window.WorkDoneProgress
+}
+
+func clientSupportsRelatedInformation(params *protocol.InitializeParams) bool {
+	textDocument := params.Capabilities.TextDocument
+	if textDocument == nil || textDocument.PublishDiagnostics == nil || textDocument.PublishDiagnostics.RelatedInformation == nil {
+		return false
+	}
+
+	return *textDocument.PublishDiagnostics.RelatedInformation
 }
@@ -0,0 +1,102 @@
+package server
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/pherrymason/c3-lsp/pkg/document"
+	"github.com/stretchr/testify/assert"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func TestIndexWorkspace_indexes_every_file_reporting_progress(t *testing.T) {
+	files := map[string]string{}
+	for i := 0; i < 20; i++ {
+		files[fmt.Sprintf("src/file%d.c3", i)] = fmt.Sprintf("module app%d;\nfn void run%d() {}\n", i, i)
+	}
+	folder := writeWorkspaceFiles(t, t.TempDir(), files)
+	s := newTestServer(t, folder)
+
+	reports := []protocol.WorkDoneProgressReport{}
+	progress := &workDoneProgress{notify: func(method string, params any) {
+		reports = append(reports, params.(protocol.ProgressParams).Value.(protocol.WorkDoneProgressReport))
+	}}
+	s.indexWorkspace(progress)
+
+	for i := 0; i < 20; i++ {
+		assert.NotNil(t, s.state.GetDocument(filepath.Join(folder, "src", fmt.Sprintf("file%d.c3", i))))
+		assert.Equal(t, 1, len(s.state.SearchByFQN(fmt.Sprintf("app%d.run%d", i, i))))
+	}
+
+	// Workers finishing at the same time may skip some percentages, but never go back.
+	assert.NotEmpty(t, reports)
+	for i := 1; i < len(reports); i++ {
+		assert.Greater(t, *reports[i].Percentage, *reports[i-1].Percentage)
+	}
+	last := reports[len(reports)-1]
+	assert.Equal(t, protocol.UInteger(100), *last.Percentage)
+	assert.Equal(t, "20/20 files", *last.Message)
+}
+
+func TestIndexWorkspace_can_be_searched_while_indexing(t *testing.T) {
+	files := map[string]string{}
+	for i := 0; i < 50; i++ {
+		files[fmt.Sprintf("file%d.c3", i)] = fmt.Sprintf("module app;\nfn void run%d() { helper(); }\nfn void helper() {}\n", i)
+	}
+	folder := writeWorkspaceFiles(t, t.TempDir(), files)
+	s := newTestServer(t, folder)
+
+	indexed := make(chan struct{})
+	go func() {
+		s.indexWorkspace(nil)
+		close(indexed)
+	}()
+
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-indexed:
+				return
+			default:
+			}
+			for _, helper := range s.state.SearchByFQN("app.helper") {
+				s.search.FindSymbolReferences(helper, s.state, true)
+			}
+		}
+	}()
+	wg.Wait()
+
+	assert.Equal(t, 50, len(s.state.SearchByFQN("app.helper")))
+}
+
+func TestIndexWorkspace_keeps_open_documents_removed_from_the_project(t *testing.T) {
+	dir := t.TempDir()
+	folder := writeWorkspaceFiles(t, dir, map[string]string{
+		"project.json":  `{ "sources": ["src/**"] }`,
+		"src/main.c3":   "module app;\nfn void main() {}\n",
+		"src/opened.c3": "module app;\nfn void opened() {}\n",
+		"src/closed.c3": "module app;\nfn void closed() {}\n",
+	})
+	s := newTestServer(t, folder)
+	s.indexWorkspace(nil)
+
+	opened := filepath.Join(folder, "src", "opened.c3")
+	doc := document.NewDocumentFromString(opened, "module app;\nfn void opened() {}\n")
+	s.state.OpenDocument(&doc, s.parser)
+
+	writeWorkspaceFiles(t, dir, map[string]string{"project.json": `{ "sources": ["src/main.c3"] }`})
+	s.indexWorkspace(nil)
+
+	assert.NotNil(t, s.state.GetDocument(opened))
+	assert.Nil(t, s.state.GetDocument(filepath.Join(folder, "src", "closed.c3")))
+
+	s.state.UpdateDocument(opened, []interface{}{
+		protocol.TextDocumentContentChangeEventWhole{Text: "module app;\nfn void edited() {}\n"},
+	}, s.parser)
+	assert.Equal(t, 1, len(s.state.SearchByFQN("app.edited")))
+}
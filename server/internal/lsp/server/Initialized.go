@@ -0,0 +1,72 @@
+package server
+
+import (
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// Support "Initialized"
+func (s *Server) Initialized(context *glsp.Context, params *protocol.InitializedParams) error {
+	go s.registerCapabilities(context)
+
+	for _, folder := range s.workspaceFolders() {
+		if folder.configurationError != nil {
+			s.publishConfigurationDiagnostics(folder.path, folder.configurationError, context.Notify)
+		}
+	}
+	if s.pullConfiguration {
+		s.pullEditorSettings(context)
+		if s.editorSettings != nil {
+			s.configurationChanged(context.Notify)
+		}
+	}
+
+	if len(s.workspaceFolders()) > 0 {
+		s.indexWorkspaceInBackground(context, false)
+	}
+
+	return nil
+}
+
+// registerCapabilities asks the client to report the changes of C3 files and of the project and
+// server configuration, including those made outside the editor, like a git checkout, and the
+// changes of the editor settings.
+func (s *Server) registerCapabilities(context *glsp.Context) {
+	registrations := []protocol.Registration{}
+	if s.registerFileWatchers {
+		registrations = append(registrations, protocol.Registration{
+			ID:     "c3lsp-watched-files",
+			Method: protocol.MethodWorkspaceDidChangeWatchedFiles,
+			RegisterOptions: protocol.DidChangeWatchedFilesRegistrationOptions{
+				Watchers: []protocol.FileSystemWatcher{
+					{GlobPattern: "**/*.{c3,c3i}"},
+					{GlobPattern: "**/project.json"},
+					{GlobPattern: "**/c3lsp.json"},
+				},
+			},
+		})
+	}
+	if s.registerConfigurationChanges {
+		registrations = append(registrations, protocol.Registration{
+			ID:     "c3lsp-configuration",
+			Method: protocol.MethodWorkspaceDidChangeConfiguration,
+		})
+	}
+
+	if len(registrations) > 0 {
+		context.Call(protocol.ServerClientRegisterCapability, protocol.RegistrationParams{Registrations: registrations}, nil)
+	}
+}
+
+// indexWorkspaceInBackground indexes the workspace and then runs diagnostics, while requests
+// about open documents are answered. Requests read snapshots of the state, so documents
+// indexed meanwhile do not change what they are looking at.
+func (s *Server) indexWorkspaceInBackground(context *glsp.Context, delayDiagnostics bool) {
+	go func() {
+		progress := s.beginProgress(context, "Indexing C3 workspace")
+		s.indexWorkspace(progress)
+		progress.end("Workspace indexed")
+
+		s.RunDiagnostics(s.state, context.Notify, delayDiagnostics)
+	}()
+}
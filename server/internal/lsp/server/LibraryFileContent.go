@@ -0,0 +1,19 @@
+package server
+
+import (
+	"github.com/pherrymason/c3-lsp/internal/c3c"
+	_prot "github.com/pherrymason/c3-lsp/internal/lsp/protocol"
+	"github.com/pherrymason/c3-lsp/pkg/utils"
+	"github.com/tliron/glsp"
+)
+
+// Support "c3lsp/libraryFileContent": content of the files inside library archives, so clients
+// can show them when going to the definition of a library symbol.
+func (h *Server) LibraryFileContent(context *glsp.Context, params *_prot.LibraryFileContentParams) (string, error) {
+	docId := utils.NormalizePath(params.TextDocument.URI)
+	if doc := h.state.GetDocument(docId); doc != nil {
+		return doc.SourceCode.Text, nil
+	}
+
+	return c3c.ReadLibraryFile(docId)
+}
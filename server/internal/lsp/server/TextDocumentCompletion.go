@@ -11,15 +11,17 @@ import (
 // Returns: []CompletionItem | CompletionList | nil
 func (h *Server) TextDocumentCompletion(context *glsp.Context, params *protocol.CompletionParams) (any, error) {
 
+	docId := utils.NormalizePath(params.TextDocument.URI)
+	state := h.state.ForDocument(docId)
 	cursorContext := ctx.BuildFromDocumentPosition(
 		params.Position,
-		utils.NormalizePath(params.TextDocument.URI),
-		h.state,
+		docId,
+		state,
 	)
 
 	suggestions := h.search.BuildCompletionList(
 		cursorContext,
-		h.state,
+		state,
 	)
 	return suggestions, nil
 }
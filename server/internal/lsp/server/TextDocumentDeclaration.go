@@ -2,7 +2,6 @@ package server
 
 import (
 	_prot "github.com/pherrymason/c3-lsp/internal/lsp/protocol"
-	"github.com/pherrymason/c3-lsp/pkg/fs"
 	"github.com/pherrymason/c3-lsp/pkg/symbols"
 	"github.com/pherrymason/c3-lsp/pkg/utils"
 	"github.com/tliron/glsp"
@@ -11,10 +10,11 @@ import (
 
 // Support "Go to declaration"
 func (h *Server) TextDocumentDeclaration(context *glsp.Context, params *protocol.DeclarationParams) (any, error) {
+	docId := utils.NormalizePath(params.TextDocument.URI)
 	identifierOption := h.search.FindSymbolDeclarationInWorkspace(
-		utils.NormalizePath(params.TextDocument.URI),
+		docId,
 		symbols.NewPositionFromLSPPosition(params.Position),
-		h.state,
+		h.state.ForDocument(docId),
 	)
 
 	if identifierOption.IsNone() {
@@ -22,12 +22,13 @@ func (h *Server) TextDocumentDeclaration(context *glsp.Context, params *protocol
 	}
 
 	symbol := identifierOption.Get()
-	if !symbol.HasSourceCode() && h.options.C3.StdlibPath.IsNone() {
+	stdlibPath := h.optionsFor(docId).C3.StdlibPath
+	if !symbol.HasSourceCode() && stdlibPath.IsNone() {
 		return nil, nil
 	}
 
 	return protocol.Location{
-		URI:   fs.ConvertPathToURI(symbol.GetDocumentURI(), h.options.C3.StdlibPath),
+		URI:   h.state.DocumentURI(symbol.GetDocumentURI(), stdlibPath),
 		Range: _prot.Lsp_NewRangeFromRange(symbol.GetIdRange()),
 	}, nil
 }
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"encoding/json"
+	"hash/fnv"
+	"strconv"
+
+	_prot "github.com/pherrymason/c3-lsp/internal/lsp/protocol"
+	"github.com/pherrymason/c3-lsp/pkg/utils"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// Support "Pull Diagnostics"
+func (h *Server) TextDocumentDiagnostic(context *glsp.Context, params *_prot.DocumentDiagnosticParams) (any, error) {
+	items := h.documentDiagnostics(utils.NormalizePath(params.TextDocument.URI))
+	resultId := diagnosticsResultId(items)
+
+	if params.PreviousResultID != nil && *params.PreviousResultID == resultId {
+		return _prot.UnchangedDocumentDiagnosticReport{
+			Kind:     _prot.DocumentDiagnosticReportKindUnchanged,
+			ResultID: resultId,
+		}, nil
+	}
+
+	return _prot.FullDocumentDiagnosticReport{
+		Kind:     _prot.DocumentDiagnosticReportKindFull,
+		ResultID: &resultId,
+		Items:    items,
+	}, nil
+}
+
+// Support "Workspace Diagnostics"
+func (h *Server) WorkspaceDiagnostic(context *glsp.Context, params *_prot.WorkspaceDiagnosticParams) (_prot.WorkspaceDiagnosticReport, error) {
+	previousResultIds := map[string]string{}
+	for _, previous := range params.PreviousResultIDs {
+		previousResultIds[utils.NormalizePath(previous.URI)] = previous.Value
+	}
+
+	report := _prot.WorkspaceDiagnosticReport{Items: []any{}}
+	for _, docId := range h.state.DiagnosableDocumentIds() {
+		// Analysing the whole workspace takes a while, the client might not want the result anymore.
+		if context.Context != nil && context.Context.Err() != nil {
+			break
+		}
+		if h.state.IsLibraryDocument(docId) {
+			continue
+		}
+
+		items := h.documentDiagnostics(docId)
+		resultId := diagnosticsResultId(items)
+		uri := h.fileURI(docId)
+
+		if previous, ok := previousResultIds[docId]; ok && previous == resultId {
+			report.Items = append(report.Items, _prot.WorkspaceUnchangedDocumentDiagnosticReport{
+				UnchangedDocumentDiagnosticReport: _prot.UnchangedDocumentDiagnosticReport{
+					Kind:     _prot.DocumentDiagnosticReportKindUnchanged,
+					ResultID: resultId,
+				},
+				URI: uri,
+			})
+			continue
+		}
+
+		report.Items = append(report.Items, _prot.WorkspaceFullDocumentDiagnosticReport{
+			FullDocumentDiagnosticReport: _prot.FullDocumentDiagnosticReport{
+				Kind:     _prot.DocumentDiagnosticReportKindFull,
+				ResultID: &resultId,
+				Items:    items,
+			},
+			URI: uri,
+		})
+	}
+
+	return report, nil
+}
+
+// documentDiagnostics returns the diagnostics of the analysis of the document together with those
+// reported by c3c. The document is only analysed again when it or the symbols known changed.
+func (h *Server) documentDiagnostics(docId string) []protocol.Diagnostic {
+	if doc := h.state.GetDocument(docId); doc != nil && !h.state.HasCurrentAnalysis(docId) {
+		h.analyze(doc)
+	}
+
+	return h.state.PublishableDiagnostics(docId)
+}
+
+// diagnosticsResultId identifies a list of diagnostics, so unchanged lists get the same id.
+func diagnosticsResultId(diagnostics []protocol.Diagnostic) string {
+	data, _ := json.Marshal(diagnostics)
+	hash := fnv.New64a()
+	hash.Write(data)
+
+	return strconv.FormatUint(hash.Sum64(), 36)
+}
@@ -0,0 +1,97 @@
+package server
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	_prot "github.com/pherrymason/c3-lsp/internal/lsp/protocol"
+	"github.com/pherrymason/c3-lsp/pkg/document"
+	"github.com/pherrymason/c3-lsp/pkg/fs"
+	"github.com/pherrymason/c3-lsp/pkg/option"
+	"github.com/pherrymason/c3-lsp/pkg/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func pullDocumentDiagnostics(t *testing.T, s *Server, uri protocol.DocumentUri, previousResultId *string) any {
+	t.Helper()
+
+	report, err := s.TextDocumentDiagnostic(&glsp.Context{}, &_prot.DocumentDiagnosticParams{
+		TextDocument:     protocol.TextDocumentIdentifier{URI: uri},
+		PreviousResultID: previousResultId,
+	})
+	assert.Nil(t, err)
+
+	return report
+}
+
+func TestTextDocumentDiagnostic_reports_unchanged_diagnostics_by_result_id(t *testing.T) {
+	s := newTestServer(t)
+	s.pullDiagnostics = true
+	uri := fs.ConvertPathToURI(filepath.Join(t.TempDir(), "app.c3"), option.None[string]())
+	s.state.OpenDocument(document.NewDocumentFromDocURI(uri, "module app;\nfn void main() {\n\tmissing();\n}\n", 1), s.parser)
+
+	full, ok := pullDocumentDiagnostics(t, s, uri, nil).(_prot.FullDocumentDiagnosticReport)
+	assert.True(t, ok)
+	assert.Equal(t, 1, len(full.Items))
+
+	unchanged, ok := pullDocumentDiagnostics(t, s, uri, full.ResultID).(_prot.UnchangedDocumentDiagnosticReport)
+	assert.True(t, ok)
+	assert.Equal(t, *full.ResultID, unchanged.ResultID)
+
+	// New diagnostics from c3c change the result.
+	s.state.SetDocumentDiagnostics(utils.NormalizePath(uri), []protocol.Diagnostic{{Message: "from c3c"}})
+	changed, ok := pullDocumentDiagnostics(t, s, uri, full.ResultID).(_prot.FullDocumentDiagnosticReport)
+	assert.True(t, ok)
+	assert.Equal(t, 2, len(changed.Items))
+	assert.NotEqual(t, *full.ResultID, *changed.ResultID)
+}
+
+func TestWorkspaceDiagnostic_only_analyses_documents_again_when_symbols_change(t *testing.T) {
+	folder := writeWorkspaceFiles(t, t.TempDir(), map[string]string{
+		"app.c3": "module app;\nfn void main() {\n\thelper();\n}\n",
+		"lib.c3": "module app;\nfn void other() {}\n",
+	})
+	s := newTestServer(t, folder)
+	s.pullDiagnostics = true
+	s.indexWorkspace(nil)
+	app := filepath.Join(folder, "app.c3")
+
+	report, err := s.WorkspaceDiagnostic(&glsp.Context{}, &_prot.WorkspaceDiagnosticParams{})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(report.Items))
+	assert.True(t, s.state.HasCurrentAnalysis(app))
+
+	previous := []_prot.PreviousResultID{}
+	for _, item := range report.Items {
+		full := item.(_prot.WorkspaceFullDocumentDiagnosticReport)
+		previous = append(previous, _prot.PreviousResultID{URI: full.URI, Value: *full.ResultID})
+	}
+	report, err = s.WorkspaceDiagnostic(&glsp.Context{}, &_prot.WorkspaceDiagnosticParams{PreviousResultIDs: previous})
+	assert.Nil(t, err)
+	for _, item := range report.Items {
+		_, ok := item.(_prot.WorkspaceUnchangedDocumentDiagnosticReport)
+		assert.True(t, ok)
+	}
+
+	// Declaring the missing function in another document changes the analysis of app.c3.
+	lib := document.NewDocumentFromString(filepath.Join(folder, "lib.c3"), "module app;\nfn void helper() {}\n")
+	s.state.RefreshDocumentIdentifiers(&lib, s.parser)
+	assert.False(t, s.state.HasCurrentAnalysis(app))
+	assert.Empty(t, s.documentDiagnostics(app))
+}
+
+func TestInitialize_pushes_diagnostics_to_pull_clients_without_refresh_support(t *testing.T) {
+	s := newTestServer(t)
+	rawParams := json.RawMessage(`{"capabilities": {"textDocument": {"diagnostic": {}}}}`)
+	params := &protocol.InitializeParams{}
+	assert.Nil(t, json.Unmarshal(rawParams, params))
+
+	result, err := s.Initialize("c3lsp-test", "test", protocol.ServerCapabilities{}, &glsp.Context{Params: rawParams}, params)
+
+	assert.Nil(t, err)
+	assert.False(t, s.pullDiagnostics)
+	assert.Nil(t, result.(_prot.InitializeResult).Capabilities.DiagnosticProvider)
+}
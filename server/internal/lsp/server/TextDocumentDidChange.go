@@ -1,12 +1,16 @@
 package server
 
 import (
+	"github.com/pherrymason/c3-lsp/pkg/utils"
 	"github.com/tliron/glsp"
 	protocol "github.com/tliron/glsp/protocol_3_16"
 )
 
 func (s *Server) TextDocumentDidChange(context *glsp.Context, params *protocol.DidChangeTextDocumentParams) error {
+	docId := utils.NormalizePath(params.TextDocument.URI)
 	s.state.UpdateDocument(params.TextDocument.URI, params.ContentChanges, s.parser)
+	s.publishSyntaxErrors(docId, context.Notify)
+	s.analyzeDocumentLater(docId, context.Notify)
 
 	s.RunDiagnostics(s.state, context.Notify, true)
 
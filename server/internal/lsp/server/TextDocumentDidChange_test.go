@@ -0,0 +1,38 @@
+package server
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/pherrymason/c3-lsp/pkg/document"
+	"github.com/pherrymason/c3-lsp/pkg/fs"
+	"github.com/pherrymason/c3-lsp/pkg/option"
+	"github.com/stretchr/testify/assert"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func TestTextDocumentDidChange_publishes_syntax_errors_right_away(t *testing.T) {
+	s := newTestServer(t)
+	uri := fs.ConvertPathToURI(filepath.Join(t.TempDir(), "app.c3"), option.None[string]())
+	s.state.OpenDocument(document.NewDocumentFromDocURI(uri, "module app;\nfn void main() {}\n", 1), s.parser)
+
+	published := []protocol.PublishDiagnosticsParams{}
+	notify := func(method string, params any) {
+		if method == protocol.ServerTextDocumentPublishDiagnostics {
+			published = append(published, params.(protocol.PublishDiagnosticsParams))
+		}
+	}
+	err := s.TextDocumentDidChange(&glsp.Context{Notify: notify}, &protocol.DidChangeTextDocumentParams{
+		TextDocument: protocol.VersionedTextDocumentIdentifier{TextDocumentIdentifier: protocol.TextDocumentIdentifier{URI: uri}, Version: 2},
+		ContentChanges: []any{
+			protocol.TextDocumentContentChangeEventWhole{Text: "module app;\nfn void main() {\n\tint x = 1\n}\n"},
+		},
+	})
+	assert.Nil(t, err)
+
+	// The semantic analysis is still waiting for the document to stop changing.
+	assert.Len(t, published, 1)
+	assert.Len(t, published[0].Diagnostics, 1)
+	assert.Equal(t, "Missing ';'", published[0].Diagnostics[0].Message)
+}
@@ -1,11 +1,20 @@
 package server
 
 import (
+	"github.com/pherrymason/c3-lsp/pkg/utils"
 	"github.com/tliron/glsp"
 	protocol "github.com/tliron/glsp/protocol_3_16"
 )
 
 func (h *Server) TextDocumentDidClose(context *glsp.Context, params *protocol.DidCloseTextDocumentParams) error {
-	h.state.CloseDocument(params.TextDocument.URI)
+	docId := utils.NormalizePath(params.TextDocument.URI)
+	h.state.CloseDocument(params.TextDocument.URI, h.parser)
+	delete(h.openDocuments, params.TextDocument.URI)
+	delete(h.semanticTokens, docId)
+	h.forgetDocumentAnalysis(docId)
+
+	// Syntax errors are only reported for open documents.
+	h.state.RemoveAnalysisDiagnostics(docId)
+	h.publishDiagnostics(docId, context.Notify)
 	return nil
 }
@@ -25,7 +25,9 @@ func (h *Server) TextDocumentDidOpen(context *glsp.Context, params *protocol.Did
 	}
 
 	doc := document.NewDocumentFromDocURI(params.TextDocument.URI, params.TextDocument.Text, params.TextDocument.Version)
-	h.state.RefreshDocumentIdentifiers(doc, h.parser)
+	h.state.OpenDocument(doc, h.parser)
+	h.openDocuments[params.TextDocument.URI] = true
+	h.analyzeDocument(doc, context.Notify)
 
 	return nil
 }
@@ -7,6 +7,10 @@ import (
 
 // Support "Hover"
 func (s *Server) TextDocumentDidSave(ctx *glsp.Context, params *protocol.DidSaveTextDocumentParams) error {
+	if s.isProjectFile(params.TextDocument.URI) {
+		s.indexWorkspaceInBackground(ctx, true)
+		return nil
+	}
 	s.RunDiagnostics(s.state, ctx.Notify, true)
 	return nil
 }
@@ -0,0 +1,13 @@
+package server
+
+import (
+	"github.com/pherrymason/c3-lsp/pkg/utils"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// Support "Document Symbols"
+func (h *Server) TextDocumentDocumentSymbol(context *glsp.Context, params *protocol.DocumentSymbolParams) (any, error) {
+	docId := utils.NormalizePath(params.TextDocument.URI)
+	return h.search.BuildDocumentSymbols(docId, h.state.ForDocument(docId)), nil
+}
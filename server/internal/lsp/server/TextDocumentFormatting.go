@@ -0,0 +1,111 @@
+package server
+
+import (
+	"errors"
+
+	"github.com/pherrymason/c3-lsp/internal/lsp/formatter"
+	"github.com/pherrymason/c3-lsp/pkg/utils"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// Support "Formatting"
+func (h *Server) TextDocumentFormatting(context *glsp.Context, params *protocol.DocumentFormattingParams) ([]protocol.TextEdit, error) {
+	doc := h.state.GetDocument(utils.NormalizePath(params.TextDocument.URI))
+	if doc == nil {
+		return nil, nil
+	}
+
+	formatted, err := formatter.Format(doc.SourceCode.Text, h.formatterOptions(doc.URI, params.Options))
+	if err != nil {
+		return formattingError(err)
+	}
+
+	return toTextEdits(formatter.DiffEdits(doc.SourceCode.Text, formatted)), nil
+}
+
+// Support "Range Formatting"
+func (h *Server) TextDocumentRangeFormatting(context *glsp.Context, params *protocol.DocumentRangeFormattingParams) ([]protocol.TextEdit, error) {
+	endLine := params.Range.End.Line
+	// A selection ending at the start of a line does not include that line.
+	if params.Range.End.Character == 0 && endLine > params.Range.Start.Line {
+		endLine--
+	}
+
+	return h.formatLines(params.TextDocument.URI, params.Range.Start.Line, endLine, params.Options)
+}
+
+// Support "On Type Formatting"
+func (h *Server) TextDocumentOnTypeFormatting(context *glsp.Context, params *protocol.DocumentOnTypeFormattingParams) ([]protocol.TextEdit, error) {
+	line := params.Position.Line
+	// After a line break, the line just finished is the one to format.
+	if params.Ch == "\n" && line > 0 {
+		line--
+	}
+
+	return h.formatLines(params.TextDocument.URI, line, line, params.Options)
+}
+
+func (h *Server) formatLines(uri protocol.DocumentUri, startLine protocol.UInteger, endLine protocol.UInteger, clientOptions protocol.FormattingOptions) ([]protocol.TextEdit, error) {
+	doc := h.state.GetDocument(utils.NormalizePath(uri))
+	if doc == nil {
+		return nil, nil
+	}
+
+	edits, err := formatter.FormatRange(doc.SourceCode.Text, uint(startLine), uint(endLine), h.formatterOptions(doc.URI, clientOptions))
+	if err != nil {
+		return formattingError(err)
+	}
+
+	return toTextEdits(edits), nil
+}
+
+// formatterOptions combines the settings from the c3lsp.json of the folder of the document with
+// the indentation requested by the client.
+func (h *Server) formatterOptions(docId string, clientOptions protocol.FormattingOptions) formatter.Options {
+	options := formatter.DefaultOptions()
+	if tabSize, ok := clientOptions[protocol.FormattingOptionTabSize].(float64); ok && tabSize > 0 {
+		options.IndentSize = int(tabSize)
+	}
+	if insertSpaces, ok := clientOptions[protocol.FormattingOptionInsertSpaces].(bool); ok {
+		options.UseTabs = !insertSpaces
+	}
+
+	config := h.optionsFor(docId).Formatting
+	if config.UseTabs.IsSome() {
+		options.UseTabs = config.UseTabs.Get()
+	}
+	if config.IndentSize.IsSome() {
+		options.IndentSize = config.IndentSize.Get()
+	}
+	if config.BraceStyle != "" {
+		options.BraceStyle = config.BraceStyle
+	}
+	options.MaxLineWidth = config.MaxLineWidth
+	options.AlignStructMembers = config.AlignStructMembers
+	options.AlignEnumValues = config.AlignEnumValues
+
+	return options
+}
+
+// formattingError leaves documents with syntax errors untouched instead of reporting them,
+// as diagnostics already do.
+func formattingError(err error) ([]protocol.TextEdit, error) {
+	if errors.Is(err, formatter.ErrSyntaxErrors) {
+		return nil, nil
+	}
+
+	return nil, err
+}
+
+func toTextEdits(edits []formatter.Edit) []protocol.TextEdit {
+	textEdits := []protocol.TextEdit{}
+	for _, edit := range edits {
+		textEdits = append(textEdits, protocol.TextEdit{
+			Range:   edit.Range.ToLSP(),
+			NewText: edit.NewText,
+		})
+	}
+
+	return textEdits
+}
@@ -13,7 +13,7 @@ import (
 func (h *Server) TextDocumentHover(context *glsp.Context, params *protocol.HoverParams) (*protocol.Hover, error) {
 	pos := symbols.NewPositionFromLSPPosition(params.Position)
 	docId := utils.NormalizePath(params.TextDocument.URI)
-	foundSymbolOption := h.search.FindSymbolDeclarationInWorkspace(docId, pos, h.state)
+	foundSymbolOption := h.search.FindSymbolDeclarationInWorkspace(docId, pos, h.state.ForDocument(docId))
 	if foundSymbolOption.IsNone() {
 		return nil, nil
 	}
@@ -0,0 +1,21 @@
+package server
+
+import (
+	_prot "github.com/pherrymason/c3-lsp/internal/lsp/protocol"
+	"github.com/pherrymason/c3-lsp/pkg/symbols"
+	"github.com/pherrymason/c3-lsp/pkg/utils"
+	"github.com/tliron/glsp"
+)
+
+// Support "Inlay Hints"
+func (h *Server) TextDocumentInlayHint(context *glsp.Context, params *_prot.InlayHintParams) ([]_prot.InlayHint, error) {
+	limit := symbols.NewRange(
+		uint(params.Range.Start.Line),
+		uint(params.Range.Start.Character),
+		uint(params.Range.End.Line),
+		uint(params.Range.End.Character),
+	)
+
+	docId := utils.NormalizePath(params.TextDocument.URI)
+	return h.search.BuildInlayHints(docId, limit, h.state.ForDocument(docId)), nil
+}
@@ -0,0 +1,31 @@
+package server
+
+import (
+	_prot "github.com/pherrymason/c3-lsp/internal/lsp/protocol"
+	"github.com/pherrymason/c3-lsp/pkg/symbols"
+	"github.com/pherrymason/c3-lsp/pkg/utils"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// Support "Find All References"
+func (h *Server) TextDocumentReferences(context *glsp.Context, params *protocol.ReferenceParams) ([]protocol.Location, error) {
+	docId := utils.NormalizePath(params.TextDocument.URI)
+	references := h.search.FindReferencesInWorkspace(
+		docId,
+		symbols.NewPositionFromLSPPosition(params.Position),
+		h.state.ForDocument(docId),
+		params.Context.IncludeDeclaration,
+	)
+
+	stdlibPath := h.optionsFor(docId).C3.StdlibPath
+	locations := []protocol.Location{}
+	for _, reference := range references {
+		locations = append(locations, protocol.Location{
+			URI:   h.state.DocumentURI(reference.DocId, stdlibPath),
+			Range: _prot.Lsp_NewRangeFromRange(reference.Range),
+		})
+	}
+
+	return locations, nil
+}
@@ -0,0 +1,55 @@
+package server
+
+import (
+	"errors"
+
+	_prot "github.com/pherrymason/c3-lsp/internal/lsp/protocol"
+	"github.com/pherrymason/c3-lsp/internal/lsp/search"
+	"github.com/pherrymason/c3-lsp/pkg/symbols"
+	"github.com/pherrymason/c3-lsp/pkg/utils"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// Support "Prepare Rename"
+func (h *Server) TextDocumentPrepareRename(context *glsp.Context, params *protocol.PrepareRenameParams) (any, error) {
+	docId := utils.NormalizePath(params.TextDocument.URI)
+	_, identifierRange, err := h.search.FindRenameTarget(
+		docId,
+		symbols.NewPositionFromLSPPosition(params.Position),
+		h.state.ForDocument(docId),
+	)
+	if errors.Is(err, search.ErrNothingToRename) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return _prot.Lsp_NewRangeFromRange(identifierRange), nil
+}
+
+// Support "Rename"
+func (h *Server) TextDocumentRename(context *glsp.Context, params *protocol.RenameParams) (*protocol.WorkspaceEdit, error) {
+	docId := utils.NormalizePath(params.TextDocument.URI)
+	references, err := h.search.Rename(
+		docId,
+		symbols.NewPositionFromLSPPosition(params.Position),
+		params.NewName,
+		h.state.ForDocument(docId),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	stdlibPath := h.optionsFor(docId).C3.StdlibPath
+	changes := map[protocol.DocumentUri][]protocol.TextEdit{}
+	for _, reference := range references {
+		uri := h.state.DocumentURI(reference.DocId, stdlibPath)
+		changes[uri] = append(changes[uri], protocol.TextEdit{
+			Range:   _prot.Lsp_NewRangeFromRange(reference.Range),
+			NewText: params.NewName,
+		})
+	}
+
+	return &protocol.WorkspaceEdit{Changes: changes}, nil
+}
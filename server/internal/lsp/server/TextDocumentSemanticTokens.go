@@ -0,0 +1,197 @@
+package server
+
+import (
+	"strconv"
+
+	"github.com/pherrymason/c3-lsp/internal/lsp/search"
+	"github.com/pherrymason/c3-lsp/pkg/option"
+	"github.com/pherrymason/c3-lsp/pkg/symbols"
+	"github.com/pherrymason/c3-lsp/pkg/utils"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+var semanticTokenTypes = []protocol.SemanticTokenType{
+	protocol.SemanticTokenTypeNamespace,
+	protocol.SemanticTokenTypeType,
+	protocol.SemanticTokenTypeStruct,
+	protocol.SemanticTokenTypeEnum,
+	protocol.SemanticTokenTypeInterface,
+	protocol.SemanticTokenTypeTypeParameter,
+	protocol.SemanticTokenTypeParameter,
+	protocol.SemanticTokenTypeVariable,
+	protocol.SemanticTokenTypeProperty,
+	protocol.SemanticTokenTypeEnumMember,
+	protocol.SemanticTokenTypeFunction,
+	protocol.SemanticTokenTypeMethod,
+	protocol.SemanticTokenTypeMacro,
+}
+
+var semanticTokenModifiers = []protocol.SemanticTokenModifier{
+	protocol.SemanticTokenModifierDeclaration,
+	protocol.SemanticTokenModifierReadonly,
+	protocol.SemanticTokenModifierDefaultLibrary,
+}
+
+// semanticTokensResult is the last set of tokens sent for a document, kept to answer delta requests.
+type semanticTokensResult struct {
+	resultId string
+	data     []protocol.UInteger
+}
+
+func semanticTokensLegend() protocol.SemanticTokensLegend {
+	legend := protocol.SemanticTokensLegend{
+		TokenTypes:     []string{},
+		TokenModifiers: []string{},
+	}
+	for _, tokenType := range semanticTokenTypes {
+		legend.TokenTypes = append(legend.TokenTypes, string(tokenType))
+	}
+	for _, modifier := range semanticTokenModifiers {
+		legend.TokenModifiers = append(legend.TokenModifiers, string(modifier))
+	}
+
+	return legend
+}
+
+// Support "Semantic Tokens" of a whole document
+func (h *Server) TextDocumentSemanticTokensFull(context *glsp.Context, params *protocol.SemanticTokensParams) (*protocol.SemanticTokens, error) {
+	docId := utils.NormalizePath(params.TextDocument.URI)
+	tokens := h.search.BuildSemanticTokens(docId, h.state.ForDocument(docId), option.None[symbols.Range]())
+
+	result := h.storeSemanticTokens(docId, encodeSemanticTokens(tokens))
+
+	return &protocol.SemanticTokens{
+		ResultID: &result.resultId,
+		Data:     result.data,
+	}, nil
+}
+
+// Support "Semantic Tokens" of a whole document, as changes to the previous result.
+// Returns: SemanticTokens | SemanticTokensDelta
+func (h *Server) TextDocumentSemanticTokensFullDelta(context *glsp.Context, params *protocol.SemanticTokensDeltaParams) (any, error) {
+	docId := utils.NormalizePath(params.TextDocument.URI)
+	previous, found := h.semanticTokens[docId]
+
+	tokens := h.search.BuildSemanticTokens(docId, h.state.ForDocument(docId), option.None[symbols.Range]())
+	result := h.storeSemanticTokens(docId, encodeSemanticTokens(tokens))
+
+	if !found || previous.resultId != params.PreviousResultID {
+		return &protocol.SemanticTokens{
+			ResultID: &result.resultId,
+			Data:     result.data,
+		}, nil
+	}
+
+	return &protocol.SemanticTokensDelta{
+		ResultId: &result.resultId,
+		Edits:    diffSemanticTokens(previous.data, result.data),
+	}, nil
+}
+
+// Support "Semantic Tokens" of a range of a document
+// Returns: SemanticTokens | nil
+func (h *Server) TextDocumentSemanticTokensRange(context *glsp.Context, params *protocol.SemanticTokensRangeParams) (any, error) {
+	docId := utils.NormalizePath(params.TextDocument.URI)
+	limit := symbols.NewRange(
+		uint(params.Range.Start.Line),
+		uint(params.Range.Start.Character),
+		uint(params.Range.End.Line),
+		uint(params.Range.End.Character),
+	)
+	tokens := h.search.BuildSemanticTokens(docId, h.state.ForDocument(docId), option.Some(limit))
+
+	return &protocol.SemanticTokens{
+		Data: encodeSemanticTokens(tokens),
+	}, nil
+}
+
+func (h *Server) storeSemanticTokens(docId string, data []protocol.UInteger) semanticTokensResult {
+	h.semanticTokensResultId++
+	result := semanticTokensResult{
+		resultId: strconv.Itoa(h.semanticTokensResultId),
+		data:     data,
+	}
+	h.semanticTokens[docId] = result
+
+	return result
+}
+
+// encodeSemanticTokens encodes tokens using the relative format of the protocol:
+// each token is 5 integers (deltaLine, deltaStartChar, length, tokenType, tokenModifiers).
+func encodeSemanticTokens(tokens []search.SemanticToken) []protocol.UInteger {
+	data := []protocol.UInteger{}
+	previousLine := uint(0)
+	previousChar := uint(0)
+
+	for _, token := range tokens {
+		// Tokens spanning multiple lines are not supported by all clients.
+		if token.Range.Start.Line != token.Range.End.Line {
+			continue
+		}
+
+		deltaLine := token.Range.Start.Line - previousLine
+		deltaChar := token.Range.Start.Character
+		if deltaLine == 0 {
+			deltaChar = token.Range.Start.Character - previousChar
+		}
+
+		modifiers := 0
+		for _, modifier := range token.Modifiers {
+			for i, known := range semanticTokenModifiers {
+				if modifier == known {
+					modifiers |= 1 << i
+				}
+			}
+		}
+
+		data = append(data,
+			protocol.UInteger(deltaLine),
+			protocol.UInteger(deltaChar),
+			protocol.UInteger(token.Range.End.Character-token.Range.Start.Character),
+			protocol.UInteger(semanticTokenTypeIndex(token.Type)),
+			protocol.UInteger(modifiers),
+		)
+
+		previousLine = token.Range.Start.Line
+		previousChar = token.Range.Start.Character
+	}
+
+	return data
+}
+
+func semanticTokenTypeIndex(tokenType protocol.SemanticTokenType) int {
+	for i, known := range semanticTokenTypes {
+		if known == tokenType {
+			return i
+		}
+	}
+
+	return 0
+}
+
+// diffSemanticTokens returns a single edit replacing the part that changed between
+// `previous` and `current`, after skipping their common prefix and suffix.
+func diffSemanticTokens(previous []protocol.UInteger, current []protocol.UInteger) []protocol.SemanticTokensEdit {
+	start := 0
+	for start < len(previous) && start < len(current) && previous[start] == current[start] {
+		start++
+	}
+
+	previousEnd := len(previous)
+	currentEnd := len(current)
+	for previousEnd > start && currentEnd > start && previous[previousEnd-1] == current[currentEnd-1] {
+		previousEnd--
+		currentEnd--
+	}
+
+	if start == previousEnd && start == currentEnd {
+		return []protocol.SemanticTokensEdit{}
+	}
+
+	return []protocol.SemanticTokensEdit{{
+		Start:       protocol.UInteger(start),
+		DeleteCount: protocol.UInteger(previousEnd - start),
+		Data:        current[start:currentEnd],
+	}}
+}
@@ -25,7 +25,7 @@ func (h *Server) TextDocumentSignatureHelp(context *glsp.Context, params *protoc
 	foundSymbolOption := h.search.FindSymbolDeclarationInWorkspace(
 		docId,
 		posOption.Get(),
-		h.state,
+		h.state.ForDocument(docId),
 	)
 	if foundSymbolOption.IsNone() {
 		return nil, nil
@@ -0,0 +1,52 @@
+package server
+
+import (
+	"encoding/json"
+
+	"github.com/pherrymason/c3-lsp/pkg/cast"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// editorSettingsSection is the section of the editor settings holding the server configuration.
+const editorSettingsSection = "c3lsp"
+
+// Support "workspace/didChangeConfiguration"
+func (s *Server) WorkspaceDidChangeConfiguration(context *glsp.Context, params *protocol.DidChangeConfigurationParams) error {
+	if s.pullConfiguration {
+		s.pullEditorSettings(context)
+	} else {
+		// Clients not supporting "workspace/configuration" send all their settings.
+		var sections map[string]json.RawMessage
+		if data, err := json.Marshal(params.Settings); err == nil {
+			json.Unmarshal(data, &sections)
+		}
+		s.editorSettings = parseEditorSettings(sections[editorSettingsSection])
+	}
+
+	s.configurationChanged(context.Notify)
+
+	return nil
+}
+
+// pullEditorSettings asks the client for the server section of its settings.
+func (s *Server) pullEditorSettings(context *glsp.Context) {
+	var result []json.RawMessage
+	context.Call(protocol.ServerWorkspaceConfiguration, protocol.ConfigurationParams{
+		Items: []protocol.ConfigurationItem{{Section: cast.ToPtr(editorSettingsSection)}},
+	}, &result)
+
+	if len(result) > 0 {
+		s.editorSettings = parseEditorSettings(result[0])
+	}
+}
+
+// parseEditorSettings returns nil when the editor has no valid settings for the server.
+func parseEditorSettings(data json.RawMessage) *ServerOptsJson {
+	var settings *ServerOptsJson
+	if len(data) == 0 || json.Unmarshal(data, &settings) != nil {
+		return nil
+	}
+
+	return settings
+}
@@ -1,21 +1,65 @@
 package server
 
 import (
+	"path/filepath"
+
 	"github.com/pherrymason/c3-lsp/pkg/utils"
 	"github.com/tliron/glsp"
 	protocol "github.com/tliron/glsp/protocol_3_16"
 )
 
+// WorkspaceDidChangeWatchedFiles updates the index with the files changed outside the editor.
+// Created and deleted C3 files, or a modified project.json, make the workspace be indexed again,
+// which finds the files to add or remove. Modified files are parsed again, unless they are open.
 func (h *Server) WorkspaceDidChangeWatchedFiles(context *glsp.Context, params *protocol.DidChangeWatchedFilesParams) error {
+	reindexWorkspace := false
+	reloadConfiguration := false
+	modified := []string{}
+	for _, change := range params.Changes {
+		switch {
+		case h.isProjectFile(change.URI):
+			reindexWorkspace = true
+		case h.isConfigurationFile(change.URI):
+			reloadConfiguration = true
+		case !isC3File(change.URI):
+			continue
+		case change.Type == protocol.FileChangeTypeChanged:
+			modified = append(modified, utils.NormalizePath(change.URI))
+		default:
+			reindexWorkspace = true
+		}
+	}
+
+	if reloadConfiguration {
+		h.configurationChanged(context.Notify)
+	}
+	if !reindexWorkspace && len(modified) == 0 {
+		return nil
+	}
+
+	go func() {
+		if reindexWorkspace {
+			h.indexWorkspace(nil)
+		}
+		h.reindexFiles(modified)
+		h.RunDiagnostics(h.state, context.Notify, true)
+	}()
+
 	return nil
 }
 
+func isC3File(uri protocol.DocumentUri) bool {
+	ext := filepath.Ext(uri)
+	return ext == ".c3" || ext == ".c3i"
+}
+
 func (h *Server) WorkspaceDidDeleteFiles(context *glsp.Context, params *protocol.DeleteFilesParams) error {
 	for _, file := range params.Files {
 		// The file has been removed! update our indices
 		docId := utils.NormalizePath(file.URI)
 		//h.documents.Delete(file.URI)
 		h.state.DeleteDocument(docId)
+		h.parser.ForgetDocument(docId)
 	}
 
 	return nil
@@ -28,6 +72,7 @@ func (h *Server) WorkspaceDidRenameFiles(context *glsp.Context, params *protocol
 		oldDocId := utils.NormalizePath(file.OldURI)
 		newDocId := utils.NormalizePath(file.NewURI)
 		h.state.RenameDocument(oldDocId, newDocId)
+		h.parser.ForgetDocument(oldDocId)
 	}
 
 	return nil
@@ -0,0 +1,40 @@
+package server
+
+import (
+	"slices"
+
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// Support "workspace/didChangeWorkspaceFolders"
+// Added folders are configured from their c3lsp.json and indexed, the files of removed
+// folders are forgotten, unless another folder uses them.
+func (s *Server) WorkspaceDidChangeWorkspaceFolders(context *glsp.Context, params *protocol.DidChangeWorkspaceFoldersParams) error {
+	paths := []string{}
+	for _, folder := range s.workspaceFolders() {
+		paths = append(paths, folder.path)
+	}
+
+	for _, removed := range params.Event.Removed {
+		path := workspaceFolderPath(removed.URI)
+		paths = slices.DeleteFunc(paths, func(p string) bool { return p == path })
+		// Its configuration is no longer used.
+		s.publishConfigurationDiagnostics(path, nil, context.Notify)
+	}
+	for _, added := range params.Event.Added {
+		if path := workspaceFolderPath(added.URI); !slices.Contains(paths, path) {
+			paths = append(paths, path)
+		}
+	}
+
+	s.setWorkspaceFolders(paths)
+	s.reloadConfiguration()
+	for _, folder := range s.workspaceFolders() {
+		s.publishConfigurationDiagnostics(folder.path, folder.configurationError, context.Notify)
+	}
+	s.shared.indexStdlib()
+	s.indexWorkspaceInBackground(context, true)
+
+	return nil
+}
@@ -0,0 +1,73 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/pherrymason/c3-lsp/internal/lsp/diagnostics"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// commandExportDiagnostics returns the diagnostics of the workspace in the format given as
+// argument: "json", the default, "sarif" or "text".
+const commandExportDiagnostics = "c3lsp.exportDiagnostics"
+
+// Support "workspace/executeCommand"
+func (s *Server) WorkspaceExecuteCommand(context *glsp.Context, params *protocol.ExecuteCommandParams) (any, error) {
+	switch params.Command {
+	case commandExportDiagnostics:
+		return s.exportDiagnostics(params.Arguments)
+	}
+
+	return nil, fmt.Errorf("unknown command %q", params.Command)
+}
+
+// exportDiagnostics writes the diagnostics report. JSON and SARIF reports are returned as
+// objects, so clients do not need to decode them from a string.
+func (s *Server) exportDiagnostics(arguments []any) (any, error) {
+	format := diagnostics.FormatJSON
+	if len(arguments) > 0 {
+		argument, ok := arguments[0].(string)
+		if !ok || !diagnostics.IsValidFormat(argument) {
+			return nil, fmt.Errorf("%s: unknown format %v, expected json, sarif or text", commandExportDiagnostics, arguments[0])
+		}
+		format = argument
+	}
+
+	var output bytes.Buffer
+	if err := s.diagnosticsReport(nil).Write(&output, format); err != nil {
+		return nil, err
+	}
+	if format == diagnostics.FormatText {
+		return output.String(), nil
+	}
+
+	return json.RawMessage(output.Bytes()), nil
+}
+
+// diagnosticsReport gathers the diagnostics of the documents, or of every document of the
+// workspace when none is given: those of the last check of c3c, and the syntax errors and
+// problems of the semantic checks, analysed again. Libraries are left out.
+func (s *Server) diagnosticsReport(docIds []string) diagnostics.Report {
+	if docIds == nil {
+		docIds = s.state.DiagnosableDocumentIds()
+	}
+
+	report := diagnostics.Report{ToolName: s.appName, ToolVersion: s.version}
+	if folders := s.workspaceFolders(); len(folders) > 0 {
+		report.Root = folders[0].path
+	}
+	for _, docId := range docIds {
+		if s.state.IsLibraryDocument(docId) {
+			continue
+		}
+
+		if found := s.documentDiagnostics(docId); len(found) > 0 {
+			report.Files = append(report.Files, diagnostics.FileDiagnostics{Path: docId, Diagnostics: found})
+		}
+	}
+
+	return report
+}
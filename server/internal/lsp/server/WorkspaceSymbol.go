@@ -0,0 +1,11 @@
+package server
+
+import (
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// Support "Workspace Symbols"
+func (h *Server) WorkspaceSymbol(context *glsp.Context, params *protocol.WorkspaceSymbolParams) ([]protocol.SymbolInformation, error) {
+	return h.search.BuildWorkspaceSymbols(params.Query, h.state, h.serverOptions().C3.StdlibPath), nil
+}
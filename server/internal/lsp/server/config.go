@@ -1,95 +1,183 @@
 package server
 
 import (
+	"bytes"
 	"encoding/json"
-	"io"
+	"errors"
+	"fmt"
 	"log"
+	"maps"
 	"os"
+	"path/filepath"
+	"slices"
 	"time"
 
+	"github.com/bep/debounce"
+
 	"github.com/pherrymason/c3-lsp/internal/c3c"
+	"github.com/pherrymason/c3-lsp/internal/lsp/diagnostics"
+	"github.com/pherrymason/c3-lsp/internal/lsp/formatter"
+	"github.com/pherrymason/c3-lsp/pkg/cast"
+	"github.com/pherrymason/c3-lsp/pkg/fs"
 	"github.com/pherrymason/c3-lsp/pkg/option"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
 )
 
 type DiagnosticsOpts struct {
 	Enabled bool          `json:"enabled"`
 	Delay   time.Duration `json:"delay"`
+	// Timeout of c3c when checking a target, in milliseconds. 0 waits forever.
+	Timeout time.Duration `json:"timeout"`
+	// Severity of each semantic check, by diagnostic code.
+	Checks map[string]diagnostics.Severity `json:"checks"`
+}
+
+type FormattingOpts struct {
+	// Indentation requested by the client is used when these are not configured.
+	UseTabs    option.Option[bool]
+	IndentSize option.Option[int]
+
+	BraceStyle         formatter.BraceStyle
+	MaxLineWidth       int
+	AlignStructMembers bool
+	AlignEnumValues    bool
 }
 
 // ServerOpts holds the options to create a new Server.
 type ServerOpts struct {
 	C3          c3c.C3Opts      `json:"C3Opts"`
 	Diagnostics DiagnosticsOpts `json:"Diagnostics"`
+	Formatting  FormattingOpts  `json:"Formatting"`
 
 	LogFilepath      option.Option[string]
 	SendCrashReports bool
 	Debug            bool
+
+	// Address to listen on for clients, "tcp:HOST:PORT" or "ws:HOST:PORT". Stdio is used when none.
+	Listen option.Option[string]
+	// Connections share the index of the same server instead of having their own.
+	SharedState bool
 }
 
+// ServerOptsJson is the configuration read from c3lsp.json, or received from the editor settings
+// in the "c3lsp" section. Settings left out keep the value given in the command line.
 type ServerOptsJson struct {
 	C3 struct {
 		Version     *string  `json:"version,omitempty"`
 		Path        *string  `json:"path,omitempty"`
 		StdlibPath  *string  `json:"stdlib-path,omitempty"`
 		CompileArgs []string `json:"compile-args"`
+		Targets     []string `json:"targets"`
 	}
 
 	Diagnostics struct {
-		Enabled bool          `json:"enabled"`
-		Delay   time.Duration `json:"delay"`
+		Enabled *bool             `json:"enabled,omitempty"`
+		Delay   *time.Duration    `json:"delay,omitempty"`
+		Timeout *time.Duration    `json:"timeout,omitempty"`
+		Checks  map[string]string `json:"checks"`
 	}
-}
 
-func (s *Server) loadServerConfigurationForWorkspace(path string) {
-	file, err := os.Open(path + "/c3lsp.json")
-	if err != nil {
-		// No configuration project file found.
-		log.Print("No configuration " + path + "/c3lsp.json found")
-		return
+	Formatting struct {
+		IndentStyle        *string `json:"indent-style,omitempty"`
+		IndentSize         *int    `json:"indent-size,omitempty"`
+		BraceStyle         *string `json:"brace-style,omitempty"`
+		MaxLineWidth       *int    `json:"max-line-width,omitempty"`
+		AlignStructMembers *bool   `json:"align-struct-members,omitempty"`
+		AlignEnumValues    *bool   `json:"align-enum-values,omitempty"`
 	}
-	defer file.Close()
+}
 
-	log.Print("Reading configuration " + path + "/c3lsp.json")
+const configurationFile = "c3lsp.json"
 
-	// Lee el archivo
-	data, err := io.ReadAll(file)
+// configurationError is a c3lsp.json that cannot be used. It is reported as a diagnostic on the file.
+type configurationError struct {
+	path   string
+	offset int64
+	err    error
+}
+
+func (e configurationError) Error() string {
+	return fmt.Sprintf("%s: %v", e.path, e.err)
+}
+
+// readServerConfiguration reads the c3lsp.json of the workspace at path. It returns nil when there is none.
+func readServerConfiguration(path string) (*ServerOptsJson, error) {
+	filePath := filepath.Join(path, configurationFile)
+	data, err := os.ReadFile(filePath)
+	if errors.Is(err, os.ErrNotExist) {
+		log.Print("No configuration " + filePath + " found")
+		return nil, nil
+	}
 	if err != nil {
-		log.Fatalf("Error reading config json: %v", err)
+		return nil, configurationError{path: filePath, err: err}
 	}
+
+	log.Print("Reading configuration " + filePath)
 	log.Printf("%s", data)
 
-	var options ServerOptsJson
-	err = json.Unmarshal(data, &options)
-	if err != nil {
-		log.Fatalf("Error deserializing config json: %v", err)
-	}
+	var config ServerOptsJson
+	if err := json.Unmarshal(data, &config); err != nil {
+		var offset int64
+		var syntaxError *json.SyntaxError
+		var typeError *json.UnmarshalTypeError
+		// Offsets are past the invalid character, or past the value of the wrong type.
+		if errors.As(err, &syntaxError) {
+			offset = syntaxError.Offset - 1
+		} else if errors.As(err, &typeError) {
+			offset = typeError.Offset - 1
+		}
 
-	if options.C3.StdlibPath != nil {
-		s.options.C3.StdlibPath = option.Some(*options.C3.StdlibPath)
-		log.Printf("Stdlib:%s", *options.C3.StdlibPath)
-		log.Printf("Setted Stdlib:%s", s.options.C3.StdlibPath.Get())
+		return nil, configurationError{path: filePath, offset: offset, err: err}
 	}
 
-	if options.C3.Version != nil {
-		s.options.C3.Version = option.Some(*options.C3.Version)
+	return &config, nil
+}
+
+// reloadConfiguration sets the options of every workspace folder from the command line, the editor
+// settings and the c3lsp.json of the folder, in increasing priority. Settings removed from them go
+// back to their previous source. A folder with an invalid c3lsp.json keeps its current options.
+// Each folder sees the stdlib of its own language version, or its stdlib sources once indexed.
+// The options of the server are those of the main folder, used for files outside every folder.
+func (s *Server) reloadConfiguration() {
+	options := s.defaultOptions
+	options.C3.CompileArgs = slices.Clone(options.C3.CompileArgs)
+	options.C3.Targets = slices.Clone(options.C3.Targets)
+	options.Diagnostics.Checks = maps.Clone(options.Diagnostics.Checks)
+	if options.Diagnostics.Checks == nil {
+		options.Diagnostics.Checks = diagnostics.DefaultChecks()
+	}
+	if s.editorSettings != nil {
+		options.applyConfiguration(*s.editorSettings)
 	}
 
-	if options.C3.Path != nil {
-		s.options.C3.Path = option.Some(*options.C3.Path)
-		// Get version from binary
+	s.foldersMutex.Lock()
+	folders := []*workspaceFolder{}
+	for _, folder := range s.folders {
+		folderOptions, err := s.folderOptions(options, folder.path)
+		if err != nil {
+			log.Printf("Invalid configuration: %v", err)
+			folderOptions = folder.options
+		}
+		folders = append(folders, &workspaceFolder{path: folder.path, options: folderOptions, configurationError: err})
 	}
+	s.folders = folders
+	s.foldersMutex.Unlock()
 
-	if len(options.C3.CompileArgs) > 0 {
-		s.options.C3.CompileArgs = options.C3.CompileArgs
+	if len(folders) > 0 {
+		options = folders[0].options
+	} else {
+		options = s.resolveOptions(options, s.editorSettings != nil)
 	}
 
-	c3Version := c3c.GetC3Version(s.options.C3.Path)
-	if c3Version.IsSome() {
-		s.options.C3.Version = c3Version
+	s.optionsMutex.Lock()
+	if options.Diagnostics.Delay != s.options.Diagnostics.Delay {
+		s.diagnosticDebounced = debounce.New(options.Diagnostics.Delay * time.Millisecond)
 	}
+	s.options = options
+	s.optionsMutex.Unlock()
 
-	requestedLanguageVersion := checkRequestedLanguageVersion(s.options.C3.Version)
-	s.state.SetLanguageVersion(requestedLanguageVersion)
+	s.shared.useStdlibs()
 
 	// Change log filepath?
 	// Should be able to do that form c3lsp.json?
@@ -97,3 +185,191 @@ func (s *Server) loadServerConfigurationForWorkspace(path string) {
 	// Enable/disable sendCrashReports
 	// Should be able to do that form c3lsp.json?
 }
+
+// serverOptions returns the options of the server. They are replaced as a whole when the
+// configuration changes, the value returned is never modified.
+func (s *Server) serverOptions() ServerOpts {
+	s.optionsMutex.RLock()
+	defer s.optionsMutex.RUnlock()
+
+	return s.options
+}
+
+// folderOptions applies to options the c3lsp.json of the folder at path.
+func (s *Server) folderOptions(options ServerOpts, path string) (ServerOpts, error) {
+	config, err := readServerConfiguration(path)
+	if err != nil {
+		return options, err
+	}
+
+	configured := s.editorSettings != nil
+	if config != nil {
+		options.C3.CompileArgs = slices.Clone(options.C3.CompileArgs)
+		options.C3.Targets = slices.Clone(options.C3.Targets)
+		options.Diagnostics.Checks = maps.Clone(options.Diagnostics.Checks)
+		options.applyConfiguration(*config)
+		configured = true
+	}
+
+	return s.resolveOptions(options, configured), nil
+}
+
+// resolveOptions completes the options with what is found from the configured c3c.
+func (s *Server) resolveOptions(options ServerOpts, configured bool) ServerOpts {
+	if configured {
+		c3Version := c3c.GetC3Version(options.C3.Path)
+		if c3Version.IsSome() {
+			options.C3.Version = c3Version
+		}
+	}
+	if options.C3.StdlibPath.IsNone() {
+		options.C3.StdlibPath = c3c.FindStdlibPath(options.C3.Path)
+	}
+	// Diagnostics stay disabled for clients or compilers not supporting them.
+	s.optionsMutex.RLock()
+	options.Diagnostics.Enabled = options.Diagnostics.Enabled && s.diagnosticsSupported &&
+		!s.unsupportedCompilers[options.C3.Path.GetOrElse("")]
+	s.optionsMutex.RUnlock()
+
+	return options
+}
+
+// applyConfiguration sets the options present in config.
+func (opts *ServerOpts) applyConfiguration(config ServerOptsJson) {
+	if config.C3.StdlibPath != nil && *config.C3.StdlibPath != "" {
+		opts.C3.StdlibPath = option.Some(*config.C3.StdlibPath)
+		log.Printf("Stdlib:%s", *config.C3.StdlibPath)
+	}
+
+	if config.C3.Version != nil {
+		opts.C3.Version = option.Some(*config.C3.Version)
+	}
+
+	if config.C3.Path != nil {
+		opts.C3.Path = option.Some(*config.C3.Path)
+		// Get version from binary
+	}
+
+	if len(config.C3.CompileArgs) > 0 {
+		opts.C3.CompileArgs = config.C3.CompileArgs
+	}
+
+	if len(config.C3.Targets) > 0 {
+		opts.C3.Targets = config.C3.Targets
+	}
+
+	if config.Diagnostics.Enabled != nil {
+		opts.Diagnostics.Enabled = *config.Diagnostics.Enabled
+	}
+
+	if config.Diagnostics.Delay != nil {
+		opts.Diagnostics.Delay = *config.Diagnostics.Delay
+	}
+
+	if config.Diagnostics.Timeout != nil {
+		opts.Diagnostics.Timeout = *config.Diagnostics.Timeout
+	}
+
+	opts.applyChecksConfiguration(config)
+	opts.applyFormattingConfiguration(config)
+}
+
+func (opts *ServerOpts) applyChecksConfiguration(config ServerOptsJson) {
+	for code, severity := range config.Diagnostics.Checks {
+		if !diagnostics.IsKnownCheck(code) {
+			log.Printf("Unknown diagnostics check %q", code)
+			continue
+		}
+		if !diagnostics.IsValidSeverity(severity) {
+			log.Printf("Unknown severity %q for diagnostics check %q", severity, code)
+			continue
+		}
+
+		opts.Diagnostics.Checks[code] = diagnostics.Severity(severity)
+	}
+}
+
+func (opts *ServerOpts) applyFormattingConfiguration(config ServerOptsJson) {
+	if config.Formatting.IndentStyle != nil {
+		switch *config.Formatting.IndentStyle {
+		case "tab":
+			opts.Formatting.UseTabs = option.Some(true)
+		case "space":
+			opts.Formatting.UseTabs = option.Some(false)
+		default:
+			log.Printf("Unknown formatting indent-style %q", *config.Formatting.IndentStyle)
+		}
+	}
+
+	if config.Formatting.IndentSize != nil && *config.Formatting.IndentSize > 0 {
+		opts.Formatting.IndentSize = option.Some(*config.Formatting.IndentSize)
+	}
+
+	if config.Formatting.BraceStyle != nil {
+		if formatter.IsValidBraceStyle(*config.Formatting.BraceStyle) {
+			opts.Formatting.BraceStyle = formatter.BraceStyle(*config.Formatting.BraceStyle)
+		} else {
+			log.Printf("Unknown formatting brace-style %q", *config.Formatting.BraceStyle)
+		}
+	}
+
+	if config.Formatting.MaxLineWidth != nil {
+		opts.Formatting.MaxLineWidth = *config.Formatting.MaxLineWidth
+	}
+
+	if config.Formatting.AlignStructMembers != nil {
+		opts.Formatting.AlignStructMembers = *config.Formatting.AlignStructMembers
+	}
+
+	if config.Formatting.AlignEnumValues != nil {
+		opts.Formatting.AlignEnumValues = *config.Formatting.AlignEnumValues
+	}
+}
+
+// configurationChanged applies the configuration again, reporting whether the c3lsp.json of
+// each folder is valid, and checks the workspace with it.
+func (s *Server) configurationChanged(notify glsp.NotifyFunc) {
+	s.reloadConfiguration()
+	// The checks to run might have changed.
+	s.state.InvalidateAnalysis()
+	for _, folder := range s.workspaceFolders() {
+		s.publishConfigurationDiagnostics(folder.path, folder.configurationError, notify)
+	}
+	s.shared.indexStdlib()
+	s.RunDiagnostics(s.state, notify, true)
+}
+
+// publishConfigurationDiagnostics reports on the c3lsp.json of the folder at path why it cannot
+// be used, or clears the previous report when err is nil.
+func (s *Server) publishConfigurationDiagnostics(path string, err error, notify glsp.NotifyFunc) {
+	diagnostics := []protocol.Diagnostic{}
+	var configErr configurationError
+	if errors.As(err, &configErr) {
+		position := protocol.Position{}
+		if data, readErr := os.ReadFile(configErr.path); readErr == nil {
+			position = offsetPosition(data, configErr.offset)
+		}
+
+		diagnostics = append(diagnostics, protocol.Diagnostic{
+			Range:    protocol.Range{Start: position, End: protocol.Position{Line: position.Line, Character: position.Character + 1}},
+			Severity: cast.ToPtr(protocol.DiagnosticSeverityError),
+			Source:   cast.ToPtr("c3-lsp"),
+			Message:  fmt.Sprintf("Invalid configuration, it is ignored: %v", configErr.err),
+		})
+	}
+
+	notify(protocol.ServerTextDocumentPublishDiagnostics, protocol.PublishDiagnosticsParams{
+		URI:         fs.ConvertPathToURI(filepath.Join(path, configurationFile), option.None[string]()),
+		Diagnostics: diagnostics,
+	})
+}
+
+// offsetPosition returns the position of a byte offset of data.
+func offsetPosition(data []byte, offset int64) protocol.Position {
+	offset = min(max(offset, 0), int64(len(data)))
+	before := data[:offset]
+	line := bytes.Count(before, []byte("\n"))
+	character := len(before) - (bytes.LastIndexByte(before, '\n') + 1)
+
+	return protocol.Position{Line: protocol.UInteger(line), Character: protocol.UInteger(character)}
+}
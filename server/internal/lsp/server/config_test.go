@@ -0,0 +1,147 @@
+package server
+
+import (
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pherrymason/c3-lsp/pkg/cast"
+	"github.com/pherrymason/c3-lsp/pkg/option"
+	"github.com/stretchr/testify/assert"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// newConfiguredTestServer creates a server with options given in the command line, using a c3c
+// that does not exist so the options do not depend on the one installed.
+func newConfiguredTestServer(t *testing.T, folders ...string) *Server {
+	s := newTestServer(t, folders...)
+	s.defaultOptions = ServerOpts{}
+	s.defaultOptions.C3.Path = option.Some("/nonexistent/c3c")
+	s.defaultOptions.C3.Targets = []string{"command-line"}
+	s.defaultOptions.Diagnostics.Enabled = true
+	s.defaultOptions.Diagnostics.Delay = 10
+	s.defaultOptions.Formatting.MaxLineWidth = 80
+
+	return s
+}
+
+func TestReloadConfiguration_applies_c3lsp_json_over_editor_settings_over_command_line(t *testing.T) {
+	dir := t.TempDir()
+	folder := writeWorkspaceFiles(t, dir, map[string]string{
+		"c3lsp.json": `{ "Formatting": { "max-line-width": 120 } }`,
+	})
+	s := newConfiguredTestServer(t, folder)
+	s.editorSettings = parseEditorSettings([]byte(`{ "Diagnostics": { "delay": 20 }, "Formatting": { "max-line-width": 100 } }`))
+
+	s.reloadConfiguration()
+
+	options := s.workspaceFolders()[0].options
+	assert.Equal(t, []string{"command-line"}, options.C3.Targets)
+	assert.Equal(t, 20, int(options.Diagnostics.Delay))
+	assert.Equal(t, 120, options.Formatting.MaxLineWidth)
+	assert.Equal(t, options, s.serverOptions(), "the main folder configures the server")
+
+	// Settings removed go back to their previous source.
+	writeWorkspaceFiles(t, dir, map[string]string{"c3lsp.json": `{}`})
+	s.editorSettings = nil
+	s.reloadConfiguration()
+
+	options = s.workspaceFolders()[0].options
+	assert.Equal(t, 10, int(options.Diagnostics.Delay))
+	assert.Equal(t, 80, options.Formatting.MaxLineWidth)
+}
+
+func TestReloadConfiguration_keeps_options_of_folders_with_invalid_c3lsp_json(t *testing.T) {
+	dir := t.TempDir()
+	folder := writeWorkspaceFiles(t, dir, map[string]string{
+		"c3lsp.json": `{ "Formatting": { "max-line-width": 120 } }`,
+	})
+	s := newConfiguredTestServer(t, folder)
+	s.reloadConfiguration()
+
+	writeWorkspaceFiles(t, dir, map[string]string{"c3lsp.json": "{\n  \"Formatting\": { \"max-line-width\": \"wide\" }\n}"})
+	s.reloadConfiguration()
+
+	options := s.workspaceFolders()[0].options
+	assert.Equal(t, 120, options.Formatting.MaxLineWidth)
+
+	// The error is located at the end of the value.
+	var configErr configurationError
+	assert.True(t, errors.As(s.workspaceFolders()[0].configurationError, &configErr))
+	assert.Equal(t, protocol.Position{Line: 1, Character: 41}, offsetPosition([]byte("{\n  \"Formatting\": { \"max-line-width\": \"wide\" }\n}"), configErr.offset))
+}
+
+func TestOffsetPosition(t *testing.T) {
+	data := []byte("{\n  \"C3\": {\n    \"version\": ,\n  }\n}")
+
+	assert.Equal(t, protocol.Position{Line: 0, Character: 0}, offsetPosition(data, 0))
+	assert.Equal(t, protocol.Position{Line: 2, Character: 15}, offsetPosition(data, 27))
+	assert.Equal(t, protocol.Position{Line: 0, Character: 0}, offsetPosition(data, -1), "offsets before the data")
+	assert.Equal(t, protocol.Position{Line: 4, Character: 1}, offsetPosition(data, 100), "offsets past the data")
+}
+
+func TestReadServerConfiguration_locates_syntax_errors(t *testing.T) {
+	data := "{\n  \"C3\": {\n    \"version\": ,\n  }\n}"
+	folder := writeWorkspaceFiles(t, t.TempDir(), map[string]string{"c3lsp.json": data})
+
+	_, err := readServerConfiguration(folder)
+
+	var configErr configurationError
+	assert.True(t, errors.As(err, &configErr))
+	assert.Equal(t, protocol.Position{Line: 2, Character: 15}, offsetPosition([]byte(data), configErr.offset))
+}
+
+func TestReloadConfiguration_can_run_while_diagnostics_read_options(t *testing.T) {
+	s := newConfiguredTestServer(t, t.TempDir())
+	s.editorSettings = &ServerOptsJson{}
+	s.editorSettings.Diagnostics.Delay = cast.ToPtr[time.Duration](5)
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			s.reloadConfiguration()
+		}()
+		go func() {
+			defer wg.Done()
+			s.fileURI("/file.c3")
+			s.disableDiagnostics("")
+		}()
+	}
+	wg.Wait()
+
+	assert.False(t, s.serverOptions().Diagnostics.Enabled)
+}
+
+func TestReloadConfiguration_gives_each_folder_the_stdlib_of_its_language_version(t *testing.T) {
+	old := writeWorkspaceFiles(t, t.TempDir(), map[string]string{"c3lsp.json": `{ "C3": { "version": "0.5.5" } }`})
+	recent := writeWorkspaceFiles(t, t.TempDir(), map[string]string{"c3lsp.json": `{ "C3": { "version": "0.6.2" } }`})
+	s := newConfiguredTestServer(t, old, recent)
+
+	s.reloadConfiguration()
+
+	// ElasticArray was added to the stdlib after 0.5.5.
+	query := "std::collections::elastic_array.ElasticArray"
+	assert.Empty(t, s.state.ForDocument(filepath.Join(old, "main.c3")).SearchByFQN(query))
+	assert.Equal(t, 1, len(s.state.ForDocument(filepath.Join(recent, "main.c3")).SearchByFQN(query)))
+}
+
+func TestDisableDiagnostics_only_disables_folders_using_the_compiler(t *testing.T) {
+	old := writeWorkspaceFiles(t, t.TempDir(), map[string]string{"c3lsp.json": `{ "C3": { "path": "/old/c3c" } }`})
+	recent := writeWorkspaceFiles(t, t.TempDir(), map[string]string{})
+	s := newConfiguredTestServer(t, old, recent)
+	s.reloadConfiguration()
+
+	s.disableDiagnostics("/old/c3c")
+
+	assert.False(t, s.workspaceFolders()[0].options.Diagnostics.Enabled)
+	assert.True(t, s.workspaceFolders()[1].options.Diagnostics.Enabled)
+
+	// It stays disabled when the configuration is reloaded.
+	s.reloadConfiguration()
+	assert.False(t, s.workspaceFolders()[0].options.Diagnostics.Enabled)
+	assert.True(t, s.workspaceFolders()[1].options.Diagnostics.Enabled)
+}
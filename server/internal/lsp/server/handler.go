@@ -0,0 +1,92 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+
+	_prot "github.com/pherrymason/c3-lsp/internal/lsp/protocol"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+type TextDocumentInlayHintFunc func(context *glsp.Context, params *_prot.InlayHintParams) ([]_prot.InlayHint, error)
+type TextDocumentDiagnosticFunc func(context *glsp.Context, params *_prot.DocumentDiagnosticParams) (any, error)
+type WorkspaceDiagnosticFunc func(context *glsp.Context, params *_prot.WorkspaceDiagnosticParams) (_prot.WorkspaceDiagnosticReport, error)
+type LibraryFileContentFunc func(context *glsp.Context, params *_prot.LibraryFileContentParams) (string, error)
+
+// Handler adds to the glsp protocol 3.16 handler the requests of newer
+// protocol versions that glsp does not support yet, and those specific to c3-lsp.
+type Handler struct {
+	protocol.Handler
+
+	TextDocumentInlayHint  TextDocumentInlayHintFunc
+	TextDocumentDiagnostic TextDocumentDiagnosticFunc
+	WorkspaceDiagnostic    WorkspaceDiagnosticFunc
+	LibraryFileContent     LibraryFileContentFunc
+}
+
+func (h *Handler) Handle(context *glsp.Context) (r any, validMethod bool, validParams bool, err error) {
+	switch context.Method {
+	case _prot.MethodTextDocumentInlayHint:
+		if !h.IsInitialized() {
+			return nil, true, true, errors.New("server not initialized")
+		}
+		if h.TextDocumentInlayHint != nil {
+			validMethod = true
+			var params _prot.InlayHintParams
+			if err = json.Unmarshal(context.Params, &params); err == nil {
+				validParams = true
+				r, err = h.TextDocumentInlayHint(context, &params)
+			}
+		}
+
+		return
+
+	case _prot.MethodTextDocumentDiagnostic:
+		if !h.IsInitialized() {
+			return nil, true, true, errors.New("server not initialized")
+		}
+		if h.TextDocumentDiagnostic != nil {
+			validMethod = true
+			var params _prot.DocumentDiagnosticParams
+			if err = json.Unmarshal(context.Params, &params); err == nil {
+				validParams = true
+				r, err = h.TextDocumentDiagnostic(context, &params)
+			}
+		}
+
+		return
+
+	case _prot.MethodWorkspaceDiagnostic:
+		if !h.IsInitialized() {
+			return nil, true, true, errors.New("server not initialized")
+		}
+		if h.WorkspaceDiagnostic != nil {
+			validMethod = true
+			var params _prot.WorkspaceDiagnosticParams
+			if err = json.Unmarshal(context.Params, &params); err == nil {
+				validParams = true
+				r, err = h.WorkspaceDiagnostic(context, &params)
+			}
+		}
+
+		return
+
+	case _prot.MethodLibraryFileContent:
+		if !h.IsInitialized() {
+			return nil, true, true, errors.New("server not initialized")
+		}
+		if h.LibraryFileContent != nil {
+			validMethod = true
+			var params _prot.LibraryFileContentParams
+			if err = json.Unmarshal(context.Params, &params); err == nil {
+				validParams = true
+				r, err = h.LibraryFileContent(context, &params)
+			}
+		}
+
+		return
+	}
+
+	return h.Handler.Handle(context)
+}
@@ -0,0 +1,131 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/pherrymason/c3-lsp/internal/lsp/diagnostics"
+	"github.com/pherrymason/c3-lsp/pkg/document"
+	"github.com/pherrymason/c3-lsp/pkg/fs"
+	"github.com/pherrymason/c3-lsp/pkg/option"
+	"github.com/pherrymason/c3-lsp/pkg/symbols"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// Headless runs on a workspace, without an editor, the same analysis the server runs for
+// the editor. It is used by the commands of the command line, in scripts and CI.
+type Headless struct {
+	server *Server
+	root   string
+}
+
+// NewHeadless indexes the workspace at root, configured by its c3lsp.json, with the libraries
+// it depends on and the stdlib sources.
+func NewHeadless(opts ServerOpts, appName string, version string, root string) (*Headless, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", root)
+	}
+
+	root = fs.GetCanonicalPath(root)
+	s := newServer(opts, appName, version)
+	s.setWorkspaceFolders([]string{root})
+	s.reloadConfiguration()
+	if err := s.workspaceFolders()[0].configurationError; err != nil {
+		return nil, err
+	}
+
+	if stdlibPath := s.serverOptions().C3.StdlibPath; stdlibPath.IsSome() {
+		s.shared.useStdlibSources(stdlibPath.Get())
+	}
+	s.indexWorkspace(nil)
+
+	return &Headless{server: s, root: root}, nil
+}
+
+// Root returns the path of the workspace.
+func (h *Headless) Root() string {
+	return h.root
+}
+
+// Files returns the files of the project, without those of the libraries it depends on.
+func (h *Headless) Files() []string {
+	files := []string{}
+	for docId := range h.server.shared.indexedBy(h.server) {
+		if !h.server.state.IsLibraryDocument(docId) {
+			files = append(files, docId)
+		}
+	}
+	slices.Sort(files)
+
+	return files
+}
+
+// OpenFile returns the document of the file at path, indexing it when it is not part of the project.
+func (h *Headless) OpenFile(path string) (string, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	docId := fs.GetCanonicalPath(path)
+	if h.server.state.GetDocument(docId) != nil {
+		return docId, nil
+	}
+
+	content, err := os.ReadFile(docId)
+	if err != nil {
+		return "", err
+	}
+	doc := document.NewDocumentFromString(docId, string(content))
+	h.server.state.OpenDocument(&doc, h.server.parser)
+
+	return docId, nil
+}
+
+// CheckWithCompiler checks the project with c3c, so its errors and warnings are reported by Check.
+func (h *Headless) CheckWithCompiler() error {
+	found, supported := h.server.checkFolder(h.server.workspaceFolders()[0])
+	if !supported {
+		return errors.New("c3c cannot report diagnostics, a newer version is needed")
+	}
+	if found == nil {
+		return errors.New("c3c timed out")
+	}
+
+	for file, fileDiagnostics := range found {
+		h.server.state.SetDocumentDiagnostics(file, fileDiagnostics)
+	}
+
+	return nil
+}
+
+// Check reports the syntax errors and the problems found by the semantic checks in the files,
+// or in every file of the project when none is given, with those of c3c when it checked them.
+func (h *Headless) Check(docIds []string) diagnostics.Report {
+	if len(docIds) == 0 {
+		docIds = nil
+	}
+
+	return h.server.diagnosticsReport(docIds)
+}
+
+// Symbols returns the symbols declared in the file, as editors show them in its outline.
+func (h *Headless) Symbols(docId string) []protocol.DocumentSymbol {
+	return h.server.search.BuildDocumentSymbols(docId, h.server.state.ForDocument(docId))
+}
+
+// Definition returns the symbol declared where the one at position in the file is defined.
+func (h *Headless) Definition(docId string, position symbols.Position) option.Option[symbols.Indexable] {
+	return h.server.search.FindSymbolDeclarationInWorkspace(docId, position, h.server.state.ForDocument(docId))
+}
@@ -0,0 +1,94 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/websocket"
+	wsjsonrpc2 "github.com/sourcegraph/jsonrpc2/websocket"
+)
+
+// listen accepts clients on address: "tcp:HOST:PORT" for LSP over TCP, or "ws:HOST:PORT" for
+// LSP over web sockets. Each connection gets a server with its own index, unless shared is set:
+// then every connection gets a server using the index of this one, so a second client, like a
+// terminal used to debug, sees what the editor has indexed. Each client still initializes and
+// configures its own server.
+func (s *Server) listen(address string, shared bool) error {
+	transport, hostPort, found := strings.Cut(address, ":")
+	if !found {
+		return fmt.Errorf("invalid listen address %q, expected tcp:HOST:PORT or ws:HOST:PORT", address)
+	}
+
+	connectionServer := s.newConnectionServer
+	if shared {
+		// This server has no client, it does not select the stdlib of the state.
+		s.shared.detach(s)
+		connectionServer = s.newSharingConnectionServer
+	}
+
+	switch transport {
+	case "tcp":
+		return s.listenTCP(hostPort, connectionServer)
+	case "ws":
+		return s.listenWebSocket(hostPort, connectionServer)
+	}
+
+	return fmt.Errorf("unknown transport %q in listen address, expected tcp or ws", transport)
+}
+
+// newConnectionServer creates the server of a new connection, with the options of the command line.
+func (s *Server) newConnectionServer() *Server {
+	return newServer(s.defaultOptions, s.appName, s.version)
+}
+
+// newSharingConnectionServer creates the server of a new connection, with the options of the
+// command line, using the index of this server.
+func (s *Server) newSharingConnectionServer() *Server {
+	return newSharingServer(s.defaultOptions, s.appName, s.version, s.shared)
+}
+
+func (s *Server) listenTCP(address string, connectionServer func() *Server) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+
+	s.server.Log.Noticef("listening for TCP connections on %s", listener.Addr())
+	for {
+		connection, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+
+		go connectionServer().serveStream(connection)
+	}
+}
+
+// listenWebSocket serves clients connecting to a web socket. Browsers are only allowed to connect
+// from pages of the same origin, so other pages cannot drive the server.
+func (s *Server) listenWebSocket(address string, connectionServer func() *Server) error {
+	upgrader := websocket.Upgrader{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(writer http.ResponseWriter, request *http.Request) {
+		// Upgrade answers the request itself when it fails.
+		connection, err := upgrader.Upgrade(writer, request, nil)
+		if err != nil {
+			s.server.Log.Warningf("could not upgrade to web socket: %v", err)
+			return
+		}
+		defer connection.Close()
+
+		connectionServer().serveConnection(wsjsonrpc2.NewObjectStream(connection))
+	})
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+
+	s.server.Log.Noticef("listening for web socket connections on %s", listener.Addr())
+	return http.Serve(listener, mux)
+}
@@ -0,0 +1,83 @@
+package server
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/pherrymason/c3-lsp/internal/lsp/project_state"
+	"github.com/pherrymason/c3-lsp/pkg/fs"
+	"github.com/pherrymason/c3-lsp/pkg/option"
+	"github.com/sourcegraph/jsonrpc2"
+	"github.com/stretchr/testify/assert"
+	"github.com/tliron/commonlog"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// connectTestClient connects a client to the server through an in-memory connection.
+func connectTestClient(t *testing.T, s *Server) *jsonrpc2.Conn {
+	t.Helper()
+
+	serverSide, clientSide := net.Pipe()
+	go s.serveStream(serverSide)
+
+	// Requests of the server, like registering capabilities, are answered with null.
+	handler := jsonrpc2.HandlerWithError(func(ctx context.Context, conn *jsonrpc2.Conn, request *jsonrpc2.Request) (any, error) {
+		return nil, nil
+	})
+	client := jsonrpc2.NewConn(context.Background(), jsonrpc2.NewBufferedStream(clientSide, jsonrpc2.VSCodeObjectCodec{}), handler)
+	t.Cleanup(func() { client.Close() })
+
+	return client
+}
+
+func initializeTestClient(t *testing.T, client *jsonrpc2.Conn) {
+	t.Helper()
+
+	params := map[string]any{"processId": nil, "rootUri": nil, "capabilities": map[string]any{}}
+	assert.Nil(t, client.Call(context.Background(), protocol.MethodInitialize, params, nil))
+	assert.Nil(t, client.Notify(context.Background(), protocol.MethodInitialized, map[string]any{}))
+}
+
+func documentSymbols(t *testing.T, client *jsonrpc2.Conn, uri protocol.DocumentUri) ([]protocol.DocumentSymbol, error) {
+	t.Helper()
+
+	params := protocol.DocumentSymbolParams{TextDocument: protocol.TextDocumentIdentifier{URI: uri}}
+	symbols := []protocol.DocumentSymbol{}
+	err := client.Call(context.Background(), protocol.MethodTextDocumentDocumentSymbol, params, &symbols)
+
+	return symbols, err
+}
+
+func TestSharedConnections_have_their_own_initialization_and_share_the_index(t *testing.T) {
+	logger := commonlog.GetLogger("c3lsp.test")
+	state := project_state.NewProjectState(logger, option.Some("dummy"), false)
+	shared := newSharedState(&state, nil, logger)
+	editor := connectTestClient(t, newSharingServer(ServerOpts{}, "c3lsp-test", "test", shared))
+	terminal := connectTestClient(t, newSharingServer(ServerOpts{}, "c3lsp-test", "test", shared))
+
+	initializeTestClient(t, editor)
+	uri := fs.ConvertPathToURI(filepath.Join(t.TempDir(), "main.c3"), option.None[string]())
+	assert.Nil(t, editor.Notify(context.Background(), protocol.MethodTextDocumentDidOpen, protocol.DidOpenTextDocumentParams{
+		TextDocument: protocol.TextDocumentItem{URI: uri, LanguageID: "c3", Version: 1, Text: "module app;\nfn void main() {}\n"},
+	}))
+	// Answered once the document is open.
+	_, err := documentSymbols(t, editor, uri)
+	assert.Nil(t, err)
+
+	// Initializing another client does not reset the editor's documents.
+	initializeTestClient(t, terminal)
+	symbols, err := documentSymbols(t, terminal, uri)
+	assert.Nil(t, err)
+	assert.NotEmpty(t, symbols, "the terminal sees what the editor opened")
+
+	// Shutting down another client does not stop answering the editor.
+	assert.Nil(t, terminal.Call(context.Background(), protocol.MethodShutdown, nil, nil))
+	assert.Nil(t, terminal.Notify(context.Background(), protocol.MethodExit, nil))
+	<-terminal.DisconnectNotify()
+
+	symbols, err = documentSymbols(t, editor, uri)
+	assert.Nil(t, err)
+	assert.NotEmpty(t, symbols)
+}
@@ -0,0 +1,79 @@
+package server
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/pherrymason/c3-lsp/pkg/cast"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// workDoneProgress reports to the client the progress of a task started by the server.
+// A nil workDoneProgress reports nothing, for clients without support for it.
+type workDoneProgress struct {
+	notify glsp.NotifyFunc
+	token  protocol.ProgressToken
+
+	mutex      sync.Mutex
+	percentage protocol.UInteger
+}
+
+// beginProgress asks the client to show the progress of a task, when it supports it.
+func (s *Server) beginProgress(context *glsp.Context, title string) *workDoneProgress {
+	if !s.workDoneProgress {
+		return nil
+	}
+
+	token := protocol.ProgressToken{Value: fmt.Sprintf("c3lsp/%d", s.progressTokens.Add(1))}
+	context.Call(protocol.ServerWindowWorkDoneProgressCreate, protocol.WorkDoneProgressCreateParams{Token: token}, nil)
+	context.Notify(protocol.MethodProgress, protocol.ProgressParams{
+		Token: token,
+		Value: protocol.WorkDoneProgressBegin{
+			Kind:       "begin",
+			Title:      title,
+			Percentage: cast.ToPtr(protocol.UInteger(0)),
+		},
+	})
+
+	return &workDoneProgress{notify: context.Notify, token: token}
+}
+
+// report tells how many of the total steps are done. Only changes of percentage are sent.
+func (p *workDoneProgress) report(done int, total int) {
+	if p == nil || total == 0 {
+		return
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	percentage := protocol.UInteger(done * 100 / total)
+	if percentage <= p.percentage {
+		return
+	}
+	p.percentage = percentage
+
+	p.notify(protocol.MethodProgress, protocol.ProgressParams{
+		Token: p.token,
+		Value: protocol.WorkDoneProgressReport{
+			Kind:       "report",
+			Message:    cast.ToPtr(fmt.Sprintf("%d/%d files", done, total)),
+			Percentage: &percentage,
+		},
+	})
+}
+
+func (p *workDoneProgress) end(message string) {
+	if p == nil {
+		return
+	}
+
+	p.notify(protocol.MethodProgress, protocol.ProgressParams{
+		Token: p.token,
+		Value: protocol.WorkDoneProgressEnd{
+			Kind:    "end",
+			Message: &message,
+		},
+	})
+}
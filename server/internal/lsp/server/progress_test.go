@@ -0,0 +1,37 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func TestBeginProgress_creates_a_token_when_the_client_supports_it(t *testing.T) {
+	s := newTestServer(t)
+	calls := []string{}
+	notifications := []any{}
+	context := &glsp.Context{
+		Call: func(method string, params any, result any) {
+			calls = append(calls, method)
+		},
+		Notify: func(method string, params any) {
+			notifications = append(notifications, params.(protocol.ProgressParams).Value)
+		},
+	}
+
+	assert.Nil(t, s.beginProgress(context, "Indexing"))
+	assert.Empty(t, calls)
+
+	s.workDoneProgress = true
+	progress := s.beginProgress(context, "Indexing")
+	progress.report(1, 2)
+	progress.end("Indexed")
+
+	assert.Equal(t, []string{protocol.ServerWindowWorkDoneProgressCreate}, calls)
+	assert.Equal(t, 3, len(notifications))
+	assert.Equal(t, "Indexing", notifications[0].(protocol.WorkDoneProgressBegin).Title)
+	assert.Equal(t, protocol.UInteger(50), *notifications[1].(protocol.WorkDoneProgressReport).Percentage)
+	assert.Equal(t, "Indexed", *notifications[2].(protocol.WorkDoneProgressEnd).Message)
+}
@@ -0,0 +1,276 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/sourcegraph/jsonrpc2"
+	"github.com/tliron/commonlog"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+const (
+	codeRequestCancelled = -32800
+	codeContentModified  = -32801
+)
+
+// Requests answered from the content of a document. They are abandoned when the document
+// changes before they start, as their result would no longer match what the client shows.
+var documentQueries = map[string]bool{
+	protocol.MethodTextDocumentCompletion:    true,
+	protocol.MethodTextDocumentHover:         true,
+	protocol.MethodTextDocumentSignatureHelp: true,
+	protocol.MethodTextDocumentDefinition:    true,
+	protocol.MethodTextDocumentDeclaration:   true,
+}
+
+type queuedRequest struct {
+	context context.Context
+	cancel  context.CancelFunc
+	request *jsonrpc2.Request
+	// uri is the document queried by documentQueries.
+	uri string
+	// reason is the error code answered when the request is cancelled.
+	reason int64
+}
+
+// requestQueue runs the messages received from the client one after the other, in the order
+// they arrive, so queries never see a document in the middle of an edit.
+// Messages are read while others run: "$/cancelRequest" is handled as soon as it arrives,
+// and a cancelled request is answered with an error instead of its result.
+type requestQueue struct {
+	handler    glsp.Handler
+	log        commonlog.Logger
+	connection context.Context
+
+	mutex   sync.Mutex
+	queue   []*queuedRequest
+	pending map[jsonrpc2.ID]*queuedRequest
+	wake    chan struct{}
+}
+
+func newRequestQueue(handler glsp.Handler, log commonlog.Logger) *requestQueue {
+	return &requestQueue{
+		handler: handler,
+		log:     log,
+		pending: map[jsonrpc2.ID]*queuedRequest{},
+		wake:    make(chan struct{}, 1),
+	}
+}
+
+// serveStream answers the messages read from stream until the connection is closed.
+func (s *Server) serveStream(stream io.ReadWriteCloser) {
+	s.serveConnection(jsonrpc2.NewBufferedStream(stream, jsonrpc2.VSCodeObjectCodec{}))
+}
+
+// serveConnection answers the messages of a connection, whatever its transport, until it is closed.
+func (s *Server) serveConnection(stream jsonrpc2.ObjectStream) {
+	queue := newRequestQueue(s.server.Handler, s.server.Log)
+	connectionContext, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	queue.connection = connectionContext
+
+	options := []jsonrpc2.ConnOpt{}
+	if s.server.Debug {
+		options = append(options, jsonrpc2.LogMessages(rpcLogger{commonlog.NewScopeLogger(s.server.Log, "rpc")}))
+	}
+
+	connection := jsonrpc2.NewConn(connectionContext, stream, queue, options...)
+	done := make(chan struct{})
+	go func() {
+		queue.run(connection)
+		close(done)
+	}()
+	<-connection.DisconnectNotify()
+
+	// No request of the client runs anymore.
+	<-done
+	s.shared.detach(s)
+}
+
+// Handle queues the request. It is called by the connection for each message read.
+func (q *requestQueue) Handle(ctx context.Context, connection *jsonrpc2.Conn, request *jsonrpc2.Request) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	switch request.Method {
+	case protocol.MethodCancelRequest:
+		var params struct {
+			ID jsonrpc2.ID `json:"id"`
+		}
+		if request.Params != nil && json.Unmarshal(*request.Params, &params) == nil {
+			if cancelled, ok := q.pending[params.ID]; ok {
+				cancelled.cancel()
+			}
+		}
+		return
+
+	case protocol.MethodTextDocumentDidChange:
+		var params struct {
+			TextDocument protocol.VersionedTextDocumentIdentifier `json:"textDocument"`
+		}
+		if request.Params != nil && json.Unmarshal(*request.Params, &params) == nil {
+			q.abandonQueries(params.TextDocument.URI)
+		}
+	}
+
+	requestContext, cancel := context.WithCancel(q.connection)
+	queued := &queuedRequest{
+		context: requestContext,
+		cancel:  cancel,
+		request: request,
+		reason:  codeRequestCancelled,
+	}
+	if documentQueries[request.Method] {
+		var params struct {
+			TextDocument protocol.TextDocumentIdentifier `json:"textDocument"`
+		}
+		if request.Params != nil && json.Unmarshal(*request.Params, &params) == nil {
+			queued.uri = params.TextDocument.URI
+		}
+	}
+	if !request.Notif {
+		q.pending[request.ID] = queued
+	}
+	q.queue = append(q.queue, queued)
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// abandonQueries cancels the queries on uri that did not start yet.
+func (q *requestQueue) abandonQueries(uri string) {
+	for _, queued := range q.queue {
+		if queued.uri != "" && queued.uri == uri {
+			queued.reason = codeContentModified
+			queued.cancel()
+		}
+	}
+}
+
+func (q *requestQueue) run(connection *jsonrpc2.Conn) {
+	for {
+		select {
+		case <-connection.DisconnectNotify():
+			return
+		case <-q.wake:
+		}
+
+		for {
+			q.mutex.Lock()
+			if len(q.queue) == 0 {
+				q.mutex.Unlock()
+				break
+			}
+			queued := q.queue[0]
+			q.queue = q.queue[1:]
+			q.mutex.Unlock()
+
+			q.process(connection, queued)
+		}
+	}
+}
+
+func (q *requestQueue) process(connection *jsonrpc2.Conn, queued *queuedRequest) {
+	request := queued.request
+	defer func() {
+		q.mutex.Lock()
+		delete(q.pending, request.ID)
+		q.mutex.Unlock()
+		queued.cancel()
+	}()
+
+	var result any
+	var err *jsonrpc2.Error
+	if queued.context.Err() == nil {
+		result, err = q.handle(connection, queued)
+	}
+
+	if request.Method == "exit" {
+		if closeErr := connection.Close(); closeErr != nil {
+			q.log.Error(closeErr.Error())
+		}
+		return
+	}
+	if request.Notif {
+		return
+	}
+
+	// A request cancelled while it was running is not answered with its result either.
+	if queued.context.Err() != nil {
+		q.mutex.Lock()
+		reason := queued.reason
+		q.mutex.Unlock()
+
+		err = &jsonrpc2.Error{Code: reason, Message: "request cancelled"}
+		if reason == codeContentModified {
+			err.Message = "document modified"
+		}
+	}
+
+	var replyErr error
+	if err != nil {
+		replyErr = connection.ReplyWithError(q.connection, request.ID, err)
+	} else {
+		replyErr = connection.Reply(q.connection, request.ID, result)
+	}
+	if replyErr != nil {
+		q.log.Error(replyErr.Error())
+	}
+}
+
+// handle runs the request with the glsp handler, translating its errors as glsp does.
+func (q *requestQueue) handle(connection *jsonrpc2.Conn, queued *queuedRequest) (any, *jsonrpc2.Error) {
+	request := queued.request
+	context := glsp.Context{
+		Method: request.Method,
+		Notify: func(method string, params any) {
+			if err := connection.Notify(q.connection, method, params); err != nil {
+				q.log.Error(err.Error())
+			}
+		},
+		Call: func(method string, params any, result any) {
+			if err := connection.Call(q.connection, method, params, result); err != nil {
+				q.log.Error(err.Error())
+			}
+		},
+		Context: queued.context,
+	}
+	if request.Params != nil {
+		context.Params = *request.Params
+	}
+
+	result, validMethod, validParams, err := q.handler.Handle(&context)
+	switch {
+	case !validMethod:
+		return nil, &jsonrpc2.Error{
+			Code:    jsonrpc2.CodeMethodNotFound,
+			Message: fmt.Sprintf("method not supported: %s", request.Method),
+		}
+	case !validParams:
+		rpcErr := &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
+		if err != nil {
+			rpcErr.Message = err.Error()
+		}
+		return nil, rpcErr
+	case err != nil:
+		return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidRequest, Message: err.Error()}
+	}
+
+	return result, nil
+}
+
+type rpcLogger struct {
+	log commonlog.Logger
+}
+
+func (l rpcLogger) Printf(format string, v ...any) {
+	l.log.Debugf(strings.TrimSuffix(format, "\n"), v...)
+}
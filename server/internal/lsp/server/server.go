@@ -3,15 +3,17 @@ package server
 import (
 	"fmt"
 	"log"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/bep/debounce"
+	"github.com/pherrymason/c3-lsp/internal/lsp/index_cache"
 	"github.com/pherrymason/c3-lsp/internal/lsp/project_state"
 	l "github.com/pherrymason/c3-lsp/internal/lsp/project_state"
 	"github.com/pherrymason/c3-lsp/internal/lsp/search"
 	"github.com/pherrymason/c3-lsp/pkg/option"
 	p "github.com/pherrymason/c3-lsp/pkg/parser"
-	"github.com/pkg/errors"
 	"github.com/tliron/commonlog"
 	_ "github.com/tliron/commonlog/simple"
 	"github.com/tliron/glsp"
@@ -22,14 +24,54 @@ import (
 
 type Server struct {
 	server  *glspserv.Server
-	options ServerOpts
+	appName string
 	version string
 
+	// Guards options, diagnosticsSupported, unsupportedCompilers and diagnosticDebounced, which are replaced when the
+	// configuration changes while diagnostics run in the background.
+	optionsMutex sync.RWMutex
+	options      ServerOpts
+	// Options given in the command line, before applying the editor settings and c3lsp.json.
+	defaultOptions ServerOpts
+	// Settings of the "c3lsp" section of the editor, nil when it has none.
+	editorSettings *ServerOptsJson
+	// Folders of the workspace, the first one being the main folder.
+	folders      []*workspaceFolder
+	foldersMutex sync.RWMutex
+	// Clients answering "workspace/configuration" requests.
+	pullConfiguration bool
+	// False when the client cannot be used to report diagnostics.
+	diagnosticsSupported bool
+	// Paths of the c3c found too old to report diagnostics, "" being the one in PATH. Folders
+	// using them are not checked.
+	unsupportedCompilers map[string]bool
+	// State of the project, shared with the servers of other connections in shared mode.
+	shared *sharedState
 	state  *l.ProjectState
 	parser *p.Parser
 	search search.Search
 
 	diagnosticDebounced func(func())
+	// Analysis of each document changed, waiting for the document to stop changing.
+	analysisDebounced map[string]func(func())
+	analysisMutex     sync.Mutex
+	// Symbols of files parsed in previous runs. Nil when the cache cannot be used.
+	indexCache *index_cache.Cache
+	// Clients supporting it are asked to report changes of files outside the editor.
+	registerFileWatchers bool
+	// Clients supporting it are asked to report changes of their settings.
+	registerConfigurationChanges bool
+	// Clients supporting it show the progress of indexing.
+	workDoneProgress bool
+	progressTokens   atomic.Int64
+	// Clients supporting pull diagnostics and their refresh request them instead of receiving them.
+	pullDiagnostics    bool
+	refreshDiagnostics func()
+
+	semanticTokens         map[string]semanticTokensResult
+	semanticTokensResultId int
+	// Documents open by the client, by URI.
+	openDocuments map[string]bool
 }
 
 // ServerOpts holds the options to create a new Server.
@@ -66,26 +108,52 @@ func NewServer(opts ServerOpts, appName string, version string) *Server {
 		logger.Debug(fmt.Sprintf("C3 Language version specified: %s", opts.C3.Version.Get()))
 	}
 
-	handler := protocol.Handler{}
-	glspServer := glspserv.NewServer(&handler, appName, true)
+	return newServer(opts, appName, version)
+}
 
-	requestedLanguageVersion := checkRequestedLanguageVersion(opts.C3.Version)
+// newServer creates a server with its own index. Servers listening for connections
+// create one for each client.
+func newServer(opts ServerOpts, appName string, version string) *Server {
+	logger := commonlog.GetLogger(fmt.Sprintf("%s.parser", appName))
 
+	requestedLanguageVersion := checkRequestedLanguageVersion(opts.C3.Version)
 	state := l.NewProjectState(logger, option.Some(requestedLanguageVersion.Number), opts.Debug)
+
+	return newSharingServer(opts, appName, version, newSharedState(&state, newIndexCache(version), logger))
+}
+
+// newSharingServer creates a server using the project state of shared. It answers its own
+// client, which initializes it and configures its options.
+func newSharingServer(opts ServerOpts, appName string, version string, shared *sharedState) *Server {
+	logger := commonlog.GetLogger(fmt.Sprintf("%s.parser", appName))
+
+	handler := Handler{}
+	glspServer := glspserv.NewServer(&handler, appName, true)
+
 	parser := p.NewParser(logger)
 	search := search.NewSearch(logger, opts.Debug)
 
 	server := &Server{
 		server:  glspServer,
 		options: opts,
+		appName: appName,
 		version: version,
 
-		state:  &state,
+		defaultOptions:       opts,
+		diagnosticsSupported: true,
+
+		shared: shared,
+		state:  shared.state,
 		parser: &parser,
 		search: search,
 
 		diagnosticDebounced: debounce.New(opts.Diagnostics.Delay * time.Millisecond),
+		indexCache:          shared.indexCache,
+
+		semanticTokens: map[string]semanticTokensResult{},
+		openDocuments:  map[string]bool{},
 	}
+	shared.attach(server)
 
 	handler.Initialized = func(context *glsp.Context, params *protocol.InitializedParams) error {
 		/*
@@ -103,7 +171,7 @@ func NewServer(opts ServerOpts, appName string, version string) *Server {
 			Message: fmt.Sprintf("SendCrash: %s", sendCrashStatus),
 		})
 		*/
-		return nil
+		return server.Initialized(context, params)
 	}
 	handler.Shutdown = shutdown
 	handler.SetTrace = setTrace
@@ -128,7 +196,25 @@ func NewServer(opts ServerOpts, appName string, version string) *Server {
 	handler.TextDocumentDefinition = server.TextDocumentDefinition
 	handler.TextDocumentCompletion = server.TextDocumentCompletion
 	handler.TextDocumentSignatureHelp = server.TextDocumentSignatureHelp
+	handler.TextDocumentReferences = server.TextDocumentReferences
+	handler.TextDocumentPrepareRename = server.TextDocumentPrepareRename
+	handler.TextDocumentRename = server.TextDocumentRename
+	handler.TextDocumentDocumentSymbol = server.TextDocumentDocumentSymbol
+	handler.TextDocumentSemanticTokensFull = server.TextDocumentSemanticTokensFull
+	handler.TextDocumentSemanticTokensFullDelta = server.TextDocumentSemanticTokensFullDelta
+	handler.TextDocumentSemanticTokensRange = server.TextDocumentSemanticTokensRange
+	handler.TextDocumentInlayHint = server.TextDocumentInlayHint
+	handler.TextDocumentDiagnostic = server.TextDocumentDiagnostic
+	handler.WorkspaceDiagnostic = server.WorkspaceDiagnostic
+	handler.LibraryFileContent = server.LibraryFileContent
+	handler.TextDocumentFormatting = server.TextDocumentFormatting
+	handler.TextDocumentRangeFormatting = server.TextDocumentRangeFormatting
+	handler.TextDocumentOnTypeFormatting = server.TextDocumentOnTypeFormatting
+	handler.WorkspaceSymbol = server.WorkspaceSymbol
+	handler.WorkspaceExecuteCommand = server.WorkspaceExecuteCommand
 	handler.WorkspaceDidChangeWatchedFiles = server.WorkspaceDidChangeWatchedFiles
+	handler.WorkspaceDidChangeConfiguration = server.WorkspaceDidChangeConfiguration
+	handler.WorkspaceDidChangeWorkspaceFolders = server.WorkspaceDidChangeWorkspaceFolders
 	handler.WorkspaceDidDeleteFiles = server.WorkspaceDidDeleteFiles
 	handler.WorkspaceDidRenameFiles = server.WorkspaceDidRenameFiles
 
@@ -136,17 +222,20 @@ func NewServer(opts ServerOpts, appName string, version string) *Server {
 		return params, nil
 	}
 
-	handler.WorkspaceDidChangeWorkspaceFolders = func(context *glsp.Context, params *protocol.DidChangeWorkspaceFoldersParams) error {
-
-		return nil
-	}
-
 	return server
 }
 
-// Run starts the Language Server in stdio mode.
+// Run starts the Language Server in stdio mode, or listening for connections on the address given.
 func (s *Server) Run() error {
-	return errors.Wrap(s.server.RunStdio(), "lsp")
+	options := s.serverOptions()
+	if options.Listen.IsSome() {
+		return s.listen(options.Listen.Get(), options.SharedState)
+	}
+
+	s.server.Log.Notice("reading from stdin, writing to stdout")
+	s.serveStream(glspserv.Stdio{})
+
+	return nil
 }
 
 func shutdown(context *glsp.Context) error {
@@ -159,6 +248,24 @@ func setTrace(context *glsp.Context, params *protocol.SetTraceParams) error {
 	return nil
 }
 
+// newIndexCache opens the index cache under the user cache directory. Files are parsed
+// every time when it is not available.
+func newIndexCache(version string) *index_cache.Cache {
+	dir, err := index_cache.DefaultDir()
+	if err != nil {
+		log.Printf("Index cache disabled: %v", err)
+		return nil
+	}
+
+	cache, err := index_cache.NewCache(dir, version)
+	if err != nil {
+		log.Printf("Index cache disabled: %v", err)
+		return nil
+	}
+
+	return cache
+}
+
 func checkRequestedLanguageVersion(version option.Option[string]) project_state.Version {
 	supportedVersions := project_state.SupportedVersions()
 
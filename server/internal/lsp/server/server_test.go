@@ -0,0 +1,57 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bep/debounce"
+	"github.com/pherrymason/c3-lsp/internal/lsp/project_state"
+	"github.com/pherrymason/c3-lsp/internal/lsp/search"
+	"github.com/pherrymason/c3-lsp/pkg/fs"
+	"github.com/pherrymason/c3-lsp/pkg/option"
+	p "github.com/pherrymason/c3-lsp/pkg/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/tliron/commonlog"
+	glspserv "github.com/tliron/glsp/server"
+)
+
+// newTestServer creates a server without index cache, with the given workspace folders.
+func newTestServer(t *testing.T, folders ...string) *Server {
+	t.Helper()
+
+	logger := commonlog.GetLogger("c3lsp.test")
+	state := project_state.NewProjectState(logger, option.Some("dummy"), false)
+	parser := p.NewParser(logger)
+
+	s := &Server{
+		server:               glspserv.NewServer(&Handler{}, "c3lsp-test", false),
+		diagnosticsSupported: true,
+
+		shared: newSharedState(&state, nil, logger),
+		state:  &state,
+		parser: &parser,
+		search: search.NewSearch(logger, false),
+
+		diagnosticDebounced: debounce.New(0),
+		semanticTokens:      map[string]semanticTokensResult{},
+		openDocuments:       map[string]bool{},
+	}
+	s.shared.attach(s)
+	s.setWorkspaceFolders(folders)
+
+	return s
+}
+
+// writeWorkspaceFiles writes files, by path relative to dir, returning dir as a workspace folder path.
+func writeWorkspaceFiles(t *testing.T, dir string, files map[string]string) string {
+	t.Helper()
+
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		assert.Nil(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.Nil(t, os.WriteFile(path, []byte(content), 0644))
+	}
+
+	return fs.GetCanonicalPath(dir)
+}
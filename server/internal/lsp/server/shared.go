@@ -0,0 +1,212 @@
+package server
+
+import (
+	"log"
+	"maps"
+	"slices"
+	"sync"
+
+	"github.com/pherrymason/c3-lsp/internal/lsp/index_cache"
+	"github.com/pherrymason/c3-lsp/internal/lsp/project_state"
+	p "github.com/pherrymason/c3-lsp/pkg/parser"
+	"github.com/tliron/commonlog"
+)
+
+// sharedState is the project state, with what the servers using it must agree on. Each connection
+// has its own server, answering its client with its own options, and its own project state unless
+// listening in shared mode.
+type sharedState struct {
+	state      *project_state.ProjectState
+	indexCache *index_cache.Cache
+	logger     commonlog.Logger
+
+	mutex sync.Mutex
+	// Servers using the state. The first one selects the stdlib of files outside every folder.
+	servers []*Server
+	// Files of the workspace indexed from disk by each server.
+	indexedFiles map[*Server]map[string]bool
+
+	// Held while indexing the workspace, which starts again when project.json changes.
+	indexing sync.Mutex
+
+	// Guards the stdlib used by each folder, and the stdlib sources being parsed meanwhile.
+	stdlibMutex    sync.Mutex
+	parsingStdlibs map[string]bool
+}
+
+func newSharedState(state *project_state.ProjectState, indexCache *index_cache.Cache, logger commonlog.Logger) *sharedState {
+	return &sharedState{
+		state:          state,
+		indexCache:     indexCache,
+		logger:         logger,
+		indexedFiles:   map[*Server]map[string]bool{},
+		parsingStdlibs: map[string]bool{},
+	}
+}
+
+// attach starts sharing the state with s.
+func (st *sharedState) attach(s *Server) {
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+
+	st.servers = append(st.servers, s)
+	st.indexedFiles[s] = map[string]bool{}
+}
+
+// detach stops sharing the state with s, whose client disconnected. The documents its client
+// left open are closed, and the files and stdlib only s used are removed.
+func (st *sharedState) detach(s *Server) {
+	for uri := range s.openDocuments {
+		st.state.CloseDocument(uri, s.parser)
+	}
+
+	st.indexing.Lock()
+	st.setIndexedFiles(s, nil)
+	st.mutex.Lock()
+	st.servers = slices.DeleteFunc(st.servers, func(server *Server) bool { return server == s })
+	st.mutex.Unlock()
+	st.indexing.Unlock()
+
+	st.useStdlibs()
+}
+
+// setIndexedFiles replaces the files indexed by s. Files no server indexes anymore are removed,
+// unless they are open: the editor keeps editing them, they are removed once closed, when
+// indexing again. Nil files forget those of s. indexing must be held.
+func (st *sharedState) setIndexedFiles(s *Server, files map[string]bool) {
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+
+	previous, attached := st.indexedFiles[s]
+	if !attached {
+		// Its client disconnected while indexing.
+		files = nil
+	}
+	delete(st.indexedFiles, s)
+
+	for docId := range previous {
+		switch {
+		case files[docId], st.isIndexed(docId):
+		case st.state.IsOpenDocument(docId):
+			if files != nil {
+				files[docId] = true
+			}
+		default:
+			st.state.DeleteDocument(docId)
+		}
+	}
+
+	if files != nil {
+		st.indexedFiles[s] = files
+	}
+}
+
+// isIndexed tells if any server indexed the file. mutex must be held.
+func (st *sharedState) isIndexed(docId string) bool {
+	for _, files := range st.indexedFiles {
+		if files[docId] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// indexedBy returns the files indexed by s.
+func (st *sharedState) indexedBy(s *Server) map[string]bool {
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+
+	return maps.Clone(st.indexedFiles[s])
+}
+
+// indexed tells if the file was indexed by any server.
+func (st *sharedState) indexed(docId string) bool {
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+
+	return st.isIndexed(docId)
+}
+
+// stdlibOptions returns the options selecting the stdlib of each folder, by folder path, of every
+// server. Files outside every folder use those of the first server, under "".
+func (st *sharedState) stdlibOptions() map[string]ServerOpts {
+	st.mutex.Lock()
+	servers := slices.Clone(st.servers)
+	st.mutex.Unlock()
+
+	options := map[string]ServerOpts{}
+	// The options of the first servers are kept for folders open by several ones.
+	for i := len(servers) - 1; i >= 0; i-- {
+		maps.Copy(options, servers[i].stdlibOptions())
+	}
+
+	return options
+}
+
+// useStdlibs makes the documents of each folder see the stdlib selected by its options: the
+// stdlib sources when indexed, or the symbols bundled for the language version until then.
+func (st *sharedState) useStdlibs() {
+	st.stdlibMutex.Lock()
+	defer st.stdlibMutex.Unlock()
+
+	folders := []string{}
+	for folder, options := range st.stdlibOptions() {
+		stdlibPath := options.C3.StdlibPath
+		if stdlibPath.IsSome() && st.state.HasStdlibSources(stdlibPath.Get()) {
+			st.state.UseStdlibSources(folder, stdlibPath.Get(), nil)
+		} else {
+			st.state.UseBundledStdlib(folder, checkRequestedLanguageVersion(options.C3.Version))
+		}
+		folders = append(folders, folder)
+	}
+	st.state.RetainFolders(folders)
+}
+
+// indexStdlib parses in the background the stdlib sources of each folder, configured or installed
+// with c3c, to replace the stdlib symbols bundled for their language version. These are kept when
+// there are no sources. Sources used by several folders are parsed once.
+func (st *sharedState) indexStdlib() {
+	st.stdlibMutex.Lock()
+	defer st.stdlibMutex.Unlock()
+
+	for _, options := range st.stdlibOptions() {
+		stdlibPath := options.C3.StdlibPath
+		if stdlibPath.IsNone() {
+			log.Print("No stdlib sources found, using bundled stdlib symbols")
+			continue
+		}
+
+		path := stdlibPath.Get()
+		if st.parsingStdlibs[path] || st.state.HasStdlibSources(path) {
+			continue
+		}
+		st.parsingStdlibs[path] = true
+		go st.useStdlibSources(path)
+	}
+}
+
+// useStdlibSources parses the stdlib sources at path to replace the bundled stdlib symbols of
+// the folders using them.
+func (st *sharedState) useStdlibSources(path string) {
+	// The parsers of the servers are used by requests, this one runs alongside them.
+	parser := p.NewParser(st.logger)
+	documents, err := project_state.ParseStdlib(path, &parser, st.indexCache)
+
+	st.stdlibMutex.Lock()
+	defer st.stdlibMutex.Unlock()
+
+	delete(st.parsingStdlibs, path)
+	if err != nil || len(documents) == 0 {
+		log.Printf("Could not parse stdlib sources at %s, using bundled stdlib symbols: %v", path, err)
+		return
+	}
+
+	// The configuration might have changed meanwhile.
+	for folder, options := range st.stdlibOptions() {
+		if options.C3.StdlibPath.IsSome() && options.C3.StdlibPath.Get() == path {
+			st.state.UseStdlibSources(folder, path, documents)
+		}
+	}
+	log.Printf("Stdlib indexed from %s", path)
+}
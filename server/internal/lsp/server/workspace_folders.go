@@ -0,0 +1,113 @@
+package server
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/pherrymason/c3-lsp/pkg/fs"
+	"github.com/pherrymason/c3-lsp/pkg/utils"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// workspaceFolder is a root folder of the workspace. Each folder has its own c3lsp.json and
+// is checked by c3c on its own. The symbols of every folder share the same index, so a folder
+// depending on another one can navigate to its code, but each folder sees its own stdlib.
+type workspaceFolder struct {
+	path    string
+	options ServerOpts
+	// Error of its c3lsp.json, reported as a diagnostic on the file.
+	configurationError error
+}
+
+// initialWorkspaceFolders returns the paths of the folders opened by the client. Clients
+// without support for workspace folders only send the root.
+func initialWorkspaceFolders(params *protocol.InitializeParams) []string {
+	paths := []string{}
+	for _, folder := range params.WorkspaceFolders {
+		paths = append(paths, workspaceFolderPath(folder.URI))
+	}
+	if len(paths) == 0 && params.RootURI != nil {
+		paths = append(paths, workspaceFolderPath(*params.RootURI))
+	}
+
+	return paths
+}
+
+func workspaceFolderPath(uri protocol.DocumentUri) string {
+	return fs.GetCanonicalPath(utils.NormalizePath(uri))
+}
+
+// workspaceFolders returns the folders of the workspace, the first one being the main folder.
+func (s *Server) workspaceFolders() []*workspaceFolder {
+	s.foldersMutex.RLock()
+	defer s.foldersMutex.RUnlock()
+
+	return slices.Clone(s.folders)
+}
+
+// setWorkspaceFolders changes the folders of the workspace. Folders already open keep their
+// options, new ones get the options of the server until the configuration is reloaded.
+func (s *Server) setWorkspaceFolders(paths []string) {
+	s.foldersMutex.Lock()
+	defer s.foldersMutex.Unlock()
+
+	folders := []*workspaceFolder{}
+	for _, path := range paths {
+		index := slices.IndexFunc(s.folders, func(folder *workspaceFolder) bool { return folder.path == path })
+		if index >= 0 {
+			folders = append(folders, s.folders[index])
+		} else {
+			folders = append(folders, &workspaceFolder{path: path, options: s.serverOptions()})
+		}
+	}
+	s.folders = folders
+
+	if len(folders) > 0 {
+		s.state.SetProjectRootURI(folders[0].path)
+	}
+}
+
+// folderOf returns the folder containing the file, the innermost one when folders are nested.
+// Files outside the workspace, like those of the stdlib, have no folder.
+func (s *Server) folderOf(docId string) *workspaceFolder {
+	var found *workspaceFolder
+	for _, folder := range s.workspaceFolders() {
+		if isInsideFolder(docId, folder.path) && (found == nil || len(folder.path) > len(found.path)) {
+			found = folder
+		}
+	}
+
+	return found
+}
+
+func isInsideFolder(path string, folder string) bool {
+	return path == folder || strings.HasPrefix(path, strings.TrimSuffix(folder, string(filepath.Separator))+string(filepath.Separator))
+}
+
+// optionsFor returns the options of the folder of the document, or those of the server
+// when it is outside the workspace.
+func (s *Server) optionsFor(docId string) ServerOpts {
+	if folder := s.folderOf(docId); folder != nil {
+		return folder.options
+	}
+
+	return s.serverOptions()
+}
+
+// workspaceRootFile returns the folder having uri as its file with the given name.
+func (s *Server) workspaceRootFile(uri protocol.DocumentUri, name string) *workspaceFolder {
+	path, err := fs.UriToPath(uri)
+	if err != nil {
+		return nil
+	}
+
+	path = fs.GetCanonicalPath(path)
+	for _, folder := range s.workspaceFolders() {
+		if path == filepath.Join(folder.path, name) {
+			return folder
+		}
+	}
+
+	return nil
+}
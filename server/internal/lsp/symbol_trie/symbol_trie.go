@@ -94,6 +94,11 @@ func (t *Trie) Search(query string) []symbols.Indexable {
 	}
 }
 
+// All returns every symbol stored in the trie
+func (t *Trie) All() []symbols.Indexable {
+	return collectSymbols(t.root, false)
+}
+
 // Searches an exact node in the trie
 func (t *Trie) searchExact(query string) *TrieNode {
 	node := t.root
@@ -126,3 +126,18 @@ func TestTrie_clearing_by_tag_should_keep_nodes_with_children(t *testing.T) {
 	assert.Equal(t, 1, len(trie.Search("app::structName::method1")))
 	assert.Equal(t, 0, len(trie.Search("app::structName::method2")))
 }
+
+func TestTrie_all(t *testing.T) {
+	trie := NewTrie()
+	docId := "doc"
+	strukt := symbols.NewStructBuilder("structName", "app", docId).Build()
+	fun := symbols.NewFunctionBuilder("method1", symbols.NewTypeFromString("void", "app"), "app", docId).WithTypeIdentifier("structName").Build()
+	trie.Insert(strukt)
+	trie.Insert(fun)
+
+	result := sort(trie.All())
+
+	assert.Equal(t, 2, len(result))
+	assert.Equal(t, "app::structName", result[0].GetFQN())
+	assert.Equal(t, "app::structName.method1", result[1].GetFQN())
+}
@@ -1,5 +1,7 @@
 package c3
 
+import "regexp"
+
 var keywords = map[string]struct{}{
 	"void": {}, "bool": {}, "char": {}, "double": {},
 	"float": {}, "float16": {}, "int128": {}, "ichar": {},
@@ -37,3 +39,35 @@ func IsLanguageKeyword(symbol string) bool {
 	_, exists := keywords[symbol]
 	return exists
 }
+
+var identifierClasses = []struct {
+	class   string
+	pattern *regexp.Regexp
+}{
+	{"const_ident", regexp.MustCompile(`^_*[A-Z][A-Z0-9_]*$`)},
+	{"type_ident", regexp.MustCompile(`^_*[A-Z][A-Za-z0-9_]*[a-z][A-Za-z0-9_]*$`)},
+	{"ident", regexp.MustCompile(`^_*[a-z][A-Za-z0-9_]*$`)},
+	{"ct_const_ident", regexp.MustCompile(`^\$_*[A-Z][A-Z0-9_]*$`)},
+	{"ct_type_ident", regexp.MustCompile(`^\$_*[A-Z][A-Za-z0-9_]*[a-z][A-Za-z0-9_]*$`)},
+	{"ct_ident", regexp.MustCompile(`^\$_*[a-z][A-Za-z0-9_]*$`)},
+	{"at_ident", regexp.MustCompile(`^@_*[a-z][A-Za-z0-9_]*$`)},
+	{"hash_ident", regexp.MustCompile(`^#_*[a-z][A-Za-z0-9_]*$`)},
+}
+
+// IdentifierClass returns the kind of identifier `symbol` is, following the naming
+// rules of the language: `const_ident`, `type_ident`, `ident`, or their compile time
+// (`$`), macro (`@`) and hash (`#`) variants. Returns an empty string if `symbol`
+// is not a valid identifier.
+func IdentifierClass(symbol string) string {
+	if IsLanguageKeyword(symbol) {
+		return ""
+	}
+
+	for _, identifier := range identifierClasses {
+		if identifier.pattern.MatchString(symbol) {
+			return identifier.class
+		}
+	}
+
+	return ""
+}
@@ -1,6 +1,9 @@
 package document
 
 import (
+	"strings"
+	"sync"
+
 	"github.com/pherrymason/c3-lsp/internal/lsp/cst"
 	code "github.com/pherrymason/c3-lsp/pkg/document/sourcecode"
 	"github.com/pherrymason/c3-lsp/pkg/symbols"
@@ -15,18 +18,56 @@ const MODULE_SEPARATOR = ':'
 type Document struct {
 	URI string
 	//NeedsRefreshDiagnostics bool
-	ContextSyntaxTree *sitter.Tree
-	SourceCode        code.SourceCode
+	syntaxTree *syntaxTree
+	SourceCode code.SourceCode
+}
+
+// syntaxTree is the syntax tree of a document, parsed the first time it is used.
+type syntaxTree struct {
+	once sync.Once
+	tree *sitter.Tree
+}
+
+func parsedSyntaxTree(tree *sitter.Tree) *syntaxTree {
+	parsed := &syntaxTree{tree: tree}
+	parsed.once.Do(func() {})
+
+	return parsed
 }
 
 func NewDocument(docId string, sourceCode string) Document {
 	return Document{
-		URI:               docId,
-		ContextSyntaxTree: cst.GetParsedTreeFromString(sourceCode),
-		SourceCode:        code.NewSourceCode(sourceCode),
+		URI:        docId,
+		syntaxTree: parsedSyntaxTree(cst.GetParsedTreeFromString(sourceCode)),
+		SourceCode: code.NewSourceCode(sourceCode),
 	}
 }
 
+// NewUnparsedDocument creates a Document whose syntax tree is parsed the first time it is used,
+// see SyntaxTree. Files indexed from disk have their symbols loaded from the index cache, most
+// of them are never opened nor searched.
+func NewUnparsedDocument(docId string, sourceCode string) Document {
+	return Document{
+		URI:        docId,
+		syntaxTree: &syntaxTree{},
+		SourceCode: code.NewSourceCode(sourceCode),
+	}
+}
+
+// SyntaxTree returns the syntax tree of the Document, parsing it if it was not parsed yet.
+// Nil when there is none.
+func (d *Document) SyntaxTree() *sitter.Tree {
+	if d.syntaxTree == nil {
+		return nil
+	}
+
+	d.syntaxTree.once.Do(func() {
+		d.syntaxTree.tree = cst.GetParsedTreeFromString(d.SourceCode.Text)
+	})
+
+	return d.syntaxTree.tree
+}
+
 func NewDocumentFromString(docId string, sourceCode string) Document {
 	return NewDocument(docId, sourceCode)
 }
@@ -38,45 +79,74 @@ func NewDocumentFromDocURI(docURI string, sourceCode string, docVersion int32) *
 	return &doc
 }
 
+// WithChanges returns a copy of the Document with the LSP textDocument/didChange events applied.
+// The Document is left untouched, so it can still be read while the copy is reparsed.
+func (d *Document) WithChanges(changes []interface{}) *Document {
+	changed := *d
+	if tree := d.SyntaxTree(); tree != nil {
+		// Editing a tree modifies it, its copy is edited instead.
+		changed.syntaxTree = parsedSyntaxTree(tree.Copy())
+	}
+	changed.ApplyChanges(changes)
+
+	return &changed
+}
+
 // ApplyChanges updates the content of the Document from LSP textDocument/didChange events.
+// The syntax tree is reparsed incrementally, reusing the nodes not affected by the changes.
 func (d *Document) ApplyChanges(changes []interface{}) {
+	tree := d.SyntaxTree()
 	for _, change := range changes {
 		switch c := change.(type) {
 		case protocol.TextDocumentContentChangeEvent:
 			startIndex, endIndex := c.Range.IndexesIn(d.SourceCode.Text)
+			if tree != nil {
+				tree.Edit(textEditInput(d.SourceCode.Text, startIndex, endIndex, c.Text))
+			}
 			d.SourceCode.Text = d.SourceCode.Text[:startIndex] + c.Text + d.SourceCode.Text[endIndex:]
 		case protocol.TextDocumentContentChangeEventWhole:
 			d.SourceCode.Text = c.Text
+			// Nothing can be reused from the previous tree.
+			tree = nil
 		}
 	}
 
-	//d.lines = nil
-	d.updateParsedTree()
-	d.ContextSyntaxTree = cst.GetParsedTreeFromString(d.SourceCode.Text)
-}
-func (d *Document) updateParsedTree() {
-	// TODO
-	// should the Document store the parsed CTS?
-	// would allow parsing incrementally and be faster
-	// // change 1 -> true
-	//		newText := []byte("let a = true")
-	//		tree.Edit(sitter.EditInput{
-	//    		StartIndex:  8,
-	//    		OldEndIndex: 9,
-	//    		NewEndIndex: 12,
-	//    		StartPoint: sitter.Point{
-	//    		    Row:    0,
-	//    		    Column: 8,
-	//    		},
-	//    		OldEndPoint: sitter.Point{
-	//    		    Row:    0,
-	//    		    Column: 9,
-	//    		},
-	//    		NewEndPoint: sitter.Point{
-	//    		    Row:    0,
-	//    		    Column: 12,
-	//    		},
-	//		})
+	d.syntaxTree = parsedSyntaxTree(cst.ReparseTree(tree, d.SourceCode.Text))
+}
+
+// textEditInput describes for tree-sitter the replacement of text[startIndex:endIndex] by newText.
+func textEditInput(text string, startIndex int, endIndex int, newText string) sitter.EditInput {
+	startPoint := pointAtIndex(text, startIndex)
+
+	return sitter.EditInput{
+		StartIndex:  uint32(startIndex),
+		OldEndIndex: uint32(endIndex),
+		NewEndIndex: uint32(startIndex + len(newText)),
+		StartPoint:  startPoint,
+		OldEndPoint: pointAtIndex(text, endIndex),
+		NewEndPoint: pointAfterText(startPoint, newText),
+	}
+}
+
+// pointAtIndex converts a byte index into a tree-sitter point. Columns are measured in bytes.
+func pointAtIndex(text string, index int) sitter.Point {
+	before := text[:index]
+	return sitter.Point{
+		Row:    uint32(strings.Count(before, "\n")),
+		Column: uint32(index - (strings.LastIndex(before, "\n") + 1)),
+	}
+}
+
+func pointAfterText(start sitter.Point, text string) sitter.Point {
+	rows := strings.Count(text, "\n")
+	if rows == 0 {
+		return sitter.Point{Row: start.Row, Column: start.Column + uint32(len(text))}
+	}
+
+	return sitter.Point{
+		Row:    start.Row + uint32(rows),
+		Column: uint32(len(text) - (strings.LastIndex(text, "\n") + 1)),
+	}
 }
 
 func (d *Document) HasPointInFrontSymbol(position symbols.Position) bool {
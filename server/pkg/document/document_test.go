@@ -5,8 +5,11 @@ import (
 	"fmt"
 	"testing"
 
+	"github.com/pherrymason/c3-lsp/internal/lsp/cst"
 	idx "github.com/pherrymason/c3-lsp/pkg/symbols"
+	sitter "github.com/smacker/go-tree-sitter"
 	"github.com/stretchr/testify/assert"
+	protocol "github.com/tliron/glsp/protocol_3_16"
 )
 
 func TestDocument_GetSymbolRangeAtIndex_does_not_find_symbol(t *testing.T) {
@@ -94,3 +97,65 @@ func TestDocument_ParentSymbolInPosition(t *testing.T) {
 		})
 	}
 }
+
+func TestDocument_ApplyChanges_reparses_incrementally(t *testing.T) {
+	source := "module app;\nfn void main()\n{\n\tint x = 1;\n}\n"
+	cases := []struct {
+		name     string
+		change   protocol.Range
+		text     string
+		expected string
+	}{
+		{"single line", protocol.Range{Start: protocol.Position{Line: 3, Character: 9}, End: protocol.Position{Line: 3, Character: 10}}, "42", "module app;\nfn void main()\n{\n\tint x = 42;\n}\n"},
+		{"insert lines", protocol.Range{Start: protocol.Position{Line: 4, Character: 1}, End: protocol.Position{Line: 4, Character: 1}}, "\nfn int other()\n{\n\treturn 2;\n}", "module app;\nfn void main()\n{\n\tint x = 1;\n}\nfn int other()\n{\n\treturn 2;\n}\n"},
+		{"delete lines", protocol.Range{Start: protocol.Position{Line: 2, Character: 1}, End: protocol.Position{Line: 3, Character: 11}}, "", "module app;\nfn void main()\n{\n}\n"},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := NewDocument("x", source)
+			changeRange := tt.change
+
+			doc.ApplyChanges([]interface{}{
+				protocol.TextDocumentContentChangeEvent{Range: &changeRange, Text: tt.text},
+			})
+
+			assert.Equal(t, tt.expected, doc.SourceCode.Text)
+			// Nodes reused from the previous tree must be where a full parse puts them.
+			assert.Equal(t, nodePositions(cst.GetParsedTreeFromString(tt.expected).RootNode()), nodePositions(doc.SyntaxTree().RootNode()))
+		})
+	}
+}
+
+func TestDocument_ApplyChanges_moves_nodes_after_the_change(t *testing.T) {
+	doc := NewDocument("x", "module app;\nfn void main() {}\nfn void other() {}\n")
+	changeRange := protocol.Range{Start: protocol.Position{Line: 1, Character: 8}, End: protocol.Position{Line: 1, Character: 12}}
+
+	doc.ApplyChanges([]interface{}{
+		protocol.TextDocumentContentChangeEvent{Range: &changeRange, Text: "start() {}\nfn void main"},
+	})
+
+	other := doc.SyntaxTree().RootNode().NamedChild(3)
+	assert.Equal(t, "fn void other() {}", other.Content([]byte(doc.SourceCode.Text)))
+	assert.Equal(t, sitter.Point{Row: 3, Column: 0}, other.StartPoint())
+	assert.Equal(t, sitter.Point{Row: 3, Column: 18}, other.EndPoint())
+}
+
+// nodePositions lists every node of the tree with its type and location.
+func nodePositions(node *sitter.Node) []string {
+	positions := []string{fmt.Sprintf("%s %d-%d %v-%v", node.Type(), node.StartByte(), node.EndByte(), node.StartPoint(), node.EndPoint())}
+	for i := 0; i < int(node.ChildCount()); i++ {
+		positions = append(positions, nodePositions(node.Child(i))...)
+	}
+
+	return positions
+}
+
+func TestDocument_NewUnparsedDocument_parses_its_tree_when_used(t *testing.T) {
+	doc := NewUnparsedDocument("x", "module app;\nfn void main() {}\n")
+	assert.Nil(t, doc.syntaxTree.tree)
+
+	tree := doc.SyntaxTree()
+	assert.Equal(t, "source_file", tree.RootNode().Type())
+	assert.Same(t, tree, doc.SyntaxTree())
+}
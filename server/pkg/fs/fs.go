@@ -24,6 +24,13 @@ func GetCanonicalPath(path string) string {
 	return path
 }
 
+// LibraryURIScheme is the scheme of the URIs of files inside `.c3l` library archives.
+// Clients get their content with the "c3lsp/libraryFileContent" request.
+const LibraryURIScheme = "c3l"
+
+// archiveSeparator separates the path of an archive from the path of a file inside it.
+const archiveSeparator = "!/"
+
 func ConvertPathToURI(path string, stdlibPath option.Option[string]) string {
 	path2 := strings.ReplaceAll(path, `\`, `/`)
 
@@ -34,6 +41,29 @@ func ConvertPathToURI(path string, stdlibPath option.Option[string]) string {
 	return "file:///" + strings.TrimLeft(path2, "/")
 }
 
+// ConvertArchivePathToURI returns the URI of a file inside a library archive, identified by a path
+// built with ArchiveEntryPath.
+func ConvertArchivePathToURI(path string) string {
+	return LibraryURIScheme + ":///" + strings.TrimLeft(strings.ReplaceAll(path, `\`, `/`), "/")
+}
+
+// ArchiveEntryPath returns the path identifying the file entry inside the archive.
+func ArchiveEntryPath(archive string, entry string) string {
+	return archive + archiveSeparator + strings.TrimLeft(filepath.ToSlash(entry), "/")
+}
+
+// SplitArchiveEntryPath returns the archive and the entry identified by a path built with
+// ArchiveEntryPath. ok is false when path is not inside an archive.
+func SplitArchiveEntryPath(path string) (archive string, entry string, ok bool) {
+	path = filepath.ToSlash(path)
+	i := strings.Index(path, archiveSeparator)
+	if i == -1 {
+		return "", "", false
+	}
+
+	return filepath.FromSlash(path[:i]), path[i+len(archiveSeparator):], true
+}
+
 func ScanForC3(basePath string) ([]string, error) {
 	var files []string
 	extensions := []string{"c3", "c3i"}
@@ -65,7 +95,7 @@ func UriToPath(uri string) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	if parsed.Scheme != "file" {
+	if parsed.Scheme != "file" && parsed.Scheme != LibraryURIScheme {
 		return "", errors.New("URI was not a file:// URI")
 	}
 
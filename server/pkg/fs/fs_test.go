@@ -14,3 +14,26 @@ func TestConvertPathToURI(t *testing.T) {
 
 	assert.Equal(t, "file:///D:/projects/c3-lsp/assets/c3-demo/foobar/foo.c3", uri)
 }
+
+func TestConvertArchivePathToURI(t *testing.T) {
+	path := ArchiveEntryPath("/project/lib/raylib.c3l", "raylib.c3i")
+
+	uri := ConvertArchivePathToURI(path)
+
+	assert.Equal(t, "c3l:///project/lib/raylib.c3l!/raylib.c3i", uri)
+
+	converted, err := UriToPath(uri)
+	assert.Nil(t, err)
+	assert.Equal(t, path, converted)
+}
+
+func TestSplitArchiveEntryPath(t *testing.T) {
+	archive, entry, ok := SplitArchiveEntryPath("/project/lib/raylib.c3l!/src/raylib.c3i")
+
+	assert.True(t, ok)
+	assert.Equal(t, "/project/lib/raylib.c3l", archive)
+	assert.Equal(t, "src/raylib.c3i", entry)
+
+	_, _, ok = SplitArchiveEntryPath("/project/src/main.c3")
+	assert.False(t, ok)
+}
@@ -0,0 +1,124 @@
+package parser
+
+import (
+	"hash/fnv"
+
+	idx "github.com/pherrymason/c3-lsp/pkg/symbols"
+	"github.com/pherrymason/c3-lsp/pkg/symbols_table"
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// declarationKey identifies a top level declaration between parses of the same document.
+// Its position is taken from the end of the previous top level node, so lines added or
+// removed above that node do not change it.
+type declarationKey struct {
+	module  string
+	gap     uint32
+	column  uint32
+	content uint64
+}
+
+func newDeclarationKey(node *sitter.Node, previousEnd sitter.Point, moduleDeclaration string, sourceCode []byte) declarationKey {
+	hash := fnv.New64a()
+	hash.Write(sourceCode[node.StartByte():node.EndByte()])
+
+	return declarationKey{
+		module:  moduleDeclaration,
+		gap:     node.StartPoint().Row - previousEnd.Row,
+		column:  node.StartPoint().Column,
+		content: hash.Sum64(),
+	}
+}
+
+// declaration holds the symbols parsed from a top level declaration.
+type declaration struct {
+	symbols []idx.Indexable
+	// Line where the declaration starts. Symbols store their positions, so they are moved
+	// when the declaration is found at another line.
+	line uint32
+}
+
+func (p *Parser) parseDeclaration(node *sitter.Node, moduleSymbol *idx.Module, docId *string, sourceCode []byte) declaration {
+	decl := declaration{line: node.StartPoint().Row}
+
+	switch node.Type() {
+	case "global_declaration":
+		for _, variable := range p.globalVariableDeclarationNodeToVariable(node, moduleSymbol, docId, sourceCode) {
+			decl.symbols = append(decl.symbols, variable)
+		}
+
+	case "func_definition", "func_declaration":
+		function, err := p.nodeToFunction(node, moduleSymbol, docId, sourceCode)
+		if err == nil {
+			decl.symbols = append(decl.symbols, &function)
+		}
+
+	case "enum_declaration":
+		enum := p.nodeToEnum(node, moduleSymbol, docId, sourceCode)
+		decl.symbols = append(decl.symbols, &enum)
+
+	case "struct_declaration":
+		strukt, _ := p.nodeToStruct(node, moduleSymbol, docId, sourceCode)
+		decl.symbols = append(decl.symbols, &strukt)
+
+	case "bitstruct_declaration":
+		bitstruct := p.nodeToBitStruct(node, moduleSymbol, docId, sourceCode)
+		decl.symbols = append(decl.symbols, &bitstruct)
+
+	case "define_declaration":
+		def := p.nodeToDef(node, moduleSymbol, docId, sourceCode)
+		decl.symbols = append(decl.symbols, &def)
+
+	case "const_declaration":
+		_const := p.nodeToConstant(node, moduleSymbol, docId, sourceCode)
+		decl.symbols = append(decl.symbols, &_const)
+
+	case "fault_declaration":
+		fault := p.nodeToFault(node, moduleSymbol, docId, sourceCode)
+		decl.symbols = append(decl.symbols, &fault)
+
+	case "interface_declaration":
+		interf := p.nodeToInterface(node, moduleSymbol, docId, sourceCode)
+		decl.symbols = append(decl.symbols, &interf)
+
+	case "macro_declaration":
+		macro := p.nodeToMacro(node, moduleSymbol, docId, sourceCode)
+		decl.symbols = append(decl.symbols, &macro)
+	}
+
+	return decl
+}
+
+// registerDeclaration adds the symbols of a declaration to module, and queues their types to
+// resolve. The symbols table resolves them on copies, so symbols can be registered again.
+func registerDeclaration(module *idx.Module, decl declaration, pendingToResolve *symbols_table.PendingToResolve) {
+	for _, symbol := range decl.symbols {
+		switch s := symbol.(type) {
+		case *idx.Variable:
+			module.AddVariable(s)
+			if !s.IsConstant() {
+				pendingToResolve.AddVariableType([]*idx.Variable{s}, module)
+			}
+		case *idx.Function:
+			module.AddFunction(s)
+			if s.FunctionType() != idx.Macro {
+				pendingToResolve.AddFunctionTypes(s, module)
+			}
+		case *idx.Enum:
+			module.AddEnum(s)
+		case *idx.Struct:
+			module.AddStruct(s)
+			pendingToResolve.AddStructSubtype2(s)
+			pendingToResolve.AddStructMemberTypes(s, module)
+		case *idx.Bitstruct:
+			module.AddBitstruct(s)
+		case *idx.Def:
+			module.AddDef(s)
+			pendingToResolve.AddDefType(s, module)
+		case *idx.Fault:
+			module.AddFault(s)
+		case *idx.Interface:
+			module.AddInterface(s)
+		}
+	}
+}
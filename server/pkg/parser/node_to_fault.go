@@ -41,6 +41,8 @@ func (p *Parser) nodeToFault(node *sitter.Node, currentModule *idx.Module, docId
 					constants = append(constants,
 						idx.NewFaultConstant(
 							constantNode.Content(sourceCode),
+							currentModule.GetModuleString(),
+							*docId,
 							idx.NewRangeFromTreeSitterPositions(constantNode.StartPoint(), constantNode.EndPoint()),
 						),
 					)
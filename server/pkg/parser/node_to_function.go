@@ -98,10 +98,28 @@ func (p *Parser) nodeToFunction(node *sitter.Node, currentModule *idx.Module, do
 	variables = append(variables, arguments...)
 
 	symbol.AddVariables(variables)
+	symbol.SetAttributes(nodeToAttributes(node, sourceCode))
 
 	return symbol, nil
 }
 
+// nodeToAttributes returns the attributes, like `@private`, found in the children of node.
+func nodeToAttributes(node *sitter.Node, sourceCode []byte) []string {
+	attributes := []string{}
+	for i := 0; i < int(node.ChildCount()); i++ {
+		n := node.Child(i)
+		if n.Type() != "attributes" {
+			continue
+		}
+
+		for a := 0; a < int(n.ChildCount()); a++ {
+			attributes = append(attributes, n.Child(a).Content(sourceCode))
+		}
+	}
+
+	return attributes
+}
+
 // nodeToArgument Very similar to nodeToVariable, but arguments have optional identifiers (for example when using `self` for struct methods)
 /*
 	_parameter: $ => choice(
@@ -33,16 +33,24 @@ const ModuleQuery = `(source_file ` + ModuleDeclaration + `)`
 
 type Parser struct {
 	logger commonlog.Logger
+	// Top level declarations found in the last parse of each document.
+	declarations map[string]map[declarationKey]declaration
 	//pendingToResolve symbols_table.PendingToResolve
 }
 
 func NewParser(logger commonlog.Logger) Parser {
 	return Parser{
-		logger: logger,
+		logger:       logger,
+		declarations: map[string]map[declarationKey]declaration{},
 		//pendingToResolve: symbols_table.NewPendingToResolve(),
 	}
 }
 
+// ForgetDocument drops the declarations kept to parse the document again, once it is no longer edited.
+func (p *Parser) ForgetDocument(docId string) {
+	delete(p.declarations, docId)
+}
+
 func (p *Parser) ClearProject() {
 	// p.pendingToResolve = symbols_table.NewPendingToResolve()
 }
@@ -76,7 +84,7 @@ func (p *Parser) ParseSymbols(doc *document.Document) (symbols_table.UnitModules
 		}
 		qc := sitter.NewQueryCursor()
 		qc.Exec(q, doc.ContextSyntaxTree.RootNode())*/
-	qc := cst.RunQuery(query, doc.ContextSyntaxTree.RootNode())
+	qc := cst.RunQuery(query, doc.SyntaxTree().RootNode())
 	sourceCode := []byte(doc.SourceCode.Text)
 	//fmt.Println(doc.URI, " ", doc.ContextSyntaxTree.RootNode())
 	//fmt.Println(doc.ContextSyntaxTree.RootNode().Content(sourceCode))
@@ -85,6 +93,10 @@ func (p *Parser) ParseSymbols(doc *document.Document) (symbols_table.UnitModules
 	var moduleSymbol *idx.Module
 	anonymousModuleName := true
 	lastModuleName := ""
+	moduleDeclaration := ""
+	previousDeclarations := p.declarations[doc.URI]
+	declarations := map[declarationKey]declaration{}
+	previousEnd := sitter.Point{}
 	//subtyptingToResolve := []StructWithSubtyping{}
 
 	for {
@@ -100,7 +112,7 @@ func (p *Parser) ParseSymbols(doc *document.Document) (symbols_table.UnitModules
 				moduleSymbol = parsedModules.GetOrInitModule(
 					lastModuleName,
 					&doc.URI,
-					doc.ContextSyntaxTree.RootNode(),
+					doc.SyntaxTree().RootNode(),
 					anonymousModuleName,
 				)
 			}
@@ -110,9 +122,10 @@ func (p *Parser) ParseSymbols(doc *document.Document) (symbols_table.UnitModules
 				anonymousModuleName = false
 				module, _, _ := p.nodeToModule(doc, c.Node, sourceCode)
 				lastModuleName = module.GetName()
+				moduleDeclaration = c.Node.Content(sourceCode)
 				moduleSymbol = parsedModules.UpdateOrInitModule(
 					module,
-					doc.ContextSyntaxTree.RootNode(),
+					doc.SyntaxTree().RootNode(),
 				)
 
 				start := c.Node.StartPoint()
@@ -126,55 +139,28 @@ func (p *Parser) ParseSymbols(doc *document.Document) (symbols_table.UnitModules
 				imports := p.nodeToImport(doc, c.Node, sourceCode)
 				moduleSymbol.AddImports(imports)
 
-			case "global_declaration":
-				variables := p.globalVariableDeclarationNodeToVariable(c.Node, moduleSymbol, &doc.URI, sourceCode)
-				moduleSymbol.AddVariables(variables)
-				pendingToResolve.AddVariableType(variables, moduleSymbol)
-
-			case "func_definition", "func_declaration":
-				function, err := p.nodeToFunction(c.Node, moduleSymbol, &doc.URI, sourceCode)
-				if err == nil {
-					moduleSymbol.AddFunction(&function)
-					pendingToResolve.AddFunctionTypes(&function, moduleSymbol)
+			case "global_declaration", "func_definition", "func_declaration", "enum_declaration",
+				"struct_declaration", "bitstruct_declaration", "define_declaration", "const_declaration",
+				"fault_declaration", "interface_declaration", "macro_declaration":
+				// Declarations not modified since the previous parse reuse their symbols.
+				key := newDeclarationKey(c.Node, previousEnd, moduleDeclaration, sourceCode)
+				line := c.Node.StartPoint().Row
+				decl, parsed := previousDeclarations[key]
+				// Identical declarations one after another are parsed every time.
+				_, duplicated := declarations[key]
+				parsed = parsed && !duplicated
+				if !parsed {
+					decl = p.parseDeclaration(c.Node, moduleSymbol, &doc.URI, sourceCode)
+				} else if decl.line != line {
+					// Lines were added or removed above it.
+					decl.symbols = idx.MoveSymbols(decl.symbols, int(line)-int(decl.line))
+					decl.line = line
 				}
-
-			case "enum_declaration":
-				enum := p.nodeToEnum(c.Node, moduleSymbol, &doc.URI, sourceCode)
-				moduleSymbol.AddEnum(&enum)
-
-			case "struct_declaration":
-				strukt, membersNeedingSubtypingResolve := p.nodeToStruct(c.Node, moduleSymbol, &doc.URI, sourceCode)
-				moduleSymbol.AddStruct(&strukt)
-				if len(membersNeedingSubtypingResolve) > 0 {
-					pendingToResolve.AddStructSubtype(&strukt, membersNeedingSubtypingResolve)
+				registerDeclaration(moduleSymbol, decl, &pendingToResolve)
+				if !duplicated {
+					declarations[key] = decl
 				}
 
-				pendingToResolve.AddStructMemberTypes(&strukt, moduleSymbol)
-
-			case "bitstruct_declaration":
-				bitstruct := p.nodeToBitStruct(c.Node, moduleSymbol, &doc.URI, sourceCode)
-				moduleSymbol.AddBitstruct(&bitstruct)
-
-			case "define_declaration":
-				def := p.nodeToDef(c.Node, moduleSymbol, &doc.URI, sourceCode)
-				moduleSymbol.AddDef(&def)
-				pendingToResolve.AddDefType(&def, moduleSymbol)
-
-			case "const_declaration":
-				_const := p.nodeToConstant(c.Node, moduleSymbol, &doc.URI, sourceCode)
-				moduleSymbol.AddVariable(&_const)
-
-			case "fault_declaration":
-				fault := p.nodeToFault(c.Node, moduleSymbol, &doc.URI, sourceCode)
-				moduleSymbol.AddFault(&fault)
-
-			case "interface_declaration":
-				interf := p.nodeToInterface(c.Node, moduleSymbol, &doc.URI, sourceCode)
-				moduleSymbol.AddInterface(&interf)
-
-			case "macro_declaration":
-				macro := p.nodeToMacro(c.Node, moduleSymbol, &doc.URI, sourceCode)
-				moduleSymbol.AddFunction(&macro)
 			default:
 				// TODO test that module ends up with wrong endPosition
 				// when this source code:
@@ -187,17 +173,20 @@ func (p *Parser) ParseSymbols(doc *document.Document) (symbols_table.UnitModules
 			}
 
 			moduleSymbol.SetEndPosition(nodeEndPoint)
+			previousEnd = c.Node.EndPoint()
 		}
 	}
 
 	if moduleSymbol != nil {
 		moduleSymbol.SetEndPosition(
 			idx.NewPositionFromTreeSitterPoint(
-				doc.ContextSyntaxTree.RootNode().EndPoint(),
+				doc.SyntaxTree().RootNode().EndPoint(),
 			),
 		)
 	}
 
+	p.declarations[doc.URI] = declarations
+
 	// Try to resolve as many types as possible
 	//p.resolveTypes(&parsedModules)
 
@@ -219,6 +219,8 @@ func TestParse_fault(t *testing.T) {
 		e := fault.GetConstant("IO_ERROR")
 		assert.Equal(t, "IO_ERROR", e.GetName())
 		assert.Equal(t, idx.NewRange(2, 3, 2, 11), e.GetIdRange())
+		assert.Equal(t, "doc", e.GetDocumentURI())
+		assert.True(t, e.HasSourceCode())
 		assert.Same(t, fault.Children()[0], e)
 
 		e = fault.GetConstant("PARSE_ERROR")
@@ -500,3 +502,66 @@ func TestExtractSymbols_module_with_generics(t *testing.T) {
 	module = symbols.Get("foo::another::deep")
 	assert.Equal(t, "foo::another::deep", module.GetName())
 }
+
+func TestParses_reuses_unchanged_declarations(t *testing.T) {
+	source := `module app;
+fn void first() {}
+fn void second() {}
+`
+	doc := document.NewDocument("doc", source)
+	parser := createParser()
+	symbols, _ := parser.ParseSymbols(&doc)
+	module := symbols.Get("app")
+	first := module.ChildrenFunctions[0]
+	second := module.ChildrenFunctions[1]
+
+	doc = document.NewDocument("doc", `module app;
+fn void first() {}
+fn int second() {}
+`)
+	symbols, _ = parser.ParseSymbols(&doc)
+	module = symbols.Get("app")
+
+	assert.Same(t, first, module.ChildrenFunctions[0])
+	assert.NotSame(t, second, module.ChildrenFunctions[1])
+	assert.Equal(t, "int", module.ChildrenFunctions[1].GetReturnType().GetName())
+}
+
+func TestParses_moves_declarations_below_added_lines(t *testing.T) {
+	source := `module app;
+fn void first() {}
+fn void second() {}
+`
+	doc := document.NewDocument("doc", source)
+	parser := createParser()
+	symbols, _ := parser.ParseSymbols(&doc)
+	second := symbols.Get("app").ChildrenFunctions[1]
+
+	doc = document.NewDocument("doc", `module app;
+
+fn void first() {}
+fn void second() {}
+`)
+	symbols, _ = parser.ParseSymbols(&doc)
+	moved := symbols.Get("app").ChildrenFunctions[1]
+
+	assert.NotSame(t, second, moved)
+	assert.Equal(t, idx.NewRange(3, 8, 3, 14), moved.GetIdRange())
+	assert.Equal(t, idx.NewRange(2, 8, 2, 14), second.GetIdRange())
+}
+
+func TestParses_forgets_declarations_of_documents(t *testing.T) {
+	source := `module app;
+fn void first() {}
+`
+	doc := document.NewDocument("doc", source)
+	parser := createParser()
+	symbols, _ := parser.ParseSymbols(&doc)
+	first := symbols.Get("app").ChildrenFunctions[0]
+
+	parser.ForgetDocument("doc")
+	assert.Empty(t, parser.declarations)
+
+	symbols, _ = parser.ParseSymbols(&doc)
+	assert.NotSame(t, first, symbols.Get("app").ChildrenFunctions[0])
+}
@@ -0,0 +1,188 @@
+package symbols
+
+import (
+	"slices"
+
+	"github.com/pherrymason/c3-lsp/pkg/option"
+)
+
+// CopyModules copies the symbol trees of modules, so the copies can be modified without
+// changing the originals. It also returns the copy of every symbol found in them.
+func CopyModules(modules []*Module) ([]*Module, map[Indexable]Indexable) {
+	copier := symbolCopier{copies: map[Indexable]Indexable{}}
+	copied := []*Module{}
+	for _, module := range modules {
+		copied = append(copied, copyOf(&copier, module))
+	}
+
+	return copied, copier.copies
+}
+
+// MoveSymbols copies the symbol trees of symbols, moving their ranges down by lines, or up
+// when lines is negative.
+func MoveSymbols(symbols []Indexable, lines int) []Indexable {
+	copier := symbolCopier{copies: map[Indexable]Indexable{}, lines: lines}
+	moved := []Indexable{}
+	for _, symbol := range symbols {
+		moved = append(moved, copier.copy(symbol))
+	}
+
+	return moved
+}
+
+// symbolCopier copies symbols once, even those referenced from more than one place, like
+// struct members, which are both members and children of their struct.
+type symbolCopier struct {
+	copies map[Indexable]Indexable
+	lines  int
+}
+
+func copyOf[T Indexable](c *symbolCopier, symbol T) T {
+	return c.copy(symbol).(T)
+}
+
+func copyAll[T Indexable](c *symbolCopier, symbols []T) []T {
+	if symbols == nil {
+		return nil
+	}
+
+	copied := make([]T, 0, len(symbols))
+	for _, symbol := range symbols {
+		copied = append(copied, copyOf(c, symbol))
+	}
+
+	return copied
+}
+
+func copyByName[T Indexable](c *symbolCopier, symbols map[string]T) map[string]T {
+	if symbols == nil {
+		return nil
+	}
+
+	copied := make(map[string]T, len(symbols))
+	for name, symbol := range symbols {
+		copied[name] = copyOf(c, symbol)
+	}
+
+	return copied
+}
+
+func (c *symbolCopier) copy(symbol Indexable) Indexable {
+	if copied, ok := c.copies[symbol]; ok {
+		return copied
+	}
+
+	switch s := symbol.(type) {
+	case *Module:
+		copied := *s
+		c.copies[symbol] = &copied
+		copied.Variables = copyByName(c, s.Variables)
+		copied.Enums = copyByName(c, s.Enums)
+		copied.Faults = copyByName(c, s.Faults)
+		copied.Structs = copyByName(c, s.Structs)
+		copied.Bitstructs = copyByName(c, s.Bitstructs)
+		copied.Defs = copyByName(c, s.Defs)
+		copied.ChildrenFunctions = copyAll(c, s.ChildrenFunctions)
+		copied.Interfaces = copyByName(c, s.Interfaces)
+		copied.Imports = slices.Clone(s.Imports)
+		copied.GenericParameters = copyByName(c, s.GenericParameters)
+		c.copyBase(&copied.BaseIndexable)
+		return &copied
+	case *Function:
+		copied := *s
+		c.copies[symbol] = &copied
+		copied.Variables = copyByName(c, s.Variables)
+		c.copyBase(&copied.BaseIndexable)
+		return &copied
+	case *Variable:
+		copied := *s
+		c.copies[symbol] = &copied
+		c.copyBase(&copied.BaseIndexable)
+		return &copied
+	case *Enum:
+		copied := *s
+		c.copies[symbol] = &copied
+		copied.enumerators = copyAll(c, s.enumerators)
+		c.copyBase(&copied.BaseIndexable)
+		return &copied
+	case *Enumerator:
+		copied := *s
+		c.copies[symbol] = &copied
+		copied.associatedValues = slices.Clone(s.associatedValues)
+		for i := range copied.associatedValues {
+			c.copyBase(&copied.associatedValues[i].BaseIndexable)
+		}
+		c.copyBase(&copied.BaseIndexable)
+		return &copied
+	case *Fault:
+		copied := *s
+		c.copies[symbol] = &copied
+		copied.constants = copyAll(c, s.constants)
+		c.copyBase(&copied.BaseIndexable)
+		return &copied
+	case *FaultConstant:
+		copied := *s
+		c.copies[symbol] = &copied
+		c.copyBase(&copied.BaseIndexable)
+		return &copied
+	case *Struct:
+		copied := *s
+		c.copies[symbol] = &copied
+		copied.members = copyAll(c, s.members)
+		c.copyBase(&copied.BaseIndexable)
+		return &copied
+	case *StructMember:
+		copied := *s
+		c.copies[symbol] = &copied
+		c.copyBase(&copied.BaseIndexable)
+		return &copied
+	case *Bitstruct:
+		copied := *s
+		c.copies[symbol] = &copied
+		copied.members = copyAll(c, s.members)
+		c.copyBase(&copied.BaseIndexable)
+		return &copied
+	case *Def:
+		copied := *s
+		c.copies[symbol] = &copied
+		if s.resolvesToType.IsSome() {
+			resolvesTo := *s.resolvesToType.Get()
+			copied.resolvesToType = option.Some(&resolvesTo)
+		}
+		c.copyBase(&copied.BaseIndexable)
+		return &copied
+	case *Interface:
+		copied := *s
+		c.copies[symbol] = &copied
+		copied.methods = copyByName(c, s.methods)
+		c.copyBase(&copied.BaseIndexable)
+		return &copied
+	case *GenericParameter:
+		copied := *s
+		c.copies[symbol] = &copied
+		c.copyBase(&copied.BaseIndexable)
+		return &copied
+	}
+
+	// Unknown symbols are shared.
+	c.copies[symbol] = symbol
+	return symbol
+}
+
+// copyBase copies the symbols referenced by b, which was copied from another symbol, and moves its ranges.
+func (c *symbolCopier) copyBase(b *BaseIndexable) {
+	b.module = ModulePath{tokens: slices.Clone(b.module.tokens)}
+	b.attributes = slices.Clone(b.attributes)
+	b.children = copyAll(c, b.children)
+	b.nestedScopes = copyAll(c, b.nestedScopes)
+	b.idRange = b.idRange.moved(c.lines)
+	b.docRange = b.docRange.moved(c.lines)
+}
+
+// moved returns the range moved down by lines, or up when lines is negative.
+func (r Range) moved(lines int) Range {
+	r.Start.Line = uint(int(r.Start.Line) + lines)
+	r.End.Line = uint(int(r.End.Line) + lines)
+
+	return r
+}
@@ -0,0 +1,40 @@
+package symbols
+
+import (
+	"testing"
+
+	"github.com/pherrymason/c3-lsp/pkg/option"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCopyModules_copies_symbols_once(t *testing.T) {
+	docId := "file:///app.c3"
+	module := NewModule("app", docId, NewRange(0, 7, 0, 10), NewRange(0, 0, 20, 0))
+	member := NewStructMember("x", NewTypeFromString("Point", "app"), option.None[[2]uint](), "app", docId, NewRange(1, 5, 1, 6))
+	strukt := NewStruct("Line", []string{}, []*StructMember{&member}, "app", docId, NewRange(1, 7, 1, 11), NewRange(1, 0, 3, 1))
+	module.AddStruct(&strukt)
+
+	copies, copied := CopyModules([]*Module{module})
+
+	copiedStruct := copies[0].Structs["Line"]
+	assert.NotSame(t, &strukt, copiedStruct)
+	assert.Same(t, copied[&strukt], copiedStruct)
+	assert.Same(t, copiedStruct.GetMembers()[0], copiedStruct.Children()[0])
+
+	copiedStruct.GetMembers()[0].GetType().SetModule("geometry")
+	assert.Equal(t, "app::Point", member.GetType().GetFullQualifiedName())
+}
+
+func TestMoveSymbols_moves_ranges_of_copies(t *testing.T) {
+	docId := "file:///app.c3"
+	function := NewFunction("main", NewTypeFromString("void", "app"), []string{"args"}, "app", docId, NewRange(2, 5, 2, 9), NewRange(2, 0, 5, 1))
+	argument := NewVariable("args", NewTypeFromString("String[]", "app"), "app", docId, NewRange(2, 19, 2, 23), NewRange(2, 10, 2, 23))
+	function.AddVariable(&argument)
+
+	moved := MoveSymbols([]Indexable{&function}, 3)[0].(*Function)
+
+	assert.Equal(t, NewRange(5, 5, 5, 9), moved.GetIdRange())
+	assert.Equal(t, NewRange(5, 0, 8, 1), moved.GetDocumentRange())
+	assert.Equal(t, NewRange(5, 19, 5, 23), moved.Variables["args"].GetIdRange())
+	assert.Equal(t, NewRange(2, 5, 2, 9), function.GetIdRange())
+}
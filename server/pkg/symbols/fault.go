@@ -91,12 +91,15 @@ func (e FaultConstant) GetHoverInfo() string {
 	return e.name
 }
 
-func NewFaultConstant(name string, idRange Range) *FaultConstant {
+func NewFaultConstant(name string, module string, docId string, idRange Range) *FaultConstant {
 	return &FaultConstant{
-		BaseIndexable: BaseIndexable{
-			name:    name,
-			idRange: idRange,
-			Kind:    protocol.CompletionItemKindEnumMember,
-		},
+		BaseIndexable: NewBaseIndexable(
+			name,
+			module,
+			docId,
+			idRange,
+			NewRange(0, 0, 0, 0),
+			protocol.CompletionItemKindEnumMember,
+		),
 	}
 }
@@ -34,7 +34,7 @@ type Indexable interface {
 	IsSubModuleOf(parentModule ModulePath) bool
 
 	GetHoverInfo() string
-	HasSourceCode() bool // This will return false for that code that is not accesible either because it belongs to the stdlib, or inside a .c3lib library. This results in disabling "Go to definition" / "Go to declaration" on these symbols
+	HasSourceCode() bool // This will return false for that code that is not accesible because it belongs to the stdlib. This results in disabling "Go to definition" / "Go to declaration" on these symbols
 
 	Children() []Indexable
 	NestedScopes() []Indexable
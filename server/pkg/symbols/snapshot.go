@@ -0,0 +1,517 @@
+package symbols
+
+import (
+	"fmt"
+
+	"github.com/pherrymason/c3-lsp/pkg/option"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// Snapshot is a copy of a symbol tree made only of exported fields, so it can be
+// serialized with encoding/gob. Symbols referenced from more than one place, like struct
+// members, which are both members and children of their struct, are stored once.
+type Snapshot struct {
+	Nodes []SnapshotNode
+	Roots []int
+}
+
+const (
+	snapshotModule uint8 = iota
+	snapshotFunction
+	snapshotVariable
+	snapshotEnum
+	snapshotEnumerator
+	snapshotFault
+	snapshotFaultConstant
+	snapshotStruct
+	snapshotStructMember
+	snapshotBitstruct
+	snapshotDef
+	snapshotInterface
+	snapshotGenericParameter
+)
+
+// SnapshotNode holds a symbol. Symbols it references are stored as indexes of Snapshot.Nodes.
+type SnapshotNode struct {
+	Kind           uint8
+	Name           string
+	ModuleString   string
+	ModulePath     []string
+	DocumentURI    string
+	HasSourceCode  bool
+	IdRange        Range
+	DocRange       Range
+	CompletionKind protocol.CompletionItemKind
+	Attributes     []string
+	Children       []int
+	NestedScopes   []int
+
+	// Type of variables and struct members, return type of functions, backing type of
+	// bitstructs and type of defs when HasType is true.
+	Type    SnapshotType
+	HasType bool
+	// BaseType of enums and faults.
+	BaseType string
+	// Value of enumerators.
+	Value string
+	// ResolvesTo of defs.
+	ResolvesTo     string
+	FunctionType   FunctionType
+	ArgumentIds    []string
+	TypeIdentifier string
+	// Members of structs and bitstructs, enumerators of enums, constants of faults and
+	// associated values of enumerators.
+	Members              []int
+	IsUnion              bool
+	Implements           []string
+	BitRange             *[2]uint
+	InlinePendingResolve bool
+	ExpandedInline       bool
+	Imports              []string
+}
+
+type SnapshotType struct {
+	BaseTypeLanguage  bool
+	Name              string
+	Pointer           int
+	Optional          bool
+	GenericArguments  []SnapshotType
+	Module            string
+	IsGenericArgument bool
+	IsCollection      bool
+	CollectionSize    *int
+}
+
+// NewSnapshot copies the symbol trees of modules.
+func NewSnapshot(modules []*Module) (Snapshot, error) {
+	encoder := snapshotEncoder{ids: map[Indexable]int{}}
+	for _, module := range modules {
+		id, err := encoder.encode(module)
+		if err != nil {
+			return Snapshot{}, err
+		}
+		encoder.snapshot.Roots = append(encoder.snapshot.Roots, id)
+	}
+
+	return encoder.snapshot, nil
+}
+
+type snapshotEncoder struct {
+	snapshot Snapshot
+	ids      map[Indexable]int
+}
+
+func (e *snapshotEncoder) encode(symbol Indexable) (int, error) {
+	if id, ok := e.ids[symbol]; ok {
+		return id, nil
+	}
+
+	var node SnapshotNode
+	var base *BaseIndexable
+	var members []Indexable
+
+	switch s := symbol.(type) {
+	case *Module:
+		node.Kind = snapshotModule
+		node.Imports = s.Imports
+		base = &s.BaseIndexable
+	case *Function:
+		node.Kind = snapshotFunction
+		node.FunctionType = s.fType
+		node.Type = newSnapshotType(s.returnType)
+		node.ArgumentIds = s.argumentIds
+		node.TypeIdentifier = s.typeIdentifier
+		base = &s.BaseIndexable
+	case *Variable:
+		node.Kind = snapshotVariable
+		node.Type = newSnapshotType(s.Type)
+		base = &s.BaseIndexable
+	case *Enum:
+		node.Kind = snapshotEnum
+		node.BaseType = s.baseType
+		for _, enumerator := range s.enumerators {
+			members = append(members, enumerator)
+		}
+		base = &s.BaseIndexable
+	case *Enumerator:
+		node.Kind = snapshotEnumerator
+		node.Value = s.value
+		for i := range s.associatedValues {
+			members = append(members, &s.associatedValues[i])
+		}
+		base = &s.BaseIndexable
+	case *Fault:
+		node.Kind = snapshotFault
+		node.BaseType = s.baseType
+		for _, constant := range s.constants {
+			members = append(members, constant)
+		}
+		base = &s.BaseIndexable
+	case *FaultConstant:
+		node.Kind = snapshotFaultConstant
+		base = &s.BaseIndexable
+	case *Struct:
+		node.Kind = snapshotStruct
+		node.IsUnion = s.isUnion
+		node.Implements = s.implements
+		for _, member := range s.members {
+			members = append(members, member)
+		}
+		base = &s.BaseIndexable
+	case *StructMember:
+		node.Kind = snapshotStructMember
+		node.Type = newSnapshotType(s.baseType)
+		if s.bitRange.IsSome() {
+			bitRange := s.bitRange.Get()
+			node.BitRange = &bitRange
+		}
+		node.InlinePendingResolve = s.inlinePendingResolve
+		node.ExpandedInline = s.expandedInline
+		base = &s.BaseIndexable
+	case *Bitstruct:
+		node.Kind = snapshotBitstruct
+		node.Type = newSnapshotType(s.backingType)
+		node.Implements = s.implements
+		for _, member := range s.members {
+			members = append(members, member)
+		}
+		base = &s.BaseIndexable
+	case *Def:
+		node.Kind = snapshotDef
+		node.ResolvesTo = s.resolvesTo
+		if s.resolvesToType.IsSome() {
+			node.Type = newSnapshotType(*s.resolvesToType.Get())
+			node.HasType = true
+		}
+		base = &s.BaseIndexable
+	case *Interface:
+		node.Kind = snapshotInterface
+		base = &s.BaseIndexable
+	case *GenericParameter:
+		node.Kind = snapshotGenericParameter
+		base = &s.BaseIndexable
+	default:
+		return 0, fmt.Errorf("cannot snapshot symbol %s of type %T", symbol.GetName(), symbol)
+	}
+
+	node.Name = base.name
+	node.ModuleString = base.moduleString
+	node.ModulePath = base.module.tokens
+	node.DocumentURI = base.documentURI
+	node.HasSourceCode = base.hasSourceCode
+	node.IdRange = base.idRange
+	node.DocRange = base.docRange
+	node.CompletionKind = base.Kind
+	node.Attributes = base.attributes
+
+	// Reserve the id before encoding the symbols it references.
+	id := len(e.snapshot.Nodes)
+	e.ids[symbol] = id
+	e.snapshot.Nodes = append(e.snapshot.Nodes, SnapshotNode{})
+
+	var err error
+	if node.Members, err = e.encodeAll(members); err != nil {
+		return 0, err
+	}
+	if node.Children, err = e.encodeAll(base.children); err != nil {
+		return 0, err
+	}
+	if node.NestedScopes, err = e.encodeAll(base.nestedScopes); err != nil {
+		return 0, err
+	}
+	e.snapshot.Nodes[id] = node
+
+	return id, nil
+}
+
+func (e *snapshotEncoder) encodeAll(symbols []Indexable) ([]int, error) {
+	ids := []int{}
+	for _, symbol := range symbols {
+		id, err := e.encode(symbol)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+func newSnapshotType(t Type) SnapshotType {
+	snapshotType := SnapshotType{
+		BaseTypeLanguage:  t.baseTypeLanguage,
+		Name:              t.name,
+		Pointer:           t.pointer,
+		Optional:          t.optional,
+		Module:            t.module,
+		IsGenericArgument: t.isGenericArgument,
+		IsCollection:      t.isCollection,
+	}
+	for _, argument := range t.genericArguments {
+		snapshotType.GenericArguments = append(snapshotType.GenericArguments, newSnapshotType(argument))
+	}
+	if t.collectionSize.IsSome() {
+		size := t.collectionSize.Get()
+		snapshotType.CollectionSize = &size
+	}
+
+	return snapshotType
+}
+
+func (t SnapshotType) toType() Type {
+	_type := Type{
+		baseTypeLanguage:  t.BaseTypeLanguage,
+		name:              t.Name,
+		pointer:           t.Pointer,
+		optional:          t.Optional,
+		module:            t.Module,
+		isGenericArgument: t.IsGenericArgument,
+		isCollection:      t.IsCollection,
+		collectionSize:    option.None[int](),
+	}
+	for _, argument := range t.GenericArguments {
+		_type.genericArguments = append(_type.genericArguments, argument.toType())
+	}
+	if t.CollectionSize != nil {
+		_type.collectionSize = option.Some(*t.CollectionSize)
+	}
+
+	return _type
+}
+
+// Modules rebuilds the modules the snapshot was made from.
+func (s Snapshot) Modules() ([]*Module, error) {
+	symbols := make([]Indexable, len(s.Nodes))
+	for id, node := range s.Nodes {
+		symbols[id] = newSnapshotSymbol(node)
+		if symbols[id] == nil {
+			return nil, fmt.Errorf("unknown kind %d of snapshot symbol %s", node.Kind, node.Name)
+		}
+	}
+
+	lookup := func(ids []int) ([]Indexable, error) {
+		found := []Indexable{}
+		for _, id := range ids {
+			if id < 0 || id >= len(symbols) {
+				return nil, fmt.Errorf("snapshot symbol %d out of range", id)
+			}
+			found = append(found, symbols[id])
+		}
+		return found, nil
+	}
+
+	for id, node := range s.Nodes {
+		children, err := lookup(node.Children)
+		if err != nil {
+			return nil, err
+		}
+		nestedScopes, err := lookup(node.NestedScopes)
+		if err != nil {
+			return nil, err
+		}
+		members, err := lookup(node.Members)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := linkSnapshotSymbol(symbols[id], children, nestedScopes, members); err != nil {
+			return nil, err
+		}
+	}
+
+	modules := []*Module{}
+	for _, id := range s.Roots {
+		if id < 0 || id >= len(symbols) {
+			return nil, fmt.Errorf("snapshot symbol %d out of range", id)
+		}
+		module, ok := symbols[id].(*Module)
+		if !ok {
+			return nil, fmt.Errorf("snapshot root %s is not a module", symbols[id].GetName())
+		}
+		modules = append(modules, module)
+	}
+
+	return modules, nil
+}
+
+// newSnapshotSymbol creates the symbol of node, without the symbols it references.
+func newSnapshotSymbol(node SnapshotNode) Indexable {
+	base := BaseIndexable{
+		name:          node.Name,
+		moduleString:  node.ModuleString,
+		module:        NewModulePath(node.ModulePath),
+		documentURI:   node.DocumentURI,
+		hasSourceCode: node.HasSourceCode,
+		idRange:       node.IdRange,
+		docRange:      node.DocRange,
+		Kind:          node.CompletionKind,
+		attributes:    node.Attributes,
+	}
+	if base.attributes == nil {
+		base.attributes = []string{}
+	}
+
+	switch node.Kind {
+	case snapshotModule:
+		module := NewModule("", "", Range{}, Range{})
+		module.BaseIndexable = base
+		module.Imports = append(module.Imports, node.Imports...)
+		return module
+	case snapshotFunction:
+		return &Function{
+			fType:          node.FunctionType,
+			returnType:     node.Type.toType(),
+			argumentIds:    node.ArgumentIds,
+			typeIdentifier: node.TypeIdentifier,
+			Variables:      make(map[string]*Variable),
+			BaseIndexable:  base,
+		}
+	case snapshotVariable:
+		return &Variable{Type: node.Type.toType(), BaseIndexable: base}
+	case snapshotEnum:
+		return &Enum{baseType: node.BaseType, BaseIndexable: base}
+	case snapshotEnumerator:
+		return &Enumerator{value: node.Value, associatedValues: []Variable{}, BaseIndexable: base}
+	case snapshotFault:
+		return &Fault{baseType: node.BaseType, BaseIndexable: base}
+	case snapshotFaultConstant:
+		return &FaultConstant{BaseIndexable: base}
+	case snapshotStruct:
+		return &Struct{isUnion: node.IsUnion, implements: node.Implements, BaseIndexable: base}
+	case snapshotStructMember:
+		member := &StructMember{
+			baseType:             node.Type.toType(),
+			bitRange:             option.None[[2]uint](),
+			inlinePendingResolve: node.InlinePendingResolve,
+			expandedInline:       node.ExpandedInline,
+			BaseIndexable:        base,
+		}
+		if node.BitRange != nil {
+			member.bitRange = option.Some(*node.BitRange)
+		}
+		return member
+	case snapshotBitstruct:
+		return &Bitstruct{backingType: node.Type.toType(), implements: node.Implements, BaseIndexable: base}
+	case snapshotDef:
+		def := &Def{resolvesTo: node.ResolvesTo, resolvesToType: option.None[*Type](), BaseIndexable: base}
+		if node.HasType {
+			resolvesTo := node.Type.toType()
+			def.resolvesToType = option.Some(&resolvesTo)
+		}
+		return def
+	case snapshotInterface:
+		return &Interface{methods: make(map[string]*Function), BaseIndexable: base}
+	case snapshotGenericParameter:
+		return &GenericParameter{BaseIndexable: base}
+	}
+
+	return nil
+}
+
+// linkSnapshotSymbol sets the symbols referenced by symbol, rebuilding the lookup maps
+// of modules, functions and interfaces from their children.
+func linkSnapshotSymbol(symbol Indexable, children []Indexable, nestedScopes []Indexable, members []Indexable) error {
+	switch s := symbol.(type) {
+	case *Module:
+		for _, child := range children {
+			switch c := child.(type) {
+			case *Variable:
+				s.Variables[c.name] = c
+			case *Enum:
+				s.Enums[c.name] = c
+			case *Fault:
+				s.Faults[c.name] = c
+			case *Struct:
+				s.Structs[c.name] = c
+			case *Bitstruct:
+				s.Bitstructs[c.name] = c
+			case *Def:
+				s.Defs[c.name] = c
+			case *Interface:
+				s.Interfaces[c.name] = c
+			case *GenericParameter:
+				if s.GenericParameters == nil {
+					s.GenericParameters = make(map[string]*GenericParameter)
+				}
+				s.GenericParameters[c.name] = c
+			}
+		}
+		for _, scope := range nestedScopes {
+			if function, ok := scope.(*Function); ok {
+				s.ChildrenFunctions = append(s.ChildrenFunctions, function)
+			}
+		}
+	case *Function:
+		for _, child := range children {
+			if variable, ok := child.(*Variable); ok {
+				s.Variables[variable.name] = variable
+			}
+		}
+	case *Interface:
+		for _, child := range children {
+			if method, ok := child.(*Function); ok {
+				s.methods[method.name] = method
+			}
+		}
+	case *Enum:
+		for _, member := range members {
+			enumerator, ok := member.(*Enumerator)
+			if !ok {
+				return fmt.Errorf("enumerator of %s is a %T", s.name, member)
+			}
+			s.enumerators = append(s.enumerators, enumerator)
+		}
+	case *Enumerator:
+		for _, member := range members {
+			variable, ok := member.(*Variable)
+			if !ok {
+				return fmt.Errorf("associated value of %s is a %T", s.name, member)
+			}
+			s.associatedValues = append(s.associatedValues, *variable)
+		}
+	case *Fault:
+		for _, member := range members {
+			constant, ok := member.(*FaultConstant)
+			if !ok {
+				return fmt.Errorf("constant of %s is a %T", s.name, member)
+			}
+			s.constants = append(s.constants, constant)
+		}
+	case *Struct:
+		structMembers, err := snapshotStructMembers(s.name, members)
+		if err != nil {
+			return err
+		}
+		s.members = structMembers
+	case *Bitstruct:
+		structMembers, err := snapshotStructMembers(s.name, members)
+		if err != nil {
+			return err
+		}
+		s.members = structMembers
+	}
+
+	base := symbol.(interface{ snapshotBase() *BaseIndexable }).snapshotBase()
+	base.children = children
+	base.nestedScopes = nestedScopes
+
+	return nil
+}
+
+func snapshotStructMembers(name string, members []Indexable) ([]*StructMember, error) {
+	structMembers := []*StructMember{}
+	for _, member := range members {
+		structMember, ok := member.(*StructMember)
+		if !ok {
+			return nil, fmt.Errorf("member of %s is a %T", name, member)
+		}
+		structMembers = append(structMembers, structMember)
+	}
+
+	return structMembers, nil
+}
+
+// snapshotBase gives access to the BaseIndexable embedded in every symbol.
+func (b *BaseIndexable) snapshotBase() *BaseIndexable {
+	return b
+}
@@ -0,0 +1,78 @@
+package symbols
+
+import (
+	"bytes"
+	"encoding/gob"
+	"testing"
+
+	"github.com/pherrymason/c3-lsp/pkg/option"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshot_restores_modules_after_gob_round_trip(t *testing.T) {
+	docId := "file:///app.c3"
+	module := NewModule("app", docId, NewRange(0, 7, 0, 10), NewRange(0, 0, 20, 0))
+	module.AddImports([]string{"std::io"})
+	module.SetGenericParameters(map[string]*GenericParameter{
+		"Type": NewGenericParameter("Type", "app", docId, NewRange(0, 11, 0, 15), NewRange(0, 11, 0, 15)),
+	})
+
+	variable := NewVariable("count", NewType(true, "int", 0, false, true, option.Some(3), "app"), "app", docId, NewRange(1, 4, 1, 9), NewRange(1, 0, 1, 10))
+	module.AddVariable(&variable)
+
+	function := NewFunction("main", NewTypeFromString("void", "app"), []string{"args"}, "app", docId, NewRange(2, 5, 2, 9), NewRange(2, 0, 5, 1))
+	argument := NewVariable("args", NewTypeFromString("String[]", "app"), "app", docId, NewRange(2, 19, 2, 23), NewRange(2, 10, 2, 23))
+	function.AddVariable(&argument)
+	module.AddFunction(&function)
+
+	member := NewStructMember("x", NewTypeFromString("int", "app"), option.Some([2]uint{0, 3}), "app", docId, NewRange(6, 5, 6, 6))
+	inline := NewInlineSubtype("", NewTypeFromString("Base", "app"), "app", docId, NewRange(7, 5, 7, 9))
+	strukt := NewStruct("Point", []string{"Printable"}, []*StructMember{&member, &inline}, "app", docId, NewRange(6, 7, 6, 12), NewRange(6, 0, 8, 1))
+	module.AddStruct(&strukt)
+
+	enumerator := NewEnumerator("RED", "", []Variable{variable}, "app", NewRange(9, 14, 9, 17), docId)
+	enum := NewEnum("Color", "int", []*Enumerator{}, "app", docId, NewRange(9, 5, 9, 10), NewRange(9, 0, 9, 20))
+	enum.AddEnumerators([]*Enumerator{enumerator})
+	module.AddEnum(&enum)
+
+	def := NewDefType("Points", NewTypeWithGeneric(false, false, "List", 0, []Type{NewTypeFromString("Point", "app")}, "app"), "app", docId, NewRange(10, 4, 10, 10), NewRange(10, 0, 10, 30))
+	module.AddDef(&def)
+
+	snapshot, err := NewSnapshot([]*Module{module})
+	assert.Nil(t, err)
+
+	var buffer bytes.Buffer
+	assert.Nil(t, gob.NewEncoder(&buffer).Encode(snapshot))
+	var decoded Snapshot
+	assert.Nil(t, gob.NewDecoder(&buffer).Decode(&decoded))
+
+	modules, err := decoded.Modules()
+	assert.Nil(t, err)
+	assert.Len(t, modules, 1)
+
+	restored := modules[0]
+	assert.Equal(t, "app", restored.GetName())
+	assert.Equal(t, []string{"std::io"}, restored.Imports)
+	assert.Equal(t, NewRange(0, 0, 20, 0), restored.GetDocumentRange())
+	assert.Contains(t, restored.GenericParameters, "Type")
+
+	assert.Equal(t, "int[3]", restored.Variables["count"].GetType().String())
+
+	restoredFunction := restored.ChildrenFunctions[0]
+	assert.Equal(t, "main", restoredFunction.GetName())
+	assert.Equal(t, restoredFunction, restored.NestedScopes()[0])
+	assert.Equal(t, "String[]", restoredFunction.GetArguments()[0].GetType().GetName())
+
+	restoredStruct := restored.Structs["Point"]
+	assert.Equal(t, []string{"Printable"}, restoredStruct.GetInterfaces())
+	assert.Len(t, restoredStruct.GetMembers(), 2)
+	assert.Same(t, restoredStruct.GetMembers()[0], restoredStruct.Children()[0])
+	assert.Equal(t, [2]uint{0, 3}, restoredStruct.GetMembers()[0].GetBitRange())
+	assert.True(t, restoredStruct.GetMembers()[1].IsInlinePendingToResolve())
+
+	restoredEnum := restored.Enums["Color"]
+	assert.Same(t, restoredEnum.GetEnumerator("RED"), restoredEnum.Children()[0])
+	assert.Equal(t, "count", restoredEnum.GetEnumerator("RED").GetAssociatedValues()[0].GetName())
+
+	assert.Equal(t, "List", restored.Defs["Points"].ResolvedType().GetName())
+}
@@ -56,6 +56,20 @@ func (t *Type) IsPointer() bool {
 	return t.pointer > 0
 }
 
+func (t Type) IsCollection() bool {
+	return t.isCollection
+}
+
+// ElementType returns the type of the elements of a collection type.
+func (t Type) ElementType() Type {
+	element := t
+	element.isCollection = false
+	element.collectionSize = option.None[int]()
+	element.optional = false
+
+	return element
+}
+
 func (t Type) String() string {
 	pointerStr := strings.Repeat("*", t.pointer)
 	optionalStr := ""
@@ -143,3 +143,68 @@ func (p *PendingToResolve) AddDefType(def *symbols.Def, contextModule *symbols.M
 		},
 	)
 }
+
+// hasTypeNamed tells if any pending type has one of the names.
+func (p PendingToResolve) hasTypeNamed(names map[string]bool) bool {
+	for _, typesContext := range p.typesByModule {
+		for _, typeContext := range typesContext {
+			if names[typeContext.vType.GetName()] {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// rebase returns the pending types and structs found in copied symbols, pointing to their copies.
+func (p PendingToResolve) rebase(copies map[symbols.Indexable]symbols.Indexable) PendingToResolve {
+	types := map[*symbols.Type]*symbols.Type{}
+	for original, copied := range copies {
+		if originalType := typeOf(original); originalType != nil {
+			types[originalType] = typeOf(copied)
+		}
+	}
+
+	rebased := NewPendingToResolve()
+	for moduleName, typesContext := range p.typesByModule {
+		for _, typeContext := range typesContext {
+			vType, ok := types[typeContext.vType]
+			contextModule, inCopies := copies[typeContext.contextModule].(*symbols.Module)
+			if !ok || !inCopies || typeContext.IsSolved() {
+				continue
+			}
+
+			rebased.typesByModule[moduleName] = append(
+				rebased.typesByModule[moduleName],
+				PendingTypeContext{vType: vType, contextModule: contextModule},
+			)
+		}
+	}
+
+	for _, struktWithSubtyping := range p.subtyptingToResolve {
+		if strukt, ok := copies[struktWithSubtyping.strukt].(*symbols.Struct); ok {
+			rebased.AddStructSubtype(strukt, struktWithSubtyping.members)
+		}
+	}
+
+	return rebased
+}
+
+// typeOf returns the type of symbols whose type can be pending to resolve.
+func typeOf(symbol symbols.Indexable) *symbols.Type {
+	switch s := symbol.(type) {
+	case *symbols.Variable:
+		return s.GetType()
+	case *symbols.StructMember:
+		return s.GetType()
+	case *symbols.Function:
+		return s.GetReturnType()
+	case *symbols.Def:
+		if s.ResolvesToType() {
+			return s.ResolvedType()
+		}
+	}
+
+	return nil
+}
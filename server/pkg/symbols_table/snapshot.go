@@ -0,0 +1,49 @@
+package symbols_table
+
+import (
+	idx "github.com/pherrymason/c3-lsp/pkg/symbols"
+)
+
+// Snapshot copies the modules of the unit, to store them and load them later without parsing
+// the document again.
+func (ps UnitModules) Snapshot() (idx.Snapshot, error) {
+	return idx.NewSnapshot(ps.Modules())
+}
+
+// UnitModulesFromSnapshot rebuilds the modules of a document from a snapshot, along with
+// the types pending to resolve the parser would have found in them.
+func UnitModulesFromSnapshot(docId *string, snapshot idx.Snapshot) (UnitModules, PendingToResolve, error) {
+	unitModules := NewParsedModules(docId)
+	pendingToResolve := NewPendingToResolve()
+
+	modules, err := snapshot.Modules()
+	if err != nil {
+		return unitModules, pendingToResolve, err
+	}
+
+	for _, module := range modules {
+		unitModules.modules.Set(module.GetName(), module)
+
+		for _, child := range module.Children() {
+			switch symbol := child.(type) {
+			case *idx.Variable:
+				if !symbol.IsConstant() {
+					pendingToResolve.AddVariableType([]*idx.Variable{symbol}, module)
+				}
+			case *idx.Struct:
+				pendingToResolve.AddStructSubtype2(symbol)
+				pendingToResolve.AddStructMemberTypes(symbol, module)
+			case *idx.Def:
+				pendingToResolve.AddDefType(symbol, module)
+			}
+		}
+
+		for _, function := range module.ChildrenFunctions {
+			if function.FunctionType() != idx.Macro {
+				pendingToResolve.AddFunctionTypes(function, module)
+			}
+		}
+	}
+
+	return unitModules, pendingToResolve, nil
+}
@@ -0,0 +1,49 @@
+package symbols_table
+
+import (
+	"testing"
+
+	"github.com/pherrymason/c3-lsp/pkg/symbols"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnitModulesFromSnapshot_restores_types_pending_to_resolve(t *testing.T) {
+	docId := "aDocId"
+	mod := "xx"
+
+	um := NewParsedModules(&docId)
+	module := symbols.NewModuleBuilder(mod, docId).Build()
+	module.AddStruct(
+		symbols.NewStructBuilder("ToInline", mod, docId).
+			WithStructMember("a", "int", mod, docId).
+			Build(),
+	)
+	module.AddStruct(
+		symbols.NewStructBuilder("ToProcess", mod, docId).
+			WithStructMember("c", "int", mod, docId).
+			WithSubStructMember("x", "ToInline", mod, docId).
+			Build(),
+	)
+	module.AddVariable(
+		symbols.NewVariableBuilder("value", "ToProcess", mod, docId).Build(),
+	)
+	um.modules.Set(mod, module)
+
+	snapshot, err := um.Snapshot()
+	assert.Nil(t, err)
+
+	restored, pendingToResolve, err := UnitModulesFromSnapshot(&docId, snapshot)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{mod}, restored.ModuleIds())
+	assert.Len(t, pendingToResolve.subtyptingToResolve, 1)
+	assert.Len(t, pendingToResolve.GetTypesByModule(mod), 4)
+
+	symbolsTable := NewSymbolsTable()
+	symbolsTable.Register(restored, pendingToResolve)
+
+	restoredModule := symbolsTable.GetByDoc(docId).Get(mod)
+	members := restoredModule.Structs["ToProcess"].GetMembers()
+	assert.True(t, members[1].IsExpandedInline())
+	assert.Equal(t, "a", members[2].GetName())
+	assert.Equal(t, "xx::ToProcess", restoredModule.Variables["value"].GetType().GetFullQualifiedName())
+}
@@ -1,52 +1,94 @@
 package symbols_table
 
 import (
+	"maps"
+
 	"github.com/pherrymason/c3-lsp/pkg/option"
 	"github.com/pherrymason/c3-lsp/pkg/symbols"
 	protocol "github.com/tliron/glsp/protocol_3_16"
 )
 
+// SymbolsTable holds the modules of every document. Registered modules are never modified:
+// types are resolved on copies, which replace them. So the modules returned by All and
+// GetByDoc can be used while other documents are registered.
 type SymbolsTable struct {
 	parsedModulesByDocument map[protocol.DocumentUri]UnitModules
-	pendingToResolve        PendingToResolve
+	// Types of each document that could not be resolved yet.
+	pendingByDocument map[protocol.DocumentUri]PendingToResolve
+	// Modules where the types of each document were found, so they are not searched again
+	// when the document is registered again.
+	resolvedByDocument map[protocol.DocumentUri]map[resolvedType]string
+}
+
+type resolvedType struct {
+	contextModule string
+	name          string
 }
 
 func NewSymbolsTable() SymbolsTable {
 	return SymbolsTable{
 		parsedModulesByDocument: make(map[protocol.DocumentUri]UnitModules),
-		pendingToResolve:        NewPendingToResolve(),
+		pendingByDocument:       make(map[protocol.DocumentUri]PendingToResolve),
+		resolvedByDocument:      make(map[protocol.DocumentUri]map[resolvedType]string),
 	}
 }
 
-func (st *SymbolsTable) Register(unitModules UnitModules, pendingToResolve PendingToResolve) {
-	st.parsedModulesByDocument[unitModules.DocId()] = unitModules
-
-	// Merge pendingToResolve types
-	for moduleName, types := range pendingToResolve.typesByModule {
-		st.pendingToResolve.typesByModule[moduleName] = append(
-			st.pendingToResolve.typesByModule[moduleName],
-			types...,
-		)
+// Register adds the modules of a document, replacing those it had, and resolves their types.
+// It returns the other documents whose types could be resolved with them, as their modules were replaced.
+func (st *SymbolsTable) Register(unitModules UnitModules, pendingToResolve PendingToResolve) []string {
+	docId := unitModules.DocId()
+	unitModules, pendingToResolve = copyUnitModules(unitModules, pendingToResolve)
+	st.parsedModulesByDocument = maps.Clone(st.parsedModulesByDocument)
+	st.parsedModulesByDocument[docId] = unitModules
+	st.resolveTypes(docId, pendingToResolve, map[resolvedType]string{})
+	st.expendStructSubtypes(pendingToResolve.subtyptingToResolve)
+
+	// Types of other documents that were not found might be declared in the new modules.
+	declared := map[string]bool{}
+	for _, module := range unitModules.Modules() {
+		for _, child := range module.Children() {
+			declared[child.GetName()] = true
+		}
 	}
 
-	st.pendingToResolve.subtyptingToResolve = append(
-		st.pendingToResolve.subtyptingToResolve,
-		pendingToResolve.subtyptingToResolve...,
-	)
+	replaced := []string{}
+	for otherDocId, pending := range st.pendingByDocument {
+		if otherDocId == docId || !pending.hasTypeNamed(declared) {
+			continue
+		}
+
+		otherUnitModules, pending := copyUnitModules(st.parsedModulesByDocument[otherDocId], pending)
+		st.parsedModulesByDocument[otherDocId] = otherUnitModules
+		st.resolveTypes(otherDocId, pending, st.resolvedByDocument[otherDocId])
+		replaced = append(replaced, otherDocId)
+	}
 
-	st.resolveTypes()
+	return replaced
 }
 
 func (st *SymbolsTable) DeleteDocument(docId string) {
+	st.parsedModulesByDocument = maps.Clone(st.parsedModulesByDocument)
 	delete(st.parsedModulesByDocument, docId)
+	delete(st.pendingByDocument, docId)
+	delete(st.resolvedByDocument, docId)
 }
 func (st *SymbolsTable) RenameDocument(oldDocId string, newDocId string) {
 	if val, ok := st.parsedModulesByDocument[oldDocId]; ok {
+		st.parsedModulesByDocument = maps.Clone(st.parsedModulesByDocument)
 		// Asignar el valor a la nueva clave
 		st.parsedModulesByDocument[newDocId] = val
 		// Eliminar la clave antigua
 		delete(st.parsedModulesByDocument, oldDocId)
 	}
+
+	if pending, ok := st.pendingByDocument[oldDocId]; ok {
+		st.pendingByDocument[newDocId] = pending
+		delete(st.pendingByDocument, oldDocId)
+	}
+	if resolved, ok := st.resolvedByDocument[oldDocId]; ok {
+		st.resolvedByDocument[newDocId] = resolved
+		delete(st.resolvedByDocument, oldDocId)
+	}
 }
 
 func (st *SymbolsTable) GetByDoc(docId string) *UnitModules {
@@ -54,30 +96,59 @@ func (st *SymbolsTable) GetByDoc(docId string) *UnitModules {
 	return &value
 }
 
+// All returns the modules of every document. The map is not modified by later registrations.
 func (st SymbolsTable) All() map[protocol.DocumentUri]UnitModules {
 	return st.parsedModulesByDocument
 }
 
-// Processes pending Types to resolve, searching
-func (st *SymbolsTable) resolveTypes() {
+// copyUnitModules copies the modules of a document, along with its pending types, so that
+// they can be resolved without modifying the registered ones.
+func copyUnitModules(unitModules UnitModules, pendingToResolve PendingToResolve) (UnitModules, PendingToResolve) {
+	copied := NewParsedModules(unitModules.docId)
+	modules, copies := symbols.CopyModules(unitModules.Modules())
+	for i, moduleId := range unitModules.ModuleIds() {
+		copied.modules.Set(moduleId, modules[i])
+	}
+
+	return copied, pendingToResolve.rebase(copies)
+}
+
+// Processes pending Types of a document, searching them in every document unless they were
+// found when it was registered before. Those found are added to resolved, the others are kept pending.
+func (st *SymbolsTable) resolveTypes(docId string, pendingToResolve PendingToResolve, resolved map[resolvedType]string) {
+	previouslyResolved := st.resolvedByDocument[docId]
+	unsolved := NewPendingToResolve()
 
 	// Review all pending types, and see if we can resolve them
-	for moduleName, typesContext := range st.pendingToResolve.typesByModule {
+	for moduleName, typesContext := range pendingToResolve.typesByModule {
 		// Reviewing types in `moduleName`
-		for x := len(typesContext) - 1; x >= 0; x-- {
-			if typesContext[x].IsSolved() {
-				continue
+		for _, typeContext := range typesContext {
+			key := resolvedType{contextModule: typeContext.contextModule.GetName(), name: typeContext.vType.GetName()}
+			module, found := previouslyResolved[key]
+			if !found {
+				moduleOption := st.tryToSolveType(typeContext)
+				module, found = moduleOption.GetOrElse(""), moduleOption.IsSome()
 			}
 
-			st.tryToSolveType(&typesContext[x], moduleName)
+			if !found {
+				// Not found! Keep it registered as pending
+				unsolved.typesByModule[moduleName] = append(unsolved.typesByModule[moduleName], typeContext)
+				continue
+			}
+			typeContext.vType.SetModule(module)
+			resolved[key] = module
 		}
 	}
 
-	// Resolve inline sub struct
-	st.expendStructSubtypes()
+	st.resolvedByDocument[docId] = resolved
+	if len(unsolved.typesByModule) > 0 {
+		st.pendingByDocument[docId] = unsolved
+	} else {
+		delete(st.pendingByDocument, docId)
+	}
 }
 
-func (st *SymbolsTable) tryToSolveType(typeContext *PendingTypeContext, moduleName string) {
+func (st *SymbolsTable) tryToSolveType(typeContext PendingTypeContext) option.Option[string] {
 	if len(typeContext.contextModule.Imports) > 0 {
 		// Check inside imported modules
 		for _, imported := range typeContext.contextModule.Imports {
@@ -92,22 +163,16 @@ func (st *SymbolsTable) tryToSolveType(typeContext *PendingTypeContext, moduleNa
 
 				moduleOption := st.findTypeInModules(typeContext.vType)
 				if moduleOption.IsSome() {
-					// Found in same file! Fix it
-					typeContext.vType.SetModule(moduleOption.Get())
-					typeContext.Solve()
+					return moduleOption
 				}
 
 			}
 		}
-		// Not found! Keep it registered as pending
-	} else {
-		moduleOption := st.findTypeInModules(typeContext.vType)
-		if moduleOption.IsSome() {
-			// Found in same file! Fix it
-			typeContext.vType.SetModule(moduleOption.Get())
-			typeContext.Solve()
-		}
+		// Not found!
+		return option.None[string]()
 	}
+
+	return st.findTypeInModules(typeContext.vType)
 }
 
 func (st *SymbolsTable) findTypeInModules(vType *symbols.Type) option.Option[string] {
@@ -126,13 +191,8 @@ func (st *SymbolsTable) findTypeInModules(vType *symbols.Type) option.Option[str
 }
 
 // Resolves inline sub structs
-func (st *SymbolsTable) expendStructSubtypes() {
-
-	if len(st.pendingToResolve.subtyptingToResolve) == 0 {
-		return
-	}
-
-	for _, struktWithSubtyping := range st.pendingToResolve.subtyptingToResolve {
+func (st *SymbolsTable) expendStructSubtypes(subtyptingToResolve []StructWithSubtyping) {
+	for _, struktWithSubtyping := range subtyptingToResolve {
 		for _, inlinedMemberName := range struktWithSubtyping.members {
 
 			// Go through all parsed modules searching structs with members to inline
@@ -148,6 +208,4 @@ func (st *SymbolsTable) expendStructSubtypes() {
 			}
 		}
 	}
-
-	st.pendingToResolve.subtyptingToResolve = []StructWithSubtyping{}
 }
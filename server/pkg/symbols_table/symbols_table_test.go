@@ -34,7 +34,7 @@ func TestSymbolsTable_should_expand_substructs(t *testing.T) {
 	symbolsTable.Register(um, pendingToResolve)
 
 	// After registering new unit modules, inlined structs should be expanded
-	members := module.Structs["ToProcess"].GetMembers()
+	members := symbolsTable.GetByDoc(docId).Get(mod).Structs["ToProcess"].GetMembers()
 	assert.True(t, members[1].IsExpandedInline())
 
 	assert.Equal(t, "a", members[2].GetName())
@@ -43,7 +43,9 @@ func TestSymbolsTable_should_expand_substructs(t *testing.T) {
 	assert.Equal(t, "b", members[3].GetName())
 	assert.Equal(t, "char", members[3].GetType().GetName())
 
-	assert.Equal(t, 0, len(symbolsTable.pendingToResolve.subtyptingToResolve))
+	// Registered modules are copies, those given are not modified
+	assert.False(t, module.Structs["ToProcess"].GetMembers()[1].IsExpandedInline())
+	assert.Len(t, module.Structs["ToProcess"].GetMembers(), 2)
 }
 
 func TestExtractSymbols_find_variables_flag_pending_to_resolve(t *testing.T) {
@@ -66,7 +68,7 @@ func TestExtractSymbols_find_variables_flag_pending_to_resolve(t *testing.T) {
 		pendingToResolve.AddVariableType([]*symbols.Variable{module.Variables["value"]}, module)
 		symbolsTable.Register(um, pendingToResolve)
 
-		assert.Equal(t, true, symbolsTable.pendingToResolve.GetTypesByModule(mod)[0].solved)
+		assert.NotContains(t, symbolsTable.pendingByDocument, docId)
 	})
 
 	t.Run("resolves variable type declaration defined in different file & module should resolve", func(t *testing.T) {
@@ -96,7 +98,7 @@ func TestExtractSymbols_find_variables_flag_pending_to_resolve(t *testing.T) {
 		umB.modules.Set(mod, moduleB)
 		symbolsTable.Register(umB, NewPendingToResolve())
 
-		assert.Equal(t, true, symbolsTable.pendingToResolve.GetTypesByModule(mod)[0].solved)
+		assert.NotContains(t, symbolsTable.pendingByDocument, docId)
 	})
 
 	t.Run("resolves struct member type declaration defined in different file & module should resolve", func(t *testing.T) {
@@ -129,8 +131,10 @@ func TestExtractSymbols_find_variables_flag_pending_to_resolve(t *testing.T) {
 		umB.modules.Set(mod, moduleB)
 		symbolsTable.Register(umB, NewPendingToResolve())
 
-		assert.Equal(t, true, symbolsTable.pendingToResolve.GetTypesByModule(mod)[0].solved)
-		assert.Equal(t, "yy::Ref", module.Structs["CustomStruct"].GetMembers()[0].GetType().GetFullQualifiedName())
+		// Only char is kept pending, as the builder does not flag it as a base type.
+		pending := symbolsTable.pendingByDocument[docId]
+		assert.Equal(t, "char", pending.GetTypesByModule(mod)[0].vType.GetName())
+		assert.Equal(t, "yy::Ref", symbolsTable.GetByDoc(docId).Get(mod).Structs["CustomStruct"].GetMembers()[0].GetType().GetFullQualifiedName())
 	})
 
 	t.Run("resolves function return and argument types defined in different file & module should resolve", func(t *testing.T) {
@@ -164,9 +168,9 @@ func TestExtractSymbols_find_variables_flag_pending_to_resolve(t *testing.T) {
 		umB.modules.Set(mod, moduleB)
 		symbolsTable.Register(umB, NewPendingToResolve())
 
-		assert.Equal(t, true, symbolsTable.pendingToResolve.GetTypesByModule(mod)[0].solved)
-		assert.Equal(t, "yy::Ref", module.ChildrenFunctions[0].GetReturnType().GetFullQualifiedName())
-		assert.Equal(t, "yy::Ref", module.ChildrenFunctions[0].GetArguments()[0].GetType().GetFullQualifiedName())
+		assert.NotContains(t, symbolsTable.pendingByDocument, docId)
+		assert.Equal(t, "yy::Ref", symbolsTable.GetByDoc(docId).Get(mod).ChildrenFunctions[0].GetReturnType().GetFullQualifiedName())
+		assert.Equal(t, "yy::Ref", symbolsTable.GetByDoc(docId).Get(mod).ChildrenFunctions[0].GetArguments()[0].GetType().GetFullQualifiedName())
 	})
 
 	t.Run("resolves definition defined in different file & module should resolve", func(t *testing.T) {
@@ -198,8 +202,71 @@ func TestExtractSymbols_find_variables_flag_pending_to_resolve(t *testing.T) {
 		umB.modules.Set(mod, moduleB)
 		symbolsTable.Register(umB, NewPendingToResolve())
 
-		assert.Equal(t, true, symbolsTable.pendingToResolve.GetTypesByModule(mod)[0].solved)
-		assert.Equal(t, "std::collections::map::HashMap", module.Defs["foo"].ResolvedType().GetFullQualifiedName())
+		assert.NotContains(t, symbolsTable.pendingByDocument, docId)
+		assert.Equal(t, "std::collections::map::HashMap", symbolsTable.GetByDoc(docId).Get(mod).Defs["foo"].ResolvedType().GetFullQualifiedName())
 	})
+}
+
+func TestSymbolsTable_forgets_pending_types_of_documents(t *testing.T) {
+	newUnitModules := func(docId string) (UnitModules, PendingToResolve) {
+		um := NewParsedModules(&docId)
+		module := symbols.NewModuleBuilder("xx", docId).Build()
+		module.AddVariable(
+			symbols.NewVariableBuilder("value", "Ref", "xx", docId).Build(),
+		)
+		um.modules.Set("xx", module)
+		pendingToResolve := NewPendingToResolve()
+		pendingToResolve.AddVariableType([]*symbols.Variable{module.Variables["value"]}, module)
+
+		return um, pendingToResolve
+	}
+
+	t.Run("replaces pending types of documents registered again", func(t *testing.T) {
+		symbolsTable := NewSymbolsTable()
+		symbolsTable.Register(newUnitModules("aDocId"))
+		symbolsTable.Register(newUnitModules("aDocId"))
 
+		pending := symbolsTable.pendingByDocument["aDocId"]
+		assert.Len(t, pending.GetTypesByModule("xx"), 1)
+	})
+
+	t.Run("forgets pending types of deleted documents", func(t *testing.T) {
+		symbolsTable := NewSymbolsTable()
+		symbolsTable.Register(newUnitModules("aDocId"))
+		symbolsTable.DeleteDocument("aDocId")
+
+		assert.Empty(t, symbolsTable.pendingByDocument)
+	})
+}
+
+func TestSymbolsTable_replaces_modules_of_documents_resolved_later(t *testing.T) {
+	docId := "aDocId"
+	symbolsTable := NewSymbolsTable()
+
+	um := NewParsedModules(&docId)
+	module := symbols.NewModuleBuilder("xx", docId).Build()
+	module.AddVariable(
+		symbols.NewVariableBuilder("value", "Ref", "xx", docId).Build(),
+	)
+	um.modules.Set("xx", module)
+	pendingToResolve := NewPendingToResolve()
+	pendingToResolve.AddVariableType([]*symbols.Variable{module.Variables["value"]}, module)
+	symbolsTable.Register(um, pendingToResolve)
+	registered := symbolsTable.GetByDoc(docId).Get("xx")
+	all := symbolsTable.All()
+
+	docBId := "aDocBId"
+	umB := NewParsedModules(&docBId)
+	moduleB := symbols.NewModuleBuilder("yy", docBId).Build()
+	moduleB.AddDef(
+		symbols.NewDefBuilder("Ref", "yy", docBId).Build(),
+	)
+	umB.modules.Set("yy", moduleB)
+	replaced := symbolsTable.Register(umB, NewPendingToResolve())
+
+	assert.Equal(t, []string{docId}, replaced)
+	assert.Equal(t, "yy::Ref", symbolsTable.GetByDoc(docId).Get("xx").Variables["value"].GetType().GetFullQualifiedName())
+	// Modules registered before are not modified
+	assert.Equal(t, "xx::Ref", registered.Variables["value"].GetType().GetFullQualifiedName())
+	assert.NotContains(t, all, docBId)
 }
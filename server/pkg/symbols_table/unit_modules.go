@@ -22,6 +22,12 @@ func (ps UnitModules) DocId() string {
 	return *ps.docId
 }
 
+// WithDocId returns the same modules registered under another document id.
+func (ps UnitModules) WithDocId(docId string) UnitModules {
+	ps.docId = &docId
+	return ps
+}
+
 func (ps *UnitModules) ModuleIds() []string {
 	return ps.modules.Keys()
 }